@@ -1,6 +1,6 @@
 package mcp
 
-// COSCUP 2025 event constants
+// COSCUP 2025 event dates, the defaults of COSCUPConfig
 const (
 	COSCUPYear  = 2025
 	COSCUPMonth = 8
@@ -8,11 +8,69 @@ const (
 	COSCUPDay2  = 10
 )
 
+// Conference phases relative to the event dates
+const (
+	ConferencePhaseBefore = "before"
+	ConferencePhaseDuring = "during"
+	ConferencePhaseAfter  = "after"
+)
+
+// Conference timezone (Taiwan has no daylight saving time, so a fixed offset is exact)
+const (
+	ConferenceTimezone      = "Asia/Taipei"
+	ConferenceUTCOffsetSecs = 8 * 60 * 60
+)
+
+// Conference hours (local time)
+const (
+	ConferenceStartHour = 9
+	ConferenceEndHour   = 17
+	MinSessionMinutes   = 30 // Shortest regular talk; used to decide if more planning still makes sense
+)
+
+// LastEndTime of a plan with nothing scheduled, before the first sessions of the day
+const PlanningStartTime = "08:00"
+
+// Lunch window (local time, minutes since midnight); breaks overlapping it are treated as lunch breaks
+const (
+	LunchWindowStartMinutes = 12 * 60
+	LunchWindowEndMinutes   = 13*60 + 30
+	MinLunchMinutes         = 30 // Shortest gap suggested as a proper lunch break
+	LunchBreakMinutes       = 60 // A gap between sessions longer than this overlapping the lunch window gets the lunch_break status
+)
+
 // System configuration constants
 const (
-	DefaultNumShards    = 16
-	SessionCleanupHours = 24
-	LongSessionMinutes  = 240 // 4 hours
+	DefaultNumShards             = 16
+	SessionCleanupHours          = 24  // Default TTL of plans that were never finished
+	CompletedSessionCleanupHours = 72  // Default TTL of finished plans, which users are more likely to come back to
+	LongSessionMinutes           = 240 // 4 hours
+	MaxSessionMinutes            = 720 // Anything longer is an End-before-Start typo, not a session crossing midnight
+	ShutdownTimeoutSeconds       = 10  // Time in-flight HTTP requests get to finish after SIGINT/SIGTERM
+
+	DefaultEndingSoonMinutes = 15 // Default look-ahead window for get_ending_soon
+	StartingSoonMinutes      = 15 // Look-ahead window for the next_starting_soon list of get_now
+	DefaultStartGraceMinutes = 15 // Running sessions that started at most this long ago are still offered by start_planning
+	DefaultRoundTripMinutes  = 40 // Default off-campus round trip (e.g. a meal nearby) assumed by off_campus_break
+
+	TightTransferBufferMinutes = 5  // A transfer leaving at most this much slack after walking is tight
+	LongGapMinutes             = 60 // A break longer than this counts against the balance score and gets fill-in suggestions
+	MaxBreakFillInSuggestions  = 2  // Sessions suggested to fill a long break in get_next_session
+	MaxSummaryNextSessions     = 2  // Upcoming sessions, with routes, shown by summarize_remaining_day
+	ArrivalBufferMinutes       = 3  // Time to find a seat after walking, counted when judging if a transfer is feasible
+	CrowdedBuildingSessions    = 8  // Concurrent sessions in one building at which get_crowding warns about busy hallways
+
+	MaxDetailedRoomSessions  = 12  // Cap on sessions returned with abstracts by get_room_schedule include_detail
+	DefaultSearchLimit       = 20  // Cap on sessions returned by search_sessions unless a limit is given
+	DefaultPageLimit         = 50  // Sessions per get_all_sessions page unless a limit is given
+	MaxPageLimit             = 200 // Largest page get_all_sessions accepts
+	MaxTrackRepresentatives  = 2   // Teaser sessions shown per track by get_track_catalog
+	MaxStarterPlanSessions   = 6   // Sessions in a get_starter_plan suggestion, keynotes included
+	MinReviewVarietySessions = 3   // review_plan only flags a single-track schedule once it has this many sessions
+
+	ProfileMatchScore  = 100 // Recommendation score of a session whose track is in the user's profile
+	SpeakerFollowScore = 50  // Extra score of a session by a speaker the user already chose a talk from
+	ProfileTagScore    = 30  // Extra score of a session tagged with one of the user's profile interests
 )
 
 // Venue walking time constants (minutes)
@@ -29,6 +87,29 @@ const (
 	UnknownWalkTime      = 5 // Default for unknown routes
 )
 
+// Arrival advice constants (minutes)
+const (
+	ArrivalBaseBuffer = 15 // Time to get through the campus gate, check in and find the venue
+	AUEntryTime       = 2
+	RBEntryTime       = 3
+	TREntryTime       = 5
+	UnknownEntryTime  = 5 // Default for unknown buildings
+)
+
+// Venue walking distance constants (meters), rough estimates matching the time matrix above
+const (
+	SameBuildingWalkDistance = 50
+	AUToRBWalkDistance       = 150
+	AUToTRWalkDistance       = 300
+	RBToAUWalkDistance       = 150
+	RBToRBWalkDistance       = 50
+	RBToTRWalkDistance       = 220
+	TRToAUWalkDistance       = 300
+	TRToRBWalkDistance       = 220
+	TRInternalWalkDistance   = 120
+	UnknownWalkDistance      = 350 // Default for unknown routes
+)
+
 // String constants
 const (
 	DayAug9             = "Aug9"
@@ -37,6 +118,43 @@ const (
 	DayFormatAug10      = "Aug.10"
 	DifficultyBeginner  = "入門"
 	StatusOutsideCOSCUP = "OutsideCOSCUP"
+
+	// DefaultOutsideCOSCUPDay is the day used for data lookups outside COSCUP when OutsideCOSCUPDefaultDays has no entry
+	DefaultOutsideCOSCUPDay = DayAug9
+)
+
+// Verbosity controls how much detail tool responses include
+type Verbosity string
+
+// Response verbosity levels
+const (
+	VerbosityTerse  Verbosity = "terse"  // Short messages, no route or timeline details
+	VerbosityNormal Verbosity = "normal" // Default
+	VerbosityRich   Verbosity = "rich"   // Step-by-step directions and full session details
+)
+
+// Reasons reported when there are no recommendations left
+const (
+	EmptyReasonNoLaterSessions = "no_later_sessions" // Nothing starts after the user's last end time
+	EmptyReasonAllConflict     = "all_conflict"      // Later sessions exist but all clash with the schedule
+	EmptyReasonOnlySocial      = "only_social"       // Only filtered-out social activities remain
+)
+
+// Mobility describes how fast a user moves between rooms; walking times are scaled accordingly
+type Mobility string
+
+// Mobility settings
+const (
+	MobilityFast   Mobility = "fast"   // Talk-hoppers travelling light
+	MobilityNormal Mobility = "normal" // Default, uses the base walking times
+	MobilitySlow   Mobility = "slow"   // Workshop attendees carrying laptops, or anyone taking it easy
+)
+
+// Reasons reported with a schedule_complete status
+const (
+	CompleteReasonFinishedPlanning = "finished_planning" // The user (or auto-finish) ended planning
+	CompleteReasonNoMoreSessions   = "no_more_sessions"  // The last planned session ended and nothing worth adding remains
+	CompleteReasonConferenceOver   = "conference_over"   // The planned day's program is over
 )
 
 // Building codes
@@ -45,3 +163,6 @@ const (
 	BuildingRB = "RB"
 	BuildingTR = "TR"
 )
+
+// UnknownLanguage labels sessions whose spoken language is not given in the data
+const UnknownLanguage = "未標示"
@@ -1,8 +1,14 @@
 package mcp
 
 import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 // Session represents a COSCUP session
@@ -18,52 +24,206 @@ type Session struct {
 	Difficulty string   `json:"difficulty"`
 	Room       string   // derived from JSON structure
 	Day        string   // "Aug.9" or "Aug.10"
-	URL        string   `json:"url"`  // Official COSCUP session URL
-	Tags       []string `json:"tags"` // Universal tags for categorization
+	URL        string   `json:"url"`                  // Official COSCUP session URL
+	Tags       []string `json:"tags"`                 // Universal tags for categorization
+	Cancelled  bool     `json:"cancelled,omitempty"`  // Set on scheduled copies that disappeared after a data reload
+	StreamURL  string   `json:"stream_url,omitempty"` // Livestream URL, empty if the session is not streamed
 }
 
-// Global data storage - initialized at package load time
-var (
-	allSessions   []Session
-	sessionsByDay = make(map[string][]Session)
-)
+// livestreamRooms lists rooms, by their full name as in Session.Room, with an official livestream:
+// the main stages hosting the keynotes. Their sessions get a StreamURL at load time
+// Sessions whose data already carries a StreamURL keep it
+var livestreamRooms = map[string]bool{
+	"AU":    true,
+	"RB105": true,
+}
+
+// sessionIndex is one generation of the session data together with every cache derived from it
+// It is never modified once published, so readers need no lock
+type sessionIndex struct {
+	all   []Session
+	byDay map[string][]Session
+
+	// firstByDay caches the earliest-starting sessions of each day for start_planning
+	firstByDay map[string][]Session
+
+	// byCode indexes all by code for FindSessionByCode
+	byCode map[string]Session
+
+	// byDayRoom caches each day's sessions grouped by room and sorted by start time
+	byDayRoom map[string]map[string][]Session
+
+	// rejected is how many sessions the load found invalid, reported by GetSessionStats
+	rejected int
+}
+
+// currentSessionIndex holds the published session data - initialized at package load time
+// ReloadData builds a complete new index off to the side and swaps it in with a single store
+var currentSessionIndex atomic.Pointer[sessionIndex]
+
+// sessionData returns the current session data
+// Hold on to the result for a whole lookup so every field comes from the same generation
+func sessionData() *sessionIndex {
+	return currentSessionIndex.Load()
+}
 
 // init initializes COSCUP session data from embedded data
 // This happens automatically when the package is loaded
 func init() {
 	// Process embedded data from embedded_data.go
-	for day, rooms := range COSCUPData {
-		for _, sessions := range rooms {
-			for _, session := range sessions {
+	loadSessionData(COSCUPData)
+}
+
+// DropInvalidSessions makes loading leave out sessions with unusable times instead of only logging them
+// Set it before ReloadData to change it
+var DropInvalidSessions = true
+
+// validateSession reports why a session's times can't be used, or nil when they are fine
+// An end before the start is accepted as crossing midnight unless the session would last over MaxSessionMinutes
+func validateSession(session Session) error {
+	if !isValidTime(session.Start) {
+		return fmt.Errorf("invalid start time %q", session.Start)
+	}
+	if !isValidTime(session.End) {
+		return fmt.Errorf("invalid end time %q", session.End)
+	}
+	duration := sessionDurationMinutes(session.Start, session.End)
+	if duration == 0 || duration > MaxSessionMinutes {
+		return fmt.Errorf("end %s is not after start %s", session.End, session.Start)
+	}
+	return nil
+}
+
+// validateSessions logs every session with unusable times and returns the data without them
+// (unchanged when DropInvalidSessions is off) together with how many were invalid
+func validateSessions(data map[string]map[string][]Session) (map[string]map[string][]Session, int) {
+	valid := make(map[string]map[string][]Session, len(data))
+	rejected := 0
+	for day, rooms := range data {
+		valid[day] = make(map[string][]Session, len(rooms))
+		for room, roomSessions := range rooms {
+			kept := make([]Session, 0, len(roomSessions))
+			for _, session := range roomSessions {
+				if err := validateSession(session); err != nil {
+					rejected++
+					log.Printf("Invalid session %s on %s in %s: %v", session.Code, day, room, err)
+					if DropInvalidSessions {
+						continue
+					}
+				}
+				kept = append(kept, session)
+			}
+			valid[day][room] = kept
+		}
+	}
+	return valid, rejected
+}
+
+// loadSessionData builds a new session index from day -> room -> sessions data and publishes it
+// Sessions with unusable times are dropped first, see validateSessions
+func loadSessionData(data map[string]map[string][]Session) {
+	data, rejected := validateSessions(data)
+
+	var sessions []Session
+	byDay := make(map[string][]Session)
+
+	for day, rooms := range data {
+		for _, roomSessions := range rooms {
+			for _, session := range roomSessions {
 				// Add official COSCUP URL
 				session.URL = "https://coscup.org/2025/sessions/" + session.Code
 
+				// Streamed rooms embed the livestream on the session page
+				if session.StreamURL == "" && livestreamRooms[session.Room] {
+					session.StreamURL = session.URL
+				}
+
 				// Tags are already defined in embedded_data.go
 				// No need to generate tags - they come from the embedded data
 
-				allSessions = append(allSessions, session)
-				sessionsByDay[day] = append(sessionsByDay[day], session)
+				sessions = append(sessions, session)
+				byDay[day] = append(byDay[day], session)
 			}
 		}
 	}
+
+	currentSessionIndex.Store(newSessionIndex(sessions, byDay, rejected))
+}
+
+// newSessionIndex builds an index and all its caches from the given sessions
+// The caller must not modify sessions or byDay afterwards
+func newSessionIndex(sessions []Session, byDay map[string][]Session, rejected int) *sessionIndex {
+	index := &sessionIndex{
+		all:      sessions,
+		byDay:    byDay,
+		rejected: rejected,
+	}
+
+	index.firstByDay = make(map[string][]Session, len(byDay))
+	for day, daySessions := range byDay {
+		index.firstByDay[day] = findEarliestSessions(daySessions)
+	}
+
+	index.byCode = make(map[string]Session, len(sessions))
+	for _, session := range sessions {
+		// Keep the first session of a duplicated code, as a linear scan would find
+		if _, exists := index.byCode[session.Code]; !exists {
+			index.byCode[session.Code] = session
+		}
+	}
+
+	index.byDayRoom = make(map[string]map[string][]Session, len(byDay))
+	for day, sessions := range byDay {
+		byRoom := make(map[string][]Session)
+		for _, session := range sessions {
+			byRoom[session.Room] = append(byRoom[session.Room], session)
+		}
+		for _, roomSessions := range byRoom {
+			sortSessionsByStartTime(roomSessions)
+		}
+		index.byDayRoom[day] = byRoom
+	}
+
+	return index
+}
+
+// ReloadData replaces the session data (e.g. after the official schedule changes) and rebuilds caches
+// Safe to call while tools are running: readers keep the index they loaded until their lookup is done
+func ReloadData(data map[string]map[string][]Session) {
+	loadSessionData(data)
+	cancelled := reconcileSchedules()
+	index := sessionData()
+	log.Printf("Reloaded COSCUP session data: %d sessions across %d days, %d scheduled entries marked cancelled",
+		len(index.all), len(index.byDay), cancelled)
 }
 
 // FindSessionByCode finds a session by its code
-// Returns a safe copy since allSessions is global data - preserves complete abstract for detailed view
+// Returns a safe copy since the session index is shared - preserves complete abstract for detailed view
 func FindSessionByCode(code string) *Session {
-	for _, session := range allSessions {
-		if session.Code == code {
-			// Return a copy to protect global data while preserving complete abstract
-			result := session
-			return &result
-		}
+	session, exists := sessionData().byCode[code]
+	if !exists {
+		return nil
 	}
-	return nil
+	// Return a copy to protect global data while preserving complete abstract
+	return &session
 }
 
 // GetFirstSession returns the first session of the day (usually Welcome)
 func GetFirstSession(day string) []Session {
-	sessions := sessionsByDay[day]
+	index := sessionData()
+	earliestSessions, cached := index.firstByDay[day]
+	if !cached {
+		earliestSessions = findEarliestSessions(index.byDay[day])
+	}
+	if len(earliestSessions) == 0 {
+		return nil
+	}
+
+	return getSimplifiedSessions(earliestSessions)
+}
+
+// findEarliestSessions returns all sessions sharing the earliest start time
+func findEarliestSessions(sessions []Session) []Session {
 	if len(sessions) == 0 {
 		return nil
 	}
@@ -84,7 +244,152 @@ func GetFirstSession(day string) []Session {
 		}
 	}
 
-	return getSimplifiedSessions(earliestSessions)
+	return earliestSessions
+}
+
+// TrackSummary describes a track with its session count and a few teaser sessions
+type TrackSummary struct {
+	Track           string    `json:"track"`
+	SessionCount    int       `json:"session_count"`
+	Representatives []Session `json:"representatives"`
+}
+
+// GetTrackSummary returns every track of the given internal day ("" for both days),
+// ordered by session count (largest first) with up to MaxTrackRepresentatives teaser sessions each
+func GetTrackSummary(day string) []TrackSummary {
+	index := sessionData()
+	sessions := index.all
+	if day != "" {
+		sessions = index.byDay[day]
+	}
+
+	byTrack := make(map[string][]Session)
+	for _, session := range sessions {
+		if session.Track == "" {
+			continue
+		}
+		byTrack[session.Track] = append(byTrack[session.Track], session)
+	}
+
+	summaries := make([]TrackSummary, 0, len(byTrack))
+	for track, trackSessions := range byTrack {
+		summaries = append(summaries, TrackSummary{
+			Track:           track,
+			SessionCount:    len(trackSessions),
+			Representatives: getSimplifiedSessions(pickRepresentativeSessions(trackSessions, MaxTrackRepresentatives)),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].SessionCount != summaries[j].SessionCount {
+			return summaries[i].SessionCount > summaries[j].SessionCount
+		}
+		return summaries[i].Track < summaries[j].Track
+	})
+
+	return summaries
+}
+
+// GroupSessionsByTag groups the simplified sessions of an internal day by tag, each group sorted by start time
+// Sessions with several tags appear under each of them
+func GroupSessionsByTag(day string) map[string][]Session {
+	groups := make(map[string][]Session)
+	for _, session := range getSimplifiedSessions(sessionData().byDay[day]) {
+		for _, tag := range session.Tags {
+			groups[tag] = append(groups[tag], session)
+		}
+	}
+
+	for _, sessions := range groups {
+		sortSessionsByStartTime(sessions)
+	}
+	return groups
+}
+
+// FindSessionsByTags returns the simplified sessions of an internal day tagged with all (matchAll) or any of tags,
+// sorted by start time. Tags match with or without their emoji prefix, case-insensitively
+func FindSessionsByTags(day string, tags []string, matchAll bool) []Session {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var matching []Session
+	for _, session := range getSimplifiedSessions(sessionData().byDay[day]) {
+		matched := 0
+		for _, tag := range tags {
+			if slices.ContainsFunc(session.Tags, func(sessionTag string) bool { return tagMatches(sessionTag, tag) }) {
+				matched++
+			}
+		}
+		if (matchAll && matched == len(tags)) || (!matchAll && matched > 0) {
+			matching = append(matching, session)
+		}
+	}
+
+	sortSessionsByStartTime(matching)
+	return matching
+}
+
+// tagMatches reports whether a session tag such as "🧠 AI" matches a user-supplied tag like "ai" or "🧠 AI"
+func tagMatches(sessionTag, query string) bool {
+	query = strings.TrimSpace(query)
+	if strings.EqualFold(sessionTag, query) {
+		return true
+	}
+	_, name, found := strings.Cut(sessionTag, " ")
+	return found && strings.EqualFold(name, query)
+}
+
+// pickRepresentativeSessions picks up to n sessions to showcase a track:
+// keynotes first, then the earliest sessions of the conference
+func pickRepresentativeSessions(sessions []Session, n int) []Session {
+	candidates := make([]Session, len(sessions))
+	copy(candidates, sessions)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		keynoteI := strings.Contains(strings.ToLower(candidates[i].Title), "keynote")
+		keynoteJ := strings.Contains(strings.ToLower(candidates[j].Title), "keynote")
+		if keynoteI != keynoteJ {
+			return keynoteI
+		}
+		if candidates[i].Day != candidates[j].Day {
+			return candidates[i].Day == DayFormatAug9
+		}
+		if candidates[i].Start != candidates[j].Start {
+			return timeToMinutes(candidates[i].Start) < timeToMinutes(candidates[j].Start)
+		}
+		return candidates[i].Code < candidates[j].Code
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// outlineItemPattern matches a bulleted ("- ", "* ", "• ") or numbered ("1. ", "2) ", "3、") list item
+var outlineItemPattern = regexp.MustCompile(`^(?:[-*•‧]\s+|\d+(?:[.)]\s+|、\s*))(.+)$`)
+
+// splitAbstractOutline splits an abstract into its prose summary and a list of outline items
+// Returns ok=false when fewer than two list items are found, in which case the raw abstract should be used
+func splitAbstractOutline(abstract string) (summary string, outline []string, ok bool) {
+	var summaryLines []string
+	for _, line := range strings.Split(abstract, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if match := outlineItemPattern.FindStringSubmatch(line); match != nil {
+			outline = append(outline, strings.TrimSpace(match[1]))
+			continue
+		}
+		summaryLines = append(summaryLines, line)
+	}
+
+	if len(outline) < 2 {
+		return abstract, nil, false
+	}
+	return strings.Join(summaryLines, "\n"), outline, true
 }
 
 // timeToMinutes converts "HH:MM" to minutes since midnight
@@ -105,19 +410,92 @@ func timeToMinutes(timeStr string) int {
 	return hours*60 + minutes
 }
 
+// isValidTime reports whether timeStr is a well-formed "HH:MM" time of day
+func isValidTime(timeStr string) bool {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) != 2 {
+		return false
+	}
+
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	return err1 == nil && err2 == nil && hours >= 0 && hours <= 23 && minutes >= 0 && minutes <= 59
+}
+
+// endTimeToMinutes converts a session's end time to minutes since the start day's midnight
+// An end earlier than a valid start means the session crosses midnight, so 24h is added
+func endTimeToMinutes(start, end string) int {
+	endMin := timeToMinutes(end)
+	if isValidTime(start) && isValidTime(end) && endMin < timeToMinutes(start) {
+		endMin += 24 * 60
+	}
+	return endMin
+}
+
+// sessionDurationMinutes returns the length of a session, handling sessions that cross midnight
+func sessionDurationMinutes(start, end string) int {
+	return endTimeToMinutes(start, end) - timeToMinutes(start)
+}
+
+// minutesToTime converts minutes since midnight to "HH:MM", clamping to the same day
+func minutesToTime(minutes int) string {
+	minutes = max(0, min(minutes, 23*60+59))
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
 // IsValidDay checks if the given day is valid
 func IsValidDay(day string) bool {
 	return day == DayAug9 || day == DayAug10
 }
 
+// strictDayFormat makes parseDayFormat reject unrecognized days, see SetStrictDayFormat
+var strictDayFormat bool
+
+// SetStrictDayFormat makes parseDayFormat reject unrecognized days with ErrInvalidDay instead of
+// passing them through. Off by default
+func SetStrictDayFormat(strict bool) {
+	strictDayFormat = strict
+}
+
+// isKnownDay reports whether day is a conference day in either the user or the internal format
+func isKnownDay(day string) bool {
+	return IsValidDay(day) || day == DayFormatAug9 || day == DayFormatAug10
+}
+
 // convertDayFormat converts user input format to internal format
+// StatusOutsideCOSCUP maps to DefaultOutsideCOSCUPDay instead of an unknown day that would yield no sessions
+// Internal days and any other value pass through unchanged, use parseDayFormat to reject unknown days
 func convertDayFormat(userDay string) string {
 	switch userDay {
 	case DayAug9:
 		return DayFormatAug9
 	case DayAug10:
 		return DayFormatAug10
+	case StatusOutsideCOSCUP:
+		return convertDayFormat(DefaultOutsideCOSCUPDay)
 	default:
 		return userDay
 	}
 }
+
+// parseDayFormat converts a day to the internal format like convertDayFormat
+// In strict mode an unrecognized day, which would match no sessions, fails with ErrInvalidDay
+func parseDayFormat(userDay string) (string, error) {
+	if strictDayFormat && !isKnownDay(userDay) && userDay != StatusOutsideCOSCUP {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDay, userDay)
+	}
+	return convertDayFormat(userDay), nil
+}
+
+// toUserDayFormat converts an internal day back to the user-facing format, the inverse of convertDayFormat
+// Used wherever a day is shown to users; user-format and unrecognized days pass through unchanged
+func toUserDayFormat(internalDay string) string {
+	switch internalDay {
+	case DayFormatAug9:
+		return DayAug9
+	case DayFormatAug10:
+		return DayAug10
+	default:
+		return internalDay
+	}
+}
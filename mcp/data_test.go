@@ -1,7 +1,13 @@
 package mcp
 
 import (
+	"bytes"
+	"errors"
+	"log"
 	"mcp-coscup/mcp/testutil"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -49,6 +55,9 @@ func TestHasTimeConflict(t *testing.T) {
 		{"Adjacent sessions - no overlap", "09:00", "10:00", "10:00", "11:00", false},
 		{"Same time periods", "09:00", "10:00", "09:00", "10:00", true},
 		{"One minute overlap", "09:00", "10:01", "10:00", "11:00", true},
+		{"Midnight crossing - overlaps late session", "23:30", "00:30", "23:45", "23:59", true},
+		{"Midnight crossing - no overlap with earlier session", "23:30", "00:30", "22:00", "23:30", false},
+		{"Midnight crossing - both cross midnight", "23:30", "00:30", "23:00", "00:15", true},
 	}
 
 	for _, tt := range tests {
@@ -59,6 +68,25 @@ func TestHasTimeConflict(t *testing.T) {
 	}
 }
 
+func TestSessionDurationMinutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		expected int
+	}{
+		{"Regular session", "09:00", "09:40", 40},
+		{"Crosses midnight", "23:30", "00:30", 60},
+		{"Invalid end is not treated as crossing midnight", "10:00", "bad", -600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.expected, sessionDurationMinutes(tt.start, tt.end), "sessionDurationMinutes result")
+		})
+	}
+}
+
 func TestIsValidDay(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -83,6 +111,42 @@ func TestIsValidDay(t *testing.T) {
 	}
 }
 
+// captureLog collects the standard logger's output for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestToUserDayFormat(t *testing.T) {
+	for _, userDay := range []string{DayAug9, DayAug10} {
+		testutil.AssertEqual(t, userDay, toUserDayFormat(convertDayFormat(userDay)), "Round trip from "+userDay)
+	}
+	for _, internalDay := range []string{DayFormatAug9, DayFormatAug10} {
+		testutil.AssertEqual(t, internalDay, convertDayFormat(toUserDayFormat(internalDay)), "Round trip from "+internalDay)
+	}
+	testutil.AssertEqual(t, DayAug9, toUserDayFormat(DayAug9), "User format passes through")
+}
+
+func TestParseDayFormatStrict(t *testing.T) {
+	t.Cleanup(func() { SetStrictDayFormat(false) })
+
+	day, err := parseDayFormat("Aug11")
+	testutil.AssertNoError(t, err, "Unknown day should pass through by default")
+	testutil.AssertEqual(t, "Aug11", day, "Unknown day should be unchanged")
+	testutil.AssertEqual(t, "Sep.1", toUserDayFormat("Sep.1"), "Unknown internal day should be unchanged")
+
+	SetStrictDayFormat(true)
+	for _, known := range []string{DayAug9, DayFormatAug10, StatusOutsideCOSCUP} {
+		_, err := parseDayFormat(known)
+		testutil.AssertNoError(t, err, known+" should be accepted in strict mode")
+	}
+	_, err = parseDayFormat("Aug11")
+	testutil.AssertEqual(t, true, errors.Is(err, ErrInvalidDay), "Unknown day should be rejected in strict mode")
+}
+
 func TestConvertDayFormat(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -94,6 +158,7 @@ func TestConvertDayFormat(t *testing.T) {
 		{"Invalid input", "Aug11", "Aug11"},
 		{"Empty string", "", ""},
 		{"Already formatted", "Aug.9", "Aug.9"},
+		{"Outside COSCUP uses default day", StatusOutsideCOSCUP, "Aug.9"},
 	}
 
 	for _, tt := range tests {
@@ -103,3 +168,352 @@ func TestConvertDayFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFirstSessionCacheMatchesScan(t *testing.T) {
+	for _, day := range []string{DayFormatAug9, DayFormatAug10} {
+		t.Run(day, func(t *testing.T) {
+			cached := GetFirstSession(day)
+			scanned := getSimplifiedSessions(findEarliestSessions(sessionData().byDay[day]))
+
+			testutil.AssertEqual(t, true, len(cached) > 0, "Cached first sessions should not be empty")
+			testutil.AssertEqual(t, len(scanned), len(cached), "Cached result should match a fresh scan")
+
+			scannedCodes := make(map[string]bool)
+			for _, session := range scanned {
+				scannedCodes[session.Code] = true
+			}
+			for _, session := range cached {
+				testutil.AssertEqual(t, true, scannedCodes[session.Code], "Cached session "+session.Code+" should be in scan")
+				testutil.AssertEqual(t, "", session.Abstract, "Cached result should still be simplified")
+			}
+		})
+	}
+}
+
+func TestGetFirstSessionCacheUpdatesAfterReload(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {
+			"AU": {
+				{Code: "RELOAD-LATE", Title: "Late", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+				{Code: "RELOAD-EARLY", Title: "Early", Start: "08:30", End: "09:00", Room: "AU", Day: "Aug.9"},
+			},
+		},
+	})
+
+	first := GetFirstSession("Aug.9")
+	testutil.AssertEqual(t, 1, len(first), "Should return the single earliest session after reload")
+	testutil.AssertEqual(t, "RELOAD-EARLY", first[0].Code, "Cache should reflect reloaded data")
+	testutil.AssertEqual(t, 0, len(GetFirstSession("Aug.10")), "Days missing from reloaded data should be empty")
+}
+
+func TestGetFirstSessionCacheReturnsCopies(t *testing.T) {
+	first := GetFirstSession(DayFormatAug9)
+	originalTitle := first[0].Title
+	first[0].Title = "Modified"
+
+	again := GetFirstSession(DayFormatAug9)
+	testutil.AssertEqual(t, originalTitle, again[0].Title, "Modifying a result should not corrupt the cache")
+}
+
+func TestFindSessionByCodeIndexMatchesScan(t *testing.T) {
+	for _, session := range sessionData().all {
+		var scanned *Session
+		for i := range sessionData().all {
+			if sessionData().all[i].Code == session.Code {
+				scanned = &sessionData().all[i]
+				break
+			}
+		}
+
+		found := FindSessionByCode(session.Code)
+		testutil.AssertEqual(t, true, found != nil, "Indexed lookup should find "+session.Code)
+		testutil.AssertEqual(t, scanned.Title, found.Title, "Indexed lookup should match the scan for "+session.Code)
+		testutil.AssertEqual(t, scanned.Abstract, found.Abstract, "Indexed lookup should keep the full abstract")
+	}
+
+	testutil.AssertEqual(t, (*Session)(nil), FindSessionByCode("NO-SUCH-CODE"), "Unknown code should return nil")
+
+	found := FindSessionByCode(sessionData().all[0].Code)
+	found.Title = "Modified"
+	testutil.AssertEqual(t, sessionData().all[0].Title, FindSessionByCode(sessionData().all[0].Code).Title, "Modifying a result should not corrupt the index")
+}
+
+func TestFindSessionByCodeIndexUpdatesAfterReload(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+	existing := sessionData().all[0].Code
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {
+			"AU": {{Code: "INDEX-NEW", Title: "New", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}},
+		},
+	})
+
+	found := FindSessionByCode("INDEX-NEW")
+	testutil.AssertEqual(t, true, found != nil, "Reloaded session should be indexed")
+	testutil.AssertEqual(t, "New", found.Title, "Index should reflect reloaded data")
+	testutil.AssertEqual(t, (*Session)(nil), FindSessionByCode(existing), "Removed sessions should drop out of the index")
+}
+
+// Run with -race: readers must never see a half-built index while a reload publishes a new one
+func TestReloadDataWhileReading(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+	captureLog(t)
+	storeTestUserState(t, &UserState{SessionID: "test_reload_while_reading", Day: "Aug.9", LastEndTime: "10:00"})
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := GetRecommendations("test_reload_while_reading"); err != nil {
+					t.Errorf("Recommendations should keep working during a reload: %v", err)
+					return
+				}
+				GetFirstSession("Aug.9")
+				FindRoomSessions("Aug.9", "AU")
+			}
+		}()
+	}
+
+	for range 20 {
+		ReloadData(COSCUPData)
+	}
+	close(stop)
+	readers.Wait()
+
+	testutil.AssertEqual(t, true, len(sessionData().all) > 0, "Data should be loaded after the reloads")
+}
+
+func TestReloadDataRejectsInvalidSessionTimes(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+	logs := captureLog(t)
+
+	broken := map[string]map[string][]Session{
+		"Aug.9": {
+			"AU": {
+				{Code: "VALID-OK", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+				{Code: "VALID-BACKWARDS", Start: "14:00", End: "13:30", Room: "AU", Day: "Aug.9"},
+				{Code: "VALID-ZERO", Start: "11:00", End: "11:00", Room: "AU", Day: "Aug.9"},
+				{Code: "VALID-GARBLED", Start: "1pm", End: "13:30", Room: "AU", Day: "Aug.9"},
+				{Code: "VALID-MIDNIGHT", Start: "23:00", End: "01:00", Room: "AU", Day: "Aug.9"},
+			},
+		},
+	}
+	ReloadData(broken)
+
+	testutil.AssertEqual(t, 3, GetSessionStats()["rejected_sessions"], "Backwards, zero-length and unparsable sessions should be rejected")
+	testutil.AssertEqual(t, 2, len(sessionData().all), "Rejected sessions should be dropped")
+	testutil.AssertEqual(t, true, FindSessionByCode("VALID-MIDNIGHT") != nil, "Sessions crossing midnight are valid")
+	testutil.AssertEqual(t, true, FindSessionByCode("VALID-BACKWARDS") == nil, "Backwards sessions should not be indexed")
+	for _, code := range []string{"VALID-BACKWARDS", "VALID-ZERO", "VALID-GARBLED"} {
+		testutil.AssertEqual(t, true, strings.Contains(logs.String(), code), code+" should be logged")
+	}
+
+	DropInvalidSessions = false
+	t.Cleanup(func() { DropInvalidSessions = true })
+	ReloadData(broken)
+	testutil.AssertEqual(t, 3, GetSessionStats()["rejected_sessions"], "Invalid sessions are still counted when kept")
+	testutil.AssertEqual(t, 5, len(sessionData().all), "Invalid sessions should be kept when dropping is off")
+}
+
+func TestReloadDataMarksRemovedScheduledSessionsCancelled(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	kept := Session{Code: "RECON-KEEP", Title: "Kept Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	dropped := Session{Code: "RECON-DROP", Title: "Dropped Session", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"}
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}, "TR211": {dropped}},
+	})
+
+	state := &UserState{
+		SessionID: "test_reconcile_schedules",
+		Day:       "Aug.9",
+		Schedule:  []Session{kept, dropped},
+	}
+	storeTestUserState(t, state)
+
+	// The official schedule drops one session
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}},
+	})
+
+	testutil.AssertEqual(t, 2, len(state.Schedule), "Reload should not delete scheduled entries")
+	testutil.AssertEqual(t, false, state.Schedule[0].Cancelled, "Session still in the dataset should not be cancelled")
+	testutil.AssertEqual(t, true, state.Schedule[1].Cancelled, "Session removed from the dataset should be marked cancelled")
+	testutil.AssertEqual(t, 1, countCancelledSessions(state.Schedule), "Exactly one entry should be cancelled")
+	testutil.AssertEqual(t, true, strings.Contains(generateTimelineView(state), "已取消"), "Timeline should show a cancelled badge")
+
+	// The session comes back in a later reload
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}, "TR211": {dropped}},
+	})
+
+	testutil.AssertEqual(t, false, state.Schedule[1].Cancelled, "Restored session should no longer be cancelled")
+}
+
+func TestReloadDataFreesCancelledSlot(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	kept := Session{Code: "RSLOT-KEEP", Title: "Kept Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	dropped := Session{Code: "RSLOT-DROP", Title: "Dropped Session", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"}
+	replacement := Session{Code: "RSLOT-NEW", Title: "Replacement", Start: "11:00", End: "11:30", Room: "TR212", Day: "Aug.9"}
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}, "TR211": {dropped}, "TR212": {replacement}},
+	})
+	state := &UserState{
+		SessionID:   "test_reload_frees_slot",
+		Day:         "Aug.9",
+		Schedule:    []Session{kept, dropped},
+		LastEndTime: dropped.End,
+	}
+	storeTestUserState(t, state)
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}, "TR212": {replacement}},
+	})
+	testutil.AssertEqual(t, kept.End, GetUserStateSnapshot(state.SessionID).LastEndTime, "LastEndTime should ignore the cancelled session")
+	options, err := GetRecommendations(state.SessionID)
+	testutil.AssertNoError(t, err, "GetRecommendations should succeed")
+	testutil.AssertEqual(t, replacement.Code, recommendationCodes(options), "The freed slot should be offered")
+
+	added, err := ScheduleSession(state.SessionID, replacement.Code)
+	testutil.AssertNoError(t, err, "The cancelled session should not block its replacement")
+	testutil.AssertEqual(t, replacement.Code, added.Code, "Replacement should be added as requested")
+}
+
+func TestMinutesToTime(t *testing.T) {
+	tests := []struct {
+		minutes  int
+		expected string
+	}{
+		{0, "00:00"},
+		{525, "08:45"},
+		{600, "10:00"},
+		{-30, "00:00"},
+		{24 * 60, "23:59"},
+	}
+
+	for _, tt := range tests {
+		testutil.AssertEqual(t, tt.expected, minutesToTime(tt.minutes), "minutesToTime should format HH:MM")
+	}
+}
+
+func TestGetTrackSummary(t *testing.T) {
+	for _, day := range []string{"", DayFormatAug9, DayFormatAug10} {
+		t.Run("day="+day, func(t *testing.T) {
+			tracks := GetTrackSummary(day)
+			testutil.AssertEqual(t, true, len(tracks) > 0, "Should return tracks")
+
+			for i, summary := range tracks {
+				testutil.AssertEqual(t, true, len(summary.Representatives) > 0, "Track "+summary.Track+" should have a representative")
+				testutil.AssertEqual(t, true, len(summary.Representatives) <= MaxTrackRepresentatives, "Representatives should be capped")
+				for _, session := range summary.Representatives {
+					testutil.AssertEqual(t, summary.Track, session.Track, "Representative should belong to its track")
+					testutil.AssertEqual(t, "", session.Abstract, "Representatives should be simplified")
+					if day != "" {
+						testutil.AssertEqual(t, day, session.Day, "Representative should be from the requested day")
+					}
+				}
+				if i > 0 {
+					testutil.AssertEqual(t, true, tracks[i-1].SessionCount >= summary.SessionCount, "Tracks should be ordered by session count")
+				}
+			}
+		})
+	}
+}
+
+func TestGroupSessionsByTag(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TAG-002", Start: "11:00", End: "11:30", Room: "AU", Tags: []string{"AI", "Security"}, Abstract: "long"},
+			{Code: "TAG-001", Start: "10:00", End: "10:30", Room: "TR211", Tags: []string{"AI"}},
+			{Code: "TAG-003", Start: "10:00", End: "10:30", Room: "TR212"},
+		},
+	})
+
+	groups := GroupSessionsByTag("Aug.9")
+
+	testutil.AssertEqual(t, 2, len(groups), "Untagged sessions should not form a group")
+	testutil.AssertEqual(t, "TAG-001,TAG-002", recommendationCodes(groups["AI"]), "AI sessions sorted by time")
+	testutil.AssertEqual(t, "TAG-002", recommendationCodes(groups["Security"]), "Two-tag session should also appear under its second tag")
+	testutil.AssertEqual(t, "", groups["AI"][1].Abstract, "Grouped sessions should be simplified")
+}
+
+func TestFindSessionsByTags(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TAGS-BOTH", Start: "11:00", End: "11:30", Room: "AU", Tags: []string{TagAI, TagLanguages}},
+			{Code: "TAGS-AI", Start: "10:00", End: "10:30", Room: "TR211", Tags: []string{TagAI}},
+			{Code: "TAGS-LANG", Start: "10:30", End: "11:00", Room: "TR212", Tags: []string{TagLanguages}},
+			{Code: "TAGS-SEC", Start: "09:00", End: "09:30", Room: "RB105", Tags: []string{TagSecurity}},
+			{Code: "TAGS-NONE", Start: "09:30", End: "10:00", Room: "RB101"},
+		},
+	})
+
+	tags := []string{"AI", "languages"}
+	testutil.AssertEqual(t, "TAGS-BOTH", recommendationCodes(FindSessionsByTags("Aug.9", tags, true)), "All should require every tag")
+	testutil.AssertEqual(t, "TAGS-AI,TAGS-LANG,TAGS-BOTH", recommendationCodes(FindSessionsByTags("Aug.9", tags, false)), "Any should accept either tag, sorted by time")
+	testutil.AssertEqual(t, "TAGS-AI,TAGS-BOTH", recommendationCodes(FindSessionsByTags("Aug.9", []string{TagAI}, true)), "Full tags with emoji should match too")
+	testutil.AssertEqual(t, 0, len(FindSessionsByTags("Aug.9", nil, false)), "No tags should match nothing")
+}
+
+func TestPickRepresentativeSessions(t *testing.T) {
+	sessions := []Session{
+		{Code: "REP-003", Title: "Afternoon Talk", Start: "14:00", Day: "Aug.9"},
+		{Code: "REP-002", Title: "Day Two Opener", Start: "09:00", Day: "Aug.10"},
+		{Code: "REP-001", Title: "Morning Talk", Start: "10:00", Day: "Aug.9"},
+		{Code: "REP-004", Title: "Closing Keynote", Start: "16:00", Day: "Aug.10"},
+	}
+
+	picked := pickRepresentativeSessions(sessions, 2)
+	testutil.AssertEqual(t, 2, len(picked), "Should pick two sessions")
+	testutil.AssertEqual(t, "REP-004", picked[0].Code, "Keynotes should come first")
+	testutil.AssertEqual(t, "REP-001", picked[1].Code, "Then the earliest session of the conference")
+	testutil.AssertEqual(t, "REP-003", sessions[0].Code, "Input should not be reordered")
+}
+
+func TestLoadSessionDataSetsStreamURL(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {
+			"AU":    {{Code: "STREAM-AU", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}},
+			"RB105": {{Code: "STREAM-RB", Start: "10:00", End: "10:30", Room: "RB105", Day: "Aug.9"}},
+			"TR211": {{Code: "STREAM-TR", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"}},
+		},
+	})
+
+	streamed := FindSessionByCode("STREAM-AU")
+	testutil.AssertEqual(t, streamed.URL, streamed.StreamURL, "Sessions in livestream rooms should get a stream URL")
+	keynoteHall := FindSessionByCode("STREAM-RB")
+	testutil.AssertEqual(t, keynoteHall.URL, keynoteHall.StreamURL, "Rooms are matched by their full name, not their building")
+	testutil.AssertEqual(t, "", FindSessionByCode("STREAM-TR").StreamURL, "Sessions in other rooms should not be streamed")
+}
+
+func TestSplitAbstractOutline(t *testing.T) {
+	abstract := "本議程介紹 Nix 生態系。\n\n大綱：\n1. Nix 程式設計語言\n2. 使用 Nix 打包軟體\n- 利用 Nix flakes 建立開發環境\n"
+
+	summary, outline, ok := splitAbstractOutline(abstract)
+	testutil.AssertEqual(t, true, ok, "Abstract with a list should be split")
+	testutil.AssertEqual(t, "本議程介紹 Nix 生態系。\n大綱：", summary, "Summary should keep the prose lines")
+	testutil.AssertEqual(t, 3, len(outline), "Outline should contain every list item")
+	testutil.AssertEqual(t, "Nix 程式設計語言", outline[0], "Numbered markers should be stripped")
+	testutil.AssertEqual(t, "利用 Nix flakes 建立開發環境", outline[2], "Bullet markers should be stripped")
+
+	plain := "這是一段沒有條列的摘要。\n只有 1 個數字。"
+	summary, outline, ok = splitAbstractOutline(plain)
+	testutil.AssertEqual(t, false, ok, "Abstract without a list should not be split")
+	testutil.AssertEqual(t, plain, summary, "Raw abstract should be returned as the fallback")
+	testutil.AssertEqual(t, 0, len(outline), "No outline without a list")
+}
@@ -11,4 +11,8 @@ var (
 	ErrSessionCodeRequired = errors.New("sessionCode is required")
 	ErrRoomRequired        = errors.New("room is required")
 	ErrCannotFindSession   = errors.New("cannot find specified session")
+	ErrInvalidSessionID    = errors.New("invalid session ID format")
+	ErrInvalidToken        = errors.New("invalid schedule token")
+	ErrInvalidICS          = errors.New("invalid iCalendar data")
+	ErrInvalidCOSCUPConfig = errors.New("invalid COSCUP dates")
 )
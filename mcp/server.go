@@ -1,14 +1,25 @@
 package mcp
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
@@ -40,6 +51,10 @@ func (s *COSCUPServer) Start() error {
 	// COSCUP data is automatically loaded via init() when the package loads
 	log.Println("COSCUP session data ready")
 
+	if err := loadCOSCUPConfig(); err != nil {
+		return err
+	}
+
 	// Create MCP server
 	s.mcpServer = server.NewMCPServer(
 		"COSCUP Schedule Planner",
@@ -53,14 +68,23 @@ func (s *COSCUPServer) Start() error {
 		return fmt.Errorf("failed to register tools: %w", err)
 	}
 
+	// Stop on SIGINT/SIGTERM instead of being killed mid-response
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start cleanup routine for old sessions
-	go s.startCleanupRoutine()
+	go s.startCleanupRoutine(ctx)
 
 	log.Println("COSCUP MCP Server is ready!")
 	log.Printf("Available tools: %s", getAvailableToolsList())
 
-	// Start serving (this will block)
-	return server.ServeStdio(s.mcpServer)
+	// Start serving (this will block until stdin closes or a signal arrives)
+	err := server.NewStdioServer(s.mcpServer).Listen(ctx, os.Stdin, os.Stdout)
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	logShutdown()
+	return err
 }
 
 // registerTools registers all MCP tools with their handlers
@@ -74,7 +98,7 @@ func (s *COSCUPServer) registerTools() error {
 			return fmt.Errorf("no handler found for tool: %s", toolName)
 		}
 
-		s.mcpServer.AddTool(tool, handler)
+		s.mcpServer.AddTool(tool, serverMetrics.instrumentTool(toolName, handler))
 		log.Printf("Registered tool: %s", toolName)
 	}
 
@@ -88,6 +112,10 @@ func (s *COSCUPServer) StartHTTP() error {
 	// COSCUP data is automatically loaded via init() when the package loads
 	log.Println("COSCUP session data ready")
 
+	if err := loadCOSCUPConfig(); err != nil {
+		return err
+	}
+
 	// Create MCP server
 	s.mcpServer = server.NewMCPServer(
 		"COSCUP Schedule Planner",
@@ -101,8 +129,12 @@ func (s *COSCUPServer) StartHTTP() error {
 		return fmt.Errorf("failed to register tools: %w", err)
 	}
 
+	// Stop on SIGINT/SIGTERM so deploys can drain in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start cleanup routine for old sessions
-	go s.startCleanupRoutine()
+	go s.startCleanupRoutine(ctx)
 
 	// Get port from environment variable
 	port := os.Getenv("PORT")
@@ -121,6 +153,12 @@ func (s *COSCUPServer) StartHTTP() error {
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/", s.healthHandler) // Also respond to root path
 
+	// Prometheus-compatible counters for operators
+	mux.HandleFunc("/metrics", s.metricsHandler)
+
+	// Organizer-only aggregate exports, enabled by setting ADMIN_TOKEN
+	mux.HandleFunc("/admin/popularity.csv", s.popularityCSVHandler)
+
 	// Create StreamableHTTP server with custom endpoint path
 	httpServer := server.NewStreamableHTTPServer(s.mcpServer,
 		server.WithEndpointPath("/mcp"),
@@ -132,7 +170,43 @@ func (s *COSCUPServer) StartHTTP() error {
 
 	// Start HTTP server
 	log.Printf("HTTP Server listening on :%s", port)
-	return http.ListenAndServe(":"+port, mux)
+	err := serveHTTP(ctx, &http.Server{Addr: ":" + port, Handler: mux})
+	logShutdown()
+	return err
+}
+
+// serveHTTP runs srv until ctx is cancelled, then stops accepting connections
+// and gives in-flight requests up to ShutdownTimeoutSeconds to finish
+func serveHTTP(ctx context.Context, srv *http.Server) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutdown signal received, draining HTTP connections...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeoutSeconds*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("HTTP server stopped")
+	return nil
+}
+
+// logShutdown reports the state being dropped on exit
+// Plans only live in memory, so they do not survive a restart
+func logShutdown() {
+	stats := GetSessionStats()
+	log.Printf("COSCUP MCP Server stopped with %v active sessions (in-memory plans are not persisted)", stats["active_sessions"])
 }
 
 // healthHandler provides a simple health check endpoint
@@ -143,6 +217,49 @@ func (s *COSCUPServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 
+// isAdminRequest checks the request's bearer token against ADMIN_TOKEN
+// Admin endpoints are disabled when ADMIN_TOKEN is not set
+func isAdminRequest(r *http.Request) bool {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		return false
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// popularityCSVHandler streams how many users planned each session as CSV, most planned first
+// Only aggregate counts are exported, never session IDs or individual schedules
+func (s *COSCUPServer) popularityCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	counts := AggregateSchedulePopularity()
+	sessions := slices.Clone(sessionData().all)
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if counts[sessions[i].Code] != counts[sessions[j].Code] {
+			return counts[sessions[i].Code] > counts[sessions[j].Code]
+		}
+		return sessions[i].Code < sessions[j].Code
+	})
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="popularity.csv"`)
+
+	// Rows are written straight to the response; csv.Writer flushes its small buffer as it fills
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"code", "title", "planned_count"})
+	for _, session := range sessions {
+		writer.Write([]string{session.Code, session.Title, fmt.Sprint(counts[session.Code])})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("[HTTP] Failed to write popularity CSV: %v", err)
+	}
+}
+
 // loggingMiddleware logs HTTP requests for debugging
 func (s *COSCUPServer) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -153,19 +270,178 @@ func (s *COSCUPServer) loggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 
 		duration := time.Since(start)
+		serverMetrics.observeRequest(duration)
 		log.Printf("[HTTP] %s %s completed in %v", r.Method, r.URL.Path, duration)
 	})
 }
 
+// loadCOSCUPConfig applies conference dates from the environment, see LoadCOSCUPConfigFromEnv
+// PROFILE_DECAY_HALF_LIFE_HOURS enables recency weighting of profile tracks, unset keeps decay disabled
+// STRICT_DAY_FORMAT=true rejects unrecognized days instead of passing them through, see SetStrictDayFormat
+// The Set* configuration functions and RegisterBuilding write package variables without locking,
+// so they are only called during startup: here and at the start of startCleanupRoutine
+func loadCOSCUPConfig() error {
+	config, err := LoadCOSCUPConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("failed to load conference dates: %w", err)
+	}
+	SetCOSCUPConfig(config)
+	log.Printf("Conference dates: %d-%02d-%02d and %d-%02d-%02d", config.Year, config.Month, config.Day1, config.Year, config.Month, config.Day2)
+	SetProfileDecayHalfLife(ttlHoursFromEnv("PROFILE_DECAY_HALF_LIFE_HOURS"))
+	SetStrictDayFormat(os.Getenv("STRICT_DAY_FORMAT") == "true")
+	return nil
+}
+
+// ttlHoursFromEnv reads a duration in hours, such as a session TTL, from an environment variable
+// Returns 0, i.e. keep the default, when the variable is unset or not a positive integer
+func ttlHoursFromEnv(name string) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	hours, err := strconv.Atoi(value)
+	if err != nil || hours <= 0 {
+		log.Printf("Ignoring invalid %s=%q, expected a positive number of hours", name, value)
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
 // startCleanupRoutine starts a background routine to cleanup old sessions
-func (s *COSCUPServer) startCleanupRoutine() {
+// TTLs can be overridden with SESSION_TTL_HOURS and COMPLETED_SESSION_TTL_HOURS
+// The routine returns once ctx is cancelled
+func (s *COSCUPServer) startCleanupRoutine(ctx context.Context) {
+	SetSessionTTLs(ttlHoursFromEnv("SESSION_TTL_HOURS"), ttlHoursFromEnv("COMPLETED_SESSION_TTL_HOURS"))
+
 	ticker := time.NewTicker(1 * time.Hour) // cleanup every hour
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Session cleanup routine stopped")
+			return
+		case <-ticker.C:
+		}
+
 		log.Println("Running session cleanup...")
 		CleanupOldSessions()
 		stats := GetSessionStats()
-		log.Printf("Active sessions: %v", stats["active_sessions"])
+		log.Printf("Active sessions: %v, schedule additions: %v, conflict rejections: %v",
+			stats["active_sessions"], stats["schedule_additions"], stats["conflict_rejections"])
 	}
 }
+
+// Upper bounds (seconds) of the request latency histogram buckets
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsRegistry holds the tool and request counters exposed on /metrics
+// Session counts are read from GetSessionStats when scraped, so they are not stored here
+type metricsRegistry struct {
+	mu            sync.Mutex
+	toolCalls     map[string]int64
+	toolErrors    map[string]int64
+	latencyCounts []int64 // Per bucket, not cumulative; the last entry counts requests above every bound
+	latencySum    float64
+	latencyTotal  int64
+}
+
+// serverMetrics is the process-wide registry filled by registered tools and loggingMiddleware
+var serverMetrics = newMetricsRegistry()
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		toolCalls:     make(map[string]int64),
+		toolErrors:    make(map[string]int64),
+		latencyCounts: make([]int64, len(latencyBuckets)+1),
+	}
+}
+
+// instrumentTool wraps a tool handler so its calls and failures are counted
+// Both Go errors and error results (IsError) count as failures
+func (m *metricsRegistry) instrumentTool(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+
+		m.mu.Lock()
+		m.toolCalls[name]++
+		if err != nil || (result != nil && result.IsError) {
+			m.toolErrors[name]++
+		}
+		m.mu.Unlock()
+
+		return result, err
+	}
+}
+
+// observeRequest records the duration of one HTTP request
+func (m *metricsRegistry) observeRequest(duration time.Duration) {
+	seconds := duration.Seconds()
+	bucket := sort.SearchFloat64s(latencyBuckets, seconds)
+
+	m.mu.Lock()
+	m.latencyCounts[bucket]++
+	m.latencySum += seconds
+	m.latencyTotal++
+	m.mu.Unlock()
+}
+
+// writeTo renders the registry plus live session stats in the Prometheus text format
+func (m *metricsRegistry) writeTo(w io.Writer) {
+	stats := GetSessionStats()
+
+	fmt.Fprintln(w, "# HELP coscup_active_sessions Planning sessions held in memory.")
+	fmt.Fprintln(w, "# TYPE coscup_active_sessions gauge")
+	fmt.Fprintf(w, "coscup_active_sessions %v\n", stats["active_sessions"])
+
+	fmt.Fprintln(w, "# HELP coscup_shard_sessions Planning sessions held in each shard.")
+	fmt.Fprintln(w, "# TYPE coscup_shard_sessions gauge")
+	for shard, count := range stats["shard_stats"].([]int) {
+		fmt.Fprintf(w, "coscup_shard_sessions{shard=\"%d\"} %d\n", shard, count)
+	}
+
+	fmt.Fprintln(w, "# HELP coscup_schedule_additions_total Sessions added to schedules.")
+	fmt.Fprintln(w, "# TYPE coscup_schedule_additions_total counter")
+	fmt.Fprintf(w, "coscup_schedule_additions_total %v\n", stats["schedule_additions"])
+
+	fmt.Fprintln(w, "# HELP coscup_conflict_rejections_total Sessions rejected for clashing with a schedule.")
+	fmt.Fprintln(w, "# TYPE coscup_conflict_rejections_total counter")
+	fmt.Fprintf(w, "coscup_conflict_rejections_total %v\n", stats["conflict_rejections"])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeToolCounter(w, "coscup_tool_calls_total", "Tool calls by tool name.", m.toolCalls)
+	writeToolCounter(w, "coscup_tool_errors_total", "Tool calls that returned an error, by tool name.", m.toolErrors)
+
+	fmt.Fprintln(w, "# HELP coscup_http_request_duration_seconds Latency of MCP HTTP requests.")
+	fmt.Fprintln(w, "# TYPE coscup_http_request_duration_seconds histogram")
+	var cumulative int64
+	for i, bound := range latencyBuckets {
+		cumulative += m.latencyCounts[i]
+		fmt.Fprintf(w, "coscup_http_request_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "coscup_http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.latencyTotal)
+	fmt.Fprintf(w, "coscup_http_request_duration_seconds_sum %s\n", strconv.FormatFloat(m.latencySum, 'g', -1, 64))
+	fmt.Fprintf(w, "coscup_http_request_duration_seconds_count %d\n", m.latencyTotal)
+}
+
+// writeToolCounter writes a counter labelled by tool name, sorted for stable output
+func writeToolCounter(w io.Writer, name, help string, counts map[string]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	tools := make([]string, 0, len(counts))
+	for tool := range counts {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		fmt.Fprintf(w, "%s{tool=\"%s\"} %d\n", name, tool, counts[tool])
+	}
+}
+
+// metricsHandler serves the registry in the Prometheus text exposition format
+func (s *COSCUPServer) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	serverMetrics.writeTo(w)
+}
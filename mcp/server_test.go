@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"mcp-coscup/mcp/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tests for functions in server.go
+
+func TestPopularityCSVHandler(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "POP-001", Title: "Quiet Talk", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "POP-002", Title: "Popular, Talk", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+		},
+	})
+	storeTestUserState(t, &UserState{SessionID: "test_popularity_a", Day: "Aug.9", Schedule: []Session{{Code: "POP-002"}}})
+	storeTestUserState(t, &UserState{SessionID: "test_popularity_b", Day: "Aug.9", Schedule: []Session{{Code: "POP-002"}, {Code: "POP-001", Cancelled: true}}})
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	s := NewCOSCUPServer()
+
+	t.Run("Requires admin token", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/admin/popularity.csv", nil)
+		request.Header.Set("Authorization", "Bearer wrong")
+		s.popularityCSVHandler(recorder, request)
+		testutil.AssertEqual(t, http.StatusUnauthorized, recorder.Code, "Wrong token should be rejected")
+	})
+
+	t.Run("Exports counts", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/admin/popularity.csv", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		s.popularityCSVHandler(recorder, request)
+		testutil.AssertEqual(t, http.StatusOK, recorder.Code, "Admin request should succeed")
+
+		rows, err := csv.NewReader(recorder.Body).ReadAll()
+		testutil.AssertNoError(t, err, "Response should be valid CSV")
+		testutil.AssertEqual(t, 3, len(rows), "Header plus one row per session")
+		testutil.AssertSliceEqual(t, []string{"code", "title", "planned_count"}, rows[0], "Header")
+		testutil.AssertSliceEqual(t, []string{"POP-002", "Popular, Talk", "2"}, rows[1], "Most planned session first")
+		testutil.AssertSliceEqual(t, []string{"POP-001", "Quiet Talk", "0"}, rows[2], "Cancelled entries should not count")
+	})
+}
+
+func TestTTLHoursFromEnv(t *testing.T) {
+	t.Setenv("TEST_TTL_HOURS", "48")
+	testutil.AssertEqual(t, 48*time.Hour, ttlHoursFromEnv("TEST_TTL_HOURS"), "Hours should be parsed")
+
+	t.Setenv("TEST_TTL_HOURS", "soon")
+	testutil.AssertEqual(t, time.Duration(0), ttlHoursFromEnv("TEST_TTL_HOURS"), "Invalid values keep the default")
+
+	testutil.AssertEqual(t, time.Duration(0), ttlHoursFromEnv("TEST_TTL_UNSET"), "Unset variables keep the default")
+}
+
+func TestLoadCOSCUPConfigProfileDecay(t *testing.T) {
+	t.Cleanup(func() { SetProfileDecayHalfLife(0) })
+
+	t.Setenv("PROFILE_DECAY_HALF_LIFE_HOURS", "6")
+	testutil.AssertEqual(t, nil, loadCOSCUPConfig(), "Config should load")
+	testutil.AssertEqual(t, 6*time.Hour, profileDecayHalfLife, "Half-life should come from the environment")
+
+	t.Setenv("PROFILE_DECAY_HALF_LIFE_HOURS", "")
+	testutil.AssertEqual(t, nil, loadCOSCUPConfig(), "Config should load")
+	testutil.AssertEqual(t, time.Duration(0), profileDecayHalfLife, "Unset variable should disable decay")
+}
+
+func TestServeHTTPStopsOnCancel(t *testing.T) {
+	logs := captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveHTTP(ctx, &http.Server{Addr: "127.0.0.1:0", Handler: http.NewServeMux()})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		testutil.AssertNoError(t, err, "A cancelled context should be an orderly shutdown")
+	case <-time.After(ShutdownTimeoutSeconds * time.Second):
+		t.Fatal("serveHTTP did not return after cancellation")
+	}
+	if !strings.Contains(logs.String(), "draining HTTP connections") {
+		t.Errorf("Shutdown should be logged, got %q", logs.String())
+	}
+}
+
+func TestServeHTTPReturnsListenError(t *testing.T) {
+	err := serveHTTP(context.Background(), &http.Server{Addr: "127.0.0.1:-1"})
+	testutil.AssertError(t, err, "An invalid address should fail to listen")
+}
+
+func TestCleanupRoutineStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		(&COSCUPServer{}).startCleanupRoutine(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Cleanup routine should return once the context is cancelled")
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	registry := newMetricsRegistry()
+	ok := registry.instrumentTool("get_options", func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	failing := registry.instrumentTool("choose_session", func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("bad"), nil
+	})
+	ok(context.Background(), mcp.CallToolRequest{})
+	ok(context.Background(), mcp.CallToolRequest{})
+	failing(context.Background(), mcp.CallToolRequest{})
+	registry.observeRequest(20 * time.Millisecond)
+	registry.observeRequest(3 * time.Second)
+
+	var out strings.Builder
+	registry.writeTo(&out)
+	metrics := out.String()
+
+	for _, line := range []string{
+		`coscup_tool_calls_total{tool="choose_session"} 1`,
+		`coscup_tool_calls_total{tool="get_options"} 2`,
+		`coscup_tool_errors_total{tool="choose_session"} 1`,
+		`coscup_http_request_duration_seconds_bucket{le="0.01"} 0`,
+		`coscup_http_request_duration_seconds_bucket{le="0.025"} 1`,
+		`coscup_http_request_duration_seconds_bucket{le="5"} 2`,
+		`coscup_http_request_duration_seconds_bucket{le="+Inf"} 2`,
+		`coscup_http_request_duration_seconds_count 2`,
+		fmt.Sprintf(`coscup_shard_sessions{shard="%d"}`, NumShards-1),
+		"coscup_active_sessions ",
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("Metrics should contain %q, got:\n%s", line, metrics)
+		}
+	}
+	if strings.Contains(metrics, `coscup_tool_errors_total{tool="get_options"}`) {
+		t.Error("Tools without errors should not get an error series")
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	NewCOSCUPServer().metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	testutil.AssertEqual(t, http.StatusOK, recorder.Code, "Metrics should be public")
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Metrics should be served as text, got %q", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), "# TYPE coscup_active_sessions gauge") {
+		t.Errorf("Metrics should include the session gauge, got:\n%s", recorder.Body.String())
+	}
+}
@@ -1,28 +1,44 @@
 package mcp
 
 import (
+	"bufio"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"log"
+	"maps"
+	"math"
+	mathrand "math/rand/v2"
+	"os"
+	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // UserState represents the planning state for a user session
 type UserState struct {
-	SessionID    string    `json:"session_id"`
-	Day          string    `json:"day"`           // "Aug.9" or "Aug.10"
-	Schedule     []Session `json:"schedule"`      // selected sessions
-	LastEndTime  string    `json:"last_end_time"` // end time of last selected session
-	Profile      []string  `json:"profile"`       // interested tracks
-	IsCompleted  bool      `json:"is_completed"`  // user manually finished planning
-	CreatedAt    time.Time `json:"created_at"`
-	LastActivity time.Time `json:"last_activity"`
+	SessionID      string               `json:"session_id"`
+	Day            string               `json:"day"`                        // "Aug.9" or "Aug.10"
+	Schedule       []Session            `json:"schedule"`                   // selected sessions
+	LastEndTime    string               `json:"last_end_time"`              // end time of last selected session
+	Profile        []string             `json:"profile"`                    // interested tracks
+	ProfileAddedAt map[string]time.Time `json:"profile_added_at,omitempty"` // when each profile track was last chosen
+	IsCompleted    bool                 `json:"is_completed"`               // user manually finished planning
+	Locked         map[string]bool      `json:"locked,omitempty"`           // session codes the optimizer must keep
+	AutoFinish     bool                 `json:"auto_finish,omitempty"`      // opted in to finishing automatically once the day is complete
+	OfferedAt      int                  `json:"offered_at,omitempty"`       // schedule size when more planning was last offered, 0 if never
+	Mobility       Mobility             `json:"mobility,omitempty"`         // walking pace, empty means normal
+	Favorites      []string             `json:"favorites,omitempty"`        // session codes starred without committing to them
+	CreatedAt      time.Time            `json:"created_at"`
+	LastActivity   time.Time            `json:"last_activity"`
 }
 
 // Response represents the standard MCP tool response
@@ -53,6 +69,30 @@ const NumShards = DefaultNumShards
 type SessionShard struct {
 	mu       sync.RWMutex
 	sessions map[string]*UserState
+
+	// Approximate lock contention counters, exposed via GetSessionStats
+	acquisitions atomic.Int64 // Lock and RLock calls made through lock/rlock
+	contended    atomic.Int64 // Acquisitions that had to wait because the lock was held
+}
+
+// lock takes the shard's write lock, counting whether it had to wait
+func (s *SessionShard) lock() {
+	s.acquisitions.Add(1)
+	if s.mu.TryLock() {
+		return
+	}
+	s.contended.Add(1)
+	s.mu.Lock()
+}
+
+// rlock takes the shard's read lock, counting whether it had to wait
+func (s *SessionShard) rlock() {
+	s.acquisitions.Add(1)
+	if s.mu.TryRLock() {
+		return
+	}
+	s.contended.Add(1)
+	s.mu.RLock()
 }
 
 // Global sharded storage
@@ -74,6 +114,25 @@ func getShardIndex(sessionID string) int {
 	return int(hash.Sum32() % NumShards)
 }
 
+// sessionIDDayCodes maps user day format to the day code embedded in session IDs
+var sessionIDDayCodes = map[string]string{DayAug9: "09", DayAug10: "10"}
+
+// ParseDayFromSessionID extracts the planning day (user format, e.g. "Aug9") from a session ID
+// Session IDs look like "user_09_<timestamp>_<random>", so the day survives even if the session expired
+func ParseDayFromSessionID(sessionID string) (string, error) {
+	parts := strings.Split(sessionID, "_")
+	if len(parts) < 4 || parts[0] != "user" {
+		return "", ErrInvalidSessionID
+	}
+
+	for day, code := range sessionIDDayCodes {
+		if parts[1] == code {
+			return day, nil
+		}
+	}
+	return "", ErrInvalidSessionID
+}
+
 // GenerateSecureSessionID creates a cryptographically secure session ID
 func GenerateSecureSessionID(day string) string {
 	// Generate 8 random bytes
@@ -100,7 +159,7 @@ func GenerateSessionIDWithCollisionCheck(day string) string {
 		shardIndex := getShardIndex(sessionID)
 		shard := sessionShards[shardIndex]
 
-		shard.mu.RLock()
+		shard.rlock()
 		_, exists := shard.sessions[sessionID]
 		shard.mu.RUnlock()
 
@@ -121,18 +180,18 @@ func CreateUserState(sessionID, day string) *UserState {
 	shardIndex := getShardIndex(sessionID)
 	shard := sessionShards[shardIndex]
 
-	shard.mu.Lock()
+	shard.lock()
 	defer shard.mu.Unlock()
 
 	state := &UserState{
 		SessionID:    sessionID,
 		Day:          day,
 		Schedule:     make([]Session, 0),
-		LastEndTime:  "08:00", // start from early morning
+		LastEndTime:  PlanningStartTime, // start from early morning
 		Profile:      make([]string, 0),
 		IsCompleted:  false, // planning not finished yet
-		CreatedAt:    time.Now(),
-		LastActivity: time.Now(),
+		CreatedAt:    conferenceNow(),
+		LastActivity: conferenceNow(),
 	}
 
 	shard.sessions[sessionID] = state
@@ -146,12 +205,13 @@ func GetUserState(sessionID string) *UserState {
 	shardIndex := getShardIndex(sessionID)
 	shard := sessionShards[shardIndex]
 
-	shard.mu.RLock()
-	defer shard.mu.RUnlock()
+	// Write lock: accessing a session updates its last activity
+	shard.lock()
+	defer shard.mu.Unlock()
 
 	if state, exists := shard.sessions[sessionID]; exists {
 		// Update last activity
-		state.LastActivity = time.Now()
+		state.LastActivity = conferenceNow()
 		log.Printf("[%s] Session accessed, last activity updated", sessionID)
 		return state
 	}
@@ -159,12 +219,42 @@ func GetUserState(sessionID string) *UserState {
 	return nil
 }
 
+// GetUserStateSnapshot returns a copy of the user state taken under the shard lock
+// Use this for read-only analysis so concurrent schedule updates can't race with the reader
+func GetUserStateSnapshot(sessionID string) *UserState {
+	shardIndex := getShardIndex(sessionID)
+	shard := sessionShards[shardIndex]
+
+	shard.lock()
+	defer shard.mu.Unlock()
+
+	state, exists := shard.sessions[sessionID]
+	if !exists {
+		log.Printf("[%s] Session not found", sessionID)
+		return nil
+	}
+
+	state.LastActivity = conferenceNow()
+	return state.snapshot()
+}
+
+// snapshot copies the state so that its slices don't alias the stored state
+func (s *UserState) snapshot() *UserState {
+	copied := *s
+	copied.Schedule = slices.Clone(s.Schedule)
+	copied.Profile = slices.Clone(s.Profile)
+	copied.Favorites = slices.Clone(s.Favorites)
+	copied.Locked = maps.Clone(s.Locked)
+	copied.ProfileAddedAt = maps.Clone(s.ProfileAddedAt)
+	return &copied
+}
+
 // UpdateUserState updates the user state
 func UpdateUserState(sessionID string, updater func(*UserState)) error {
 	shardIndex := getShardIndex(sessionID)
 	shard := sessionShards[shardIndex]
 
-	shard.mu.Lock()
+	shard.lock()
 	defer shard.mu.Unlock()
 
 	state, exists := shard.sessions[sessionID]
@@ -173,28 +263,308 @@ func UpdateUserState(sessionID string, updater func(*UserState)) error {
 	}
 
 	updater(state)
-	state.LastActivity = time.Now()
+	state.LastActivity = conferenceNow()
 	return nil
 }
 
+// scheduleTokenPrefix versions the schedule token format
+const scheduleTokenPrefix = "v1."
+
+// EncodeScheduleToken encodes a day's schedule as a shareable token of its session codes
+func EncodeScheduleToken(day string, schedule []Session) string {
+	codes := make([]string, len(schedule))
+	for i, session := range schedule {
+		codes[i] = session.Code
+	}
+	payload := day + ":" + strings.Join(codes, ",")
+	return scheduleTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(payload))
+}
+
+// decodeScheduleToken returns the day and session codes stored in a schedule token
+func decodeScheduleToken(token string) (string, []string, error) {
+	encoded, found := strings.CutPrefix(strings.TrimSpace(token), scheduleTokenPrefix)
+	if !found {
+		return "", nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, ErrInvalidToken
+	}
+
+	day, codeList, found := strings.Cut(string(payload), ":")
+	if !found || (day != DayFormatAug9 && day != DayFormatAug10) {
+		return "", nil, ErrInvalidToken
+	}
+	if codeList == "" {
+		return day, nil, nil
+	}
+	return day, strings.Split(codeList, ","), nil
+}
+
+// icsTimeLayout is the iCalendar local date-time format used with TZID
+const icsTimeLayout = "20060102T150405"
+
+// icsEscaper escapes iCalendar TEXT values
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICSTimezone writes the VTIMEZONE component that RFC 5545 requires for the TZID used by events
+// The conference timezone has a fixed offset without daylight saving time, so a single STANDARD rule is exact
+func writeICSTimezone(ics *strings.Builder) {
+	offset := fmt.Sprintf("+%02d%02d", ConferenceUTCOffsetSecs/3600, ConferenceUTCOffsetSecs%3600/60)
+	ics.WriteString("BEGIN:VTIMEZONE\r\n")
+	fmt.Fprintf(ics, "TZID:%s\r\n", ConferenceTimezone)
+	ics.WriteString("BEGIN:STANDARD\r\nDTSTART:19700101T000000\r\n")
+	fmt.Fprintf(ics, "TZOFFSETFROM:%s\r\nTZOFFSETTO:%s\r\n", offset, offset)
+	ics.WriteString("TZNAME:CST\r\nEND:STANDARD\r\nEND:VTIMEZONE\r\n")
+}
+
+// ExportScheduleICS renders a day's schedule as an iCalendar file
+// Each event carries its session code in X-COSCUP-CODE so ImportScheduleICS can recover it
+func ExportScheduleICS(day string, schedule []Session) string {
+	midnight := dayMidnight(day)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	var ics strings.Builder
+	ics.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//COSCUP MCP//Schedule Planner//ZH\r\n")
+	writeICSTimezone(&ics)
+	for _, session := range schedule {
+		if session.Cancelled {
+			continue
+		}
+		start := midnight.Add(time.Duration(timeToMinutes(session.Start)) * time.Minute)
+		end := midnight.Add(time.Duration(endTimeToMinutes(session.Start, session.End)) * time.Minute)
+
+		ics.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&ics, "UID:%s-%s@coscup-mcp\r\n", session.Code, start.Format("20060102"))
+		fmt.Fprintf(&ics, "DTSTAMP:%s\r\n", stamp)
+		fmt.Fprintf(&ics, "DTSTART;TZID=%s:%s\r\n", ConferenceTimezone, start.Format(icsTimeLayout))
+		fmt.Fprintf(&ics, "DTEND;TZID=%s:%s\r\n", ConferenceTimezone, end.Format(icsTimeLayout))
+		fmt.Fprintf(&ics, "SUMMARY:%s\r\n", icsEscaper.Replace(session.Title))
+		fmt.Fprintf(&ics, "LOCATION:%s\r\n", icsEscaper.Replace(session.Room))
+		if session.URL != "" {
+			fmt.Fprintf(&ics, "URL:%s\r\n", session.URL)
+		}
+		fmt.Fprintf(&ics, "X-COSCUP-CODE:%s\r\n", session.Code)
+		ics.WriteString("END:VEVENT\r\n")
+	}
+	ics.WriteString("END:VCALENDAR\r\n")
+	return ics.String()
+}
+
+// ImportScheduleICS reads an iCalendar file and returns the session codes of its events on the given internal day
+// Events are matched by their X-COSCUP-CODE, falling back to start time and room; events matching no session
+// (personal appointments, other days) are skipped
+func ImportScheduleICS(day string, r io.Reader) ([]string, error) {
+	events, err := parseICSEvents(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	seen := make(map[string]bool)
+	for _, event := range events {
+		code := matchICSEvent(day, event)
+		if code == "" {
+			log.Printf("Skipping iCalendar event %q: no matching session on %s", event["SUMMARY"], day)
+			continue
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// parseICSEvents returns the properties of each VEVENT, keyed by property name without parameters
+func parseICSEvents(r io.Reader) ([]map[string]string, error) {
+	// Unfold continuation lines first (RFC 5545: a line starting with a space or tab continues the previous one)
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0] != "BEGIN:VCALENDAR" {
+		return nil, ErrInvalidICS
+	}
+
+	var events []map[string]string
+	var current map[string]string
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			current = make(map[string]string)
+			continue
+		case "END:VEVENT":
+			if current != nil {
+				events = append(events, current)
+			}
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		nameAndParams, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		name, params, _ := strings.Cut(nameAndParams, ";")
+		current[strings.ToUpper(name)] = value
+		if tzid, ok := strings.CutPrefix(params, "TZID="); ok {
+			current[strings.ToUpper(name)+";TZID"] = tzid
+		}
+	}
+	return events, nil
+}
+
+// matchICSEvent returns the code of the session an event refers to, or "" if none
+func matchICSEvent(day string, event map[string]string) string {
+	if code := event["X-COSCUP-CODE"]; code != "" {
+		if session := FindSessionByCode(code); session != nil && session.Day == day {
+			return code
+		}
+	}
+
+	start, ok := parseICSTime(event["DTSTART"], event["DTSTART;TZID"])
+	if !ok || !sameDate(start, dayMidnight(day)) {
+		return ""
+	}
+	startTime := formatTimeForSession(start)
+	room := strings.NewReplacer(`\,`, ",", `\;`, ";", `\\`, `\`).Replace(event["LOCATION"])
+
+	for _, session := range sessionData().byDay[day] {
+		if session.Start == startTime && session.Room == room {
+			return session.Code
+		}
+	}
+	return ""
+}
+
+// parseICSTime parses an iCalendar DATE-TIME in UTC ("...Z"), with a TZID, or floating (conference time)
+func parseICSTime(value, tzid string) (time.Time, bool) {
+	location := conferenceLocation()
+	if strings.HasSuffix(value, "Z") {
+		value, location = strings.TrimSuffix(value, "Z"), time.UTC
+	} else if tzid != "" && tzid != ConferenceTimezone {
+		loaded, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false
+		}
+		location = loaded
+	}
+
+	t, err := time.ParseInLocation(icsTimeLayout, value, location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(conferenceLocation()), true
+}
+
+// sameDate reports whether two times fall on the same calendar date in the conference timezone
+func sameDate(a, b time.Time) bool {
+	a, b = a.In(conferenceLocation()), b.In(conferenceLocation())
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// PreviewScheduleToken resolves the sessions in a schedule token without creating or changing any session
+// Codes that no longer exist in the dataset are returned with only Code set and Cancelled flagged
+func PreviewScheduleToken(token string) ([]Session, error) {
+	_, codes, err := decodeScheduleToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := make([]Session, 0, len(codes))
+	for _, code := range codes {
+		if session := FindSessionByCode(code); session != nil {
+			preview = append(preview, *session)
+		} else {
+			preview = append(preview, Session{Code: code, Cancelled: true})
+		}
+	}
+	return preview, nil
+}
+
+// Schedule update counters, exposed via GetSessionStats
+// A high conflict ratio may mean the recommender is offering overlapping options
+var (
+	scheduleAdditions  atomic.Int64
+	conflictRejections atomic.Int64
+)
+
 // AddSessionToSchedule adds a selected session to user's schedule
 func AddSessionToSchedule(sessionID, sessionCode string) error {
-	session := FindSessionByCode(sessionCode)
+	_, err := ScheduleSession(sessionID, sessionCode)
+	return err
+}
+
+// ScheduleSession adds a selected session to user's schedule and returns the session actually added
+// If the session conflicts but the same talk is repeated in another timeslot that fits,
+// that instance is added instead
+// An unknown sessionID wraps ErrSessionNotFound, so callers can tell it apart from an unknown sessionCode
+func ScheduleSession(sessionID, sessionCode string) (*Session, error) {
+	found := FindSessionByCode(sessionCode)
+
+	// The day, conflict and repeat checks run under the shard lock together with the append,
+	// so concurrent choices can't both pass the checks and add overlapping sessions
+	var session *Session
+	var addErr error
+	err := UpdateUserState(sessionID, func(state *UserState) {
+		session, addErr = addSessionLocked(state, sessionID, sessionCode, found)
+	})
+	// Checked first so a never-started plan is reported as such
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	if addErr != nil {
+		return nil, addErr
+	}
+	return session, nil
+}
+
+// addSessionLocked checks found (the session looked up for sessionCode) against the user's day and schedule
+// and appends it, or a repeat instance that fits; the caller must hold the shard lock of state
+func addSessionLocked(state *UserState, sessionID, sessionCode string, found *Session) (*Session, error) {
+	session := found
 	if session == nil {
 		log.Printf("[%s] Failed to add session %s - session not found", sessionID, sessionCode)
-		return fmt.Errorf("session %s not found", sessionCode)
+		return nil, fmt.Errorf("session %s not found", sessionCode)
 	}
 
-	// Get current user state to check for conflicts
-	state := GetUserState(sessionID)
-	if state == nil {
-		return fmt.Errorf("session %s not found", sessionID)
+	// Sessions are looked up across both days, so reject picks from the other day
+	if session.Day != state.Day {
+		log.Printf("[%s] Rejected session %s from %s while planning %s",
+			sessionID, sessionCode, session.Day, state.Day)
+		return nil, fmt.Errorf("日期不符：議程 %s「%s」是 %s 的議程，但您目前正在規劃 %s 的行程。請選擇 %s 的議程，或使用 start_planning 另外規劃 %s",
+			sessionCode, session.Title, session.Day, state.Day, state.Day, session.Day)
+	}
+
+	// Cancelled entries no longer take up time, so they don't block their replacement
+	active := activeSessions(state.Schedule)
+
+	// A repeated talk may fit in another timeslot
+	if hasConflictWithSchedule(*session, active) {
+		if repeat := findRepeatInstance(*session, active); repeat != nil {
+			log.Printf("[%s] Session %s conflicts, using repeat instance %s (%s-%s) instead",
+				sessionID, sessionCode, repeat.Code, repeat.Start, repeat.End)
+			session = repeat
+		}
 	}
 
 	// Check for time conflicts with existing schedule
-	if hasConflictWithSchedule(*session, state.Schedule) {
+	if hasConflictWithSchedule(*session, active) {
 		// Find the conflicting session(s)
-		conflictingSessions := findConflictingSessions(*session, state.Schedule)
+		conflictingSessions := findConflictingSessions(*session, active)
 		conflictList := ""
 		for i, conflict := range conflictingSessions {
 			if i > 0 {
@@ -203,49 +573,93 @@ func AddSessionToSchedule(sessionID, sessionCode string) error {
 			conflictList += fmt.Sprintf("%s-%s %s", conflict.Start, conflict.End, conflict.Title)
 		}
 
+		conflictRejections.Add(1)
 		log.Printf("[%s] Time conflict detected for session %s (%s-%s)",
 			sessionID, sessionCode, session.Start, session.End)
-		return fmt.Errorf("時間衝突：您選擇的議程 %s-%s「%s」與已安排的議程重疊：%s。請選擇其他時段的議程",
+		return nil, fmt.Errorf("時間衝突：您選擇的議程 %s-%s「%s」與已安排的議程重疊：%s。請選擇其他時段的議程",
 			session.Start, session.End, session.Title, conflictList)
 	}
 
-	log.Printf("[%s] Adding session %s (%s) to schedule", sessionID, sessionCode, session.Title)
+	log.Printf("[%s] Adding session %s (%s) to schedule", sessionID, session.Code, session.Title)
 
-	return UpdateUserState(sessionID, func(state *UserState) {
-		// Add to schedule
-		state.Schedule = append(state.Schedule, *session)
+	// Add to schedule
+	state.Schedule = append(state.Schedule, *session)
+	scheduleAdditions.Add(1)
 
-		// Update last end time (only if this session ends later)
-		if timeToMinutes(session.End) > timeToMinutes(state.LastEndTime) {
-			state.LastEndTime = session.End
-		}
+	// Update last end time (only if this session ends later); a session past midnight ends the day at 23:59
+	if endMinutes := endTimeToMinutes(session.Start, session.End); endMinutes > timeToMinutes(state.LastEndTime) {
+		state.LastEndTime = minutesToTime(endMinutes)
+	}
 
-		// Update profile based on the selected track
-		addToProfile(state, session.Track)
+	// Update profile based on the selected track
+	addToProfile(state, session.Track)
 
-		log.Printf("[%s] Session added successfully. Schedule size: %d, End time: %s",
-			sessionID, len(state.Schedule), session.End)
-	})
+	log.Printf("[%s] Session added successfully. Schedule size: %d, End time: %s",
+		sessionID, len(state.Schedule), session.End)
+	return session, nil
+}
+
+// findRepeatInstance returns the instance of a repeated talk (same title, same day, different code)
+// that fits the schedule, choosing the one closest in time to the requested instance
+func findRepeatInstance(session Session, schedule []Session) *Session {
+	if session.Title == "" {
+		return nil
+	}
+
+	var best *Session
+	bestDistance := 0
+	for _, candidate := range sessionData().byDay[session.Day] {
+		if candidate.Title != session.Title || candidate.Code == session.Code || hasConflictWithSchedule(candidate, schedule) {
+			continue
+		}
+		distance := timeToMinutes(candidate.Start) - timeToMinutes(session.Start)
+		if distance < 0 {
+			distance = -distance
+		}
+		if best == nil || distance < bestDistance {
+			repeat := candidate
+			best, bestDistance = &repeat, distance
+		}
+	}
+	return best
 }
 
 // addToProfile adds a track to user's profile if not already present
+// and records when it was last chosen for recency weighting
 func addToProfile(state *UserState, track string) {
+	if state.ProfileAddedAt == nil {
+		state.ProfileAddedAt = make(map[string]time.Time)
+	}
+	state.ProfileAddedAt[track] = conferenceNow()
+
 	if slices.Contains(state.Profile, track) {
 		return // already in profile
 	}
 	state.Profile = append(state.Profile, track)
 }
 
-// sortSessionsByStartTime sorts sessions by start time using efficient sort.Slice
+// sortSessionsByStartTime sorts sessions by start time, then room, then code, so equal start times order deterministically
 func sortSessionsByStartTime(sessions []Session) {
 	sort.Slice(sessions, func(i, j int) bool {
-		return timeToMinutes(sessions[i].Start) < timeToMinutes(sessions[j].Start)
+		return sessionStartsBefore(sessions[i], sessions[j])
 	})
 }
 
+// sessionStartsBefore orders sessions by start time, breaking ties by room and then code
+func sessionStartsBefore(a, b Session) bool {
+	startA, startB := timeToMinutes(a.Start), timeToMinutes(b.Start)
+	if startA != startB {
+		return startA < startB
+	}
+	if a.Room != b.Room {
+		return a.Room < b.Room
+	}
+	return a.Code < b.Code
+}
+
 // getSimplifiedSessions creates safe copies of sessions and clears fields not needed for list display
 func getSimplifiedSessions(sessions []Session) []Session {
-	// Create safe copies since sessionsByDay is global data - avoid modifying original sessions
+	// Create safe copies since the session index is shared - avoid modifying original sessions
 	result := make([]Session, len(sessions))
 	for i, session := range sessions {
 		result[i] = session
@@ -255,111 +669,831 @@ func getSimplifiedSessions(sessions []Session) []Session {
 	return result
 }
 
-// FinishPlanning marks user's planning as completed
-func FinishPlanning(sessionID string) error {
-	return UpdateUserState(sessionID, func(state *UserState) {
-		state.IsCompleted = true
-		log.Printf("[%s] User manually finished planning with %d sessions",
-			sessionID, len(state.Schedule))
+// countSessionLanguages counts sessions per spoken language, so the client can see at a glance which languages
+// are on offer; sessions without a language are counted as UnknownLanguage
+func countSessionLanguages(sessions []Session) map[string]int {
+	counts := make(map[string]int)
+	for _, session := range sessions {
+		language := session.Language
+		if language == "" {
+			language = UnknownLanguage
+		}
+		counts[language]++
+	}
+	return counts
+}
+
+// LockSession locks (or unlocks) a scheduled session so OptimizeSchedule keeps it as a fixed point
+func LockSession(sessionID, sessionCode string, locked bool) error {
+	var inSchedule bool
+	err := UpdateUserState(sessionID, func(state *UserState) {
+		inSchedule = slices.ContainsFunc(state.Schedule, func(s Session) bool { return s.Code == sessionCode })
+		if !inSchedule {
+			return
+		}
+
+		if locked {
+			if state.Locked == nil {
+				state.Locked = make(map[string]bool)
+			}
+			state.Locked[sessionCode] = true
+		} else {
+			delete(state.Locked, sessionCode)
+		}
+		log.Printf("[%s] Session %s locked: %v", sessionID, sessionCode, locked)
 	})
+	if err != nil {
+		return err
+	}
+	if !inSchedule {
+		return fmt.Errorf("session %s is not in your schedule", sessionCode)
+	}
+	return nil
 }
 
-// FindNextAvailableInEachRoom finds next available session in each room after given time
-func FindNextAvailableInEachRoom(day, afterTime string, userSchedule []Session) []Session {
+// OptimizeSchedule proposes a full-day plan built around the user's locked sessions
+// Locked sessions are fixed points; every other slot is filled greedily with the earliest
+// available session, preferring tracks in the user's profile and then staying in the same building.
+// The stored schedule is not modified.
+func OptimizeSchedule(sessionID string) ([]Session, error) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
 
-	// Group sessions by room
-	roomSessions := make(map[string][]Session)
-	for _, session := range sessionsByDay[day] {
-		roomSessions[session.Room] = append(roomSessions[session.Room], session)
+	var plan []Session
+	for _, session := range state.Schedule {
+		if state.Locked[session.Code] {
+			plan = append(plan, session)
+		}
 	}
 
-	var nextSessions []Session
-	afterMinutes := timeToMinutes(afterTime)
+	cursor := "00:00"
+	for {
+		candidates := filterOutSocialActivities(FindNextAvailableInEachRoom(state.Day, cursor, plan))
+		if len(candidates) == 0 {
+			break
+		}
 
-	// Find next available session in each room
-	for _, sessions := range roomSessions {
+		previous := findLastSessionEndingBy(plan, cursor)
+		best := pickOptimizerCandidate(candidates, state.Profile, previous)
+		plan = append(plan, best)
+		cursor = best.End
+	}
 
-		// Sort sessions in this room by start time
-		roomSessionsSorted := make([]Session, len(sessions))
-		copy(roomSessionsSorted, sessions)
-		sortSessionsByStartTime(roomSessionsSorted)
+	sortSessionsByStartTime(plan)
+	return plan, nil
+}
 
-		// Find the first available session in this room
-		for _, session := range roomSessionsSorted {
-			startMinutes := timeToMinutes(session.Start)
+// findLastSessionEndingBy returns the session of the plan that ends last, no later than the given time
+func findLastSessionEndingBy(plan []Session, endTime string) *Session {
+	var last *Session
+	for i := range plan {
+		if endTimeToMinutes(plan[i].Start, plan[i].End) > timeToMinutes(endTime) {
+			continue
+		}
+		if last == nil || endTimeToMinutes(plan[i].Start, plan[i].End) > endTimeToMinutes(last.Start, last.End) {
+			last = &plan[i]
+		}
+	}
+	return last
+}
 
-			// Must start after afterTime
-			if startMinutes >= afterMinutes {
-				// Check if it conflicts with user schedule
-				if !hasConflictWithSchedule(session, userSchedule) {
-					nextSessions = append(nextSessions, session)
-					break // Found the next available session for this room
-				}
-				// If it conflicts, continue to check the next session in this room
+// GenerateStarterPlan suggests a plan for first-time attendees of an internal day without needing a profile:
+// the day's keynotes, then the earliest sessions that fit, preferring tracks not yet in the plan, bigger tracks
+// and staying in the same building. Lunch is left free and every transfer is walkable in time
+func GenerateStarterPlan(day string) []Session {
+	sessions := getSimplifiedSessions(filterOutSocialActivities(sessionData().byDay[day]))
+	sortSessionsByStartTime(sessions)
+
+	trackSizes := make(map[string]int)
+	for _, summary := range GetTrackSummary(day) {
+		trackSizes[summary.Track] = summary.SessionCount
+	}
+
+	var plan []Session
+	fits := func(candidate Session) bool {
+		if hasConflictWithSchedule(candidate, plan) {
+			return false
+		}
+		for _, planned := range plan {
+			if timeToMinutes(planned.Start) < timeToMinutes(candidate.Start) && !transferFits(planned, candidate, MobilityNormal) {
+				return false
+			}
+			if timeToMinutes(planned.Start) > timeToMinutes(candidate.Start) && !transferFits(candidate, planned, MobilityNormal) {
+				return false
 			}
 		}
+		return true
 	}
 
-	return getSimplifiedSessions(nextSessions)
-}
+	for _, session := range sessions {
+		if strings.Contains(strings.ToLower(session.Title), "keynote") && fits(session) {
+			plan = append(plan, session)
+		}
+	}
 
-// hasConflictWithSchedule checks if session conflicts with user's existing schedule
-func hasConflictWithSchedule(session Session, userSchedule []Session) bool {
-	for _, scheduled := range userSchedule {
-		if hasTimeConflict(session.Start, session.End, scheduled.Start, scheduled.End) {
-			return true
+	for len(plan) < MaxStarterPlanSessions {
+		usedTracks := make(map[string]bool)
+		for _, planned := range plan {
+			usedTracks[planned.Track] = true
+		}
+
+		var best *Session
+		for i, candidate := range sessions {
+			if isLunchBreak(candidate.Start, candidate.End) || !fits(candidate) {
+				continue
+			}
+			if best != nil && timeToMinutes(candidate.Start) > timeToMinutes(best.Start) {
+				break
+			}
+			if best == nil || starterPlanPrefers(candidate, *best, usedTracks, trackSizes, findLastSessionEndingBy(plan, candidate.Start)) {
+				best = &sessions[i]
+			}
 		}
+		if best == nil {
+			break
+		}
+		plan = append(plan, *best)
 	}
-	return false
+
+	sortSessionsByStartTime(plan)
+	return plan
 }
 
-// findConflictingSessions returns all sessions that conflict with the given session
-func findConflictingSessions(session Session, userSchedule []Session) []Session {
-	var conflicts []Session
-	for _, scheduled := range userSchedule {
-		if hasTimeConflict(session.Start, session.End, scheduled.Start, scheduled.End) {
-			conflicts = append(conflicts, scheduled)
+// starterPlanPrefers reports whether a is a better starter plan pick than b when both start at the same time
+func starterPlanPrefers(a, b Session, usedTracks map[string]bool, trackSizes map[string]int, previous *Session) bool {
+	if usedTracks[a.Track] != usedTracks[b.Track] {
+		return !usedTracks[a.Track]
+	}
+	if trackSizes[a.Track] != trackSizes[b.Track] {
+		return trackSizes[a.Track] > trackSizes[b.Track]
+	}
+	if previous != nil {
+		building := getBuildingFromRoom(previous.Room)
+		sameA, sameB := getBuildingFromRoom(a.Room) == building, getBuildingFromRoom(b.Room) == building
+		if sameA != sameB {
+			return sameA
 		}
 	}
-	return conflicts
+	return a.Code < b.Code
 }
 
-// hasTimeConflict checks if two time periods overlap
-func hasTimeConflict(start1, end1, start2, end2 string) bool {
-	start1Min := timeToMinutes(start1)
-	end1Min := timeToMinutes(end1)
-	start2Min := timeToMinutes(start2)
-	end2Min := timeToMinutes(end2)
-
-	// Two time periods overlap if:
-	// session1 start < session2 end && session1 end > session2 start
-	return start1Min < end2Min && end1Min > start2Min
-}
+// pickOptimizerCandidate picks the earliest-starting candidate; ties prefer profile tracks,
+// then the building of the previous session, then the session code for determinism
+func pickOptimizerCandidate(candidates []Session, profile []string, previous *Session) Session {
+	previousBuilding := ""
+	if previous != nil {
+		previousBuilding = getBuildingFromRoom(previous.Room)
+	}
 
-// GetRecommendations returns recommended sessions for the user using new room-based logic
-func GetRecommendations(sessionID string) ([]Session, error) {
-	state := GetUserState(sessionID)
-	if state == nil {
-		return nil, fmt.Errorf("session %s not found", sessionID)
+	rank := func(session Session) (int, int, int) {
+		inProfile, sameBuilding := 1, 1
+		if slices.Contains(profile, session.Track) {
+			inProfile = 0
+		}
+		if previousBuilding != "" && getBuildingFromRoom(session.Room) == previousBuilding {
+			sameBuilding = 0
+		}
+		return timeToMinutes(session.Start), inProfile, sameBuilding
 	}
 
-	// Use new room-based logic to find next available sessions
-	nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, state.Schedule)
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		candStart, candProfile, candBuilding := rank(candidate)
+		bestStart, bestProfile, bestBuilding := rank(best)
 
-	// Filter out long-duration social activities (Hacking Corner, etc.)
-	filteredSessions := filterOutSocialActivities(nextSessions)
+		switch {
+		case candStart != bestStart:
+			if candStart < bestStart {
+				best = candidate
+			}
+		case candProfile != bestProfile:
+			if candProfile < bestProfile {
+				best = candidate
+			}
+		case candBuilding != bestBuilding:
+			if candBuilding < bestBuilding {
+				best = candidate
+			}
+		case candidate.Code < best.Code:
+			best = candidate
+		}
+	}
+	return best
+}
 
-	return filteredSessions, nil
+// FinishPlanning marks user's planning as completed
+func FinishPlanning(sessionID string) error {
+	return UpdateUserState(sessionID, func(state *UserState) {
+		state.IsCompleted = true
+		log.Printf("[%s] User manually finished planning with %d sessions",
+			sessionID, len(state.Schedule))
+	})
 }
 
-// CleanupOldSessions removes sessions older than configured hours (parallel cleanup)
-func CleanupOldSessions() {
-	cutoff := time.Now().Add(-SessionCleanupHours * time.Hour)
-	totalCleaned := 0
+// SetAutoFinish opts a session in (or out) of finishing planning automatically once the day is complete
+func SetAutoFinish(sessionID string, enabled bool) error {
+	return UpdateUserState(sessionID, func(state *UserState) {
+		state.AutoFinish = enabled
+	})
+}
 
-	// Clean each shard in parallel
-	var wg sync.WaitGroup
-	cleanedCounts := make([]int, NumShards)
+// SetMobility sets the user's walking pace used for transfer feasibility
+func SetMobility(sessionID string, mobility Mobility) error {
+	return UpdateUserState(sessionID, func(state *UserState) {
+		state.Mobility = mobility
+	})
+}
+
+// AddFavorites stars sessions the user is interested in without adding them to the schedule
+// Codes already starred are kept once; unknown codes are rejected before anything is changed
+// and wrap ErrSessionNotFound
+func AddFavorites(sessionID string, codes []string) error {
+	for _, code := range codes {
+		if FindSessionByCode(code) == nil {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, code)
+		}
+	}
+	return UpdateUserState(sessionID, func(state *UserState) {
+		for _, code := range codes {
+			if !slices.Contains(state.Favorites, code) {
+				state.Favorites = append(state.Favorites, code)
+			}
+		}
+	})
+}
+
+// FavoritesConflictingWithSchedule returns the user's favorites that overlap a session on their schedule,
+// sorted by start time. Favorites on another day or already on the schedule can't clash and are skipped,
+// and cancelled schedule entries don't take up time
+func FavoritesConflictingWithSchedule(sessionID string) []Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+	return favoritesConflictingWithSchedule(state)
+}
+
+// favoritesConflictingWithSchedule computes FavoritesConflictingWithSchedule for a state
+func favoritesConflictingWithSchedule(state *UserState) []Session {
+	committed := activeSessions(state.Schedule)
+
+	var conflicts []Session
+	for _, code := range state.Favorites {
+		favorite := FindSessionByCode(code)
+		if favorite == nil || favorite.Day != state.Day {
+			continue
+		}
+		if slices.ContainsFunc(state.Schedule, func(s Session) bool { return s.Code == code }) {
+			continue
+		}
+		if hasConflictWithSchedule(*favorite, committed) {
+			conflicts = append(conflicts, *favorite)
+		}
+	}
+
+	conflicts = getSimplifiedSessions(conflicts)
+	sortSessionsByStartTime(conflicts)
+	return conflicts
+}
+
+// recordPlanningOffer remembers that more planning was offered at the current schedule size
+func recordPlanningOffer(sessionID string, scheduleSize int) {
+	_ = UpdateUserState(sessionID, func(state *UserState) {
+		state.OfferedAt = scheduleSize
+	})
+}
+
+// AutoFinishIfComplete marks planning finished for opted-in users when no real sessions remain
+// and they declined the last offer to plan more (their schedule hasn't grown since)
+// Returns whether planning was finished by this call
+func AutoFinishIfComplete(sessionID string) bool {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil || !state.AutoFinish || state.IsCompleted {
+		return false
+	}
+
+	declined := state.OfferedAt > 0 && len(state.Schedule) == state.OfferedAt
+	if !declined {
+		return false
+	}
+
+	realOptions := filterOutSocialActivities(FindNextAvailableInEachRoom(state.Day, state.LastEndTime, activeSessions(state.Schedule)))
+	if len(realOptions) > 0 {
+		return false
+	}
+
+	err := UpdateUserState(sessionID, func(state *UserState) {
+		state.IsCompleted = true
+		log.Printf("[%s] Planning auto-finished with %d sessions", sessionID, len(state.Schedule))
+	})
+	return err == nil
+}
+
+// FindNextAvailableInEachRoom finds next available session in each room after given time
+func FindNextAvailableInEachRoom(day, afterTime string, userSchedule []Session) []Session {
+	var nextSessions []Session
+	afterMinutes := timeToMinutes(afterTime)
+
+	// Find next available session in each room, using the cached per-room lists sorted by start time
+	for _, sessions := range sessionData().byDayRoom[day] {
+		// Find the first available session in this room
+		for _, session := range sessions {
+			startMinutes := timeToMinutes(session.Start)
+
+			// Must start after afterTime
+			if startMinutes >= afterMinutes {
+				// Check if it conflicts with user schedule
+				if !hasConflictWithSchedule(session, userSchedule) {
+					nextSessions = append(nextSessions, session)
+					break // Found the next available session for this room
+				}
+				// If it conflicts, continue to check the next session in this room
+			}
+		}
+	}
+
+	return getSimplifiedSessions(nextSessions)
+}
+
+// GetStartOptions returns the initial options when planning starts at currentTime on the given internal day
+// Before the day's first session (or after its last) this is GetFirstSession; otherwise it is the next session in
+// each room plus running sessions that started at most graceMinutes ago, so a user who just sat down can still add the talk
+func GetStartOptions(day, currentTime string, graceMinutes int) []Session {
+	firstSessions := GetFirstSession(day)
+	if currentTime == "" || len(firstSessions) == 0 || timeToMinutes(currentTime) <= timeToMinutes(firstSessions[0].Start) {
+		return firstSessions
+	}
+
+	nowMinutes := timeToMinutes(currentTime)
+	var ongoing []Session
+	for _, session := range sessionData().byDay[day] {
+		startMinutes := timeToMinutes(session.Start)
+		if startMinutes < nowMinutes && nowMinutes-startMinutes <= graceMinutes &&
+			endTimeToMinutes(session.Start, session.End) > nowMinutes {
+			ongoing = append(ongoing, session)
+		}
+	}
+
+	options := append(getSimplifiedSessions(ongoing), FindNextAvailableInEachRoom(day, currentTime, nil)...)
+	options = filterOutSocialActivities(options)
+	if len(options) == 0 {
+		// Nothing left today; planning the whole day is still possible
+		return firstSessions
+	}
+	sortSessionsByStartTime(options)
+	return options
+}
+
+// FindAllCompatibleSessions returns every session starting at or after the user's last end time that
+// doesn't conflict with their schedule (not just one per room), sorted by start time then room
+// Long social activities are left out, as in recommendations
+func FindAllCompatibleSessions(sessionID string) []Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	afterMinutes := timeToMinutes(state.LastEndTime)
+	active := activeSessions(state.Schedule)
+
+	var compatible []Session
+	for _, session := range filterOutSocialActivities(sessionData().byDay[state.Day]) {
+		if timeToMinutes(session.Start) < afterMinutes || hasConflictWithSchedule(session, active) {
+			continue
+		}
+		compatible = append(compatible, session)
+	}
+
+	result := getSimplifiedSessions(compatible)
+	sortSessionsByStartTime(result)
+
+	return result
+}
+
+// hasConflictWithSchedule checks if session conflicts with user's existing schedule
+func hasConflictWithSchedule(session Session, userSchedule []Session) bool {
+	for _, scheduled := range userSchedule {
+		if hasTimeConflict(session.Start, session.End, scheduled.Start, scheduled.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// findConflictingSessions returns all sessions that conflict with the given session
+func findConflictingSessions(session Session, userSchedule []Session) []Session {
+	var conflicts []Session
+	for _, scheduled := range userSchedule {
+		if hasTimeConflict(session.Start, session.End, scheduled.Start, scheduled.End) {
+			conflicts = append(conflicts, scheduled)
+		}
+	}
+	return conflicts
+}
+
+// hasTimeConflict checks if two time periods overlap
+func hasTimeConflict(start1, end1, start2, end2 string) bool {
+	start1Min := timeToMinutes(start1)
+	end1Min := endTimeToMinutes(start1, end1)
+	start2Min := timeToMinutes(start2)
+	end2Min := endTimeToMinutes(start2, end2)
+
+	// Two time periods overlap if:
+	// session1 start < session2 end && session1 end > session2 start
+	return start1Min < end2Min && end1Min > start2Min
+}
+
+// GetRecommendations returns recommended sessions for the user using new room-based logic
+func GetRecommendations(sessionID string) ([]Session, error) {
+	recommendations, _, err := GetRecommendationsWithReason(sessionID)
+	return recommendations, err
+}
+
+// GetRecommendationsWithReason is GetRecommendations that also reports why the list is empty
+// The reason is one of the EmptyReason constants, or "" when there are recommendations
+func GetRecommendationsWithReason(sessionID string) ([]Session, string, error) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil, "", fmt.Errorf("session %s not found", sessionID)
+	}
+
+	// Use new room-based logic to find next available sessions
+	nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, activeSessions(state.Schedule))
+
+	// Filter out long-duration social activities (Hacking Corner, etc.)
+	filteredSessions := filterOutSocialActivities(nextSessions)
+
+	// Stable per-user order instead of map iteration order
+	var currentBuilding string
+	if last := findLastScheduledSession(state.Schedule); last != nil && PreferSameBuildingOnTies {
+		currentBuilding = getBuildingFromRoom(last.Room)
+	}
+	orderRecommendations(filteredSessions, recommendationSeed(sessionID), currentBuilding, func(session Session) int {
+		return scoreSession(session, state)
+	})
+
+	if len(filteredSessions) > 0 {
+		return filteredSessions, "", nil
+	}
+	return filteredSessions, emptyRecommendationReason(state, len(nextSessions)), nil
+}
+
+// emptyRecommendationReason explains an empty recommendation list, given how many sessions
+// were found before social activities were filtered out
+// Only unscheduled sessions starting at or after LastEndTime count as later sessions; since LastEndTime is
+// the latest end in the schedule, they only conflict when LastEndTime lags behind it
+func emptyRecommendationReason(state *UserState, unfilteredCount int) string {
+	if unfilteredCount > 0 {
+		return EmptyReasonOnlySocial
+	}
+
+	scheduled := make(map[string]bool, len(state.Schedule))
+	for _, session := range state.Schedule {
+		scheduled[session.Code] = true
+	}
+
+	afterMinutes := timeToMinutes(state.LastEndTime)
+	active := activeSessions(state.Schedule)
+	for _, session := range sessionData().byDay[state.Day] {
+		if scheduled[session.Code] || timeToMinutes(session.Start) < afterMinutes {
+			continue
+		}
+		if hasConflictWithSchedule(session, active) {
+			return EmptyReasonAllConflict
+		}
+	}
+	return EmptyReasonNoLaterSessions
+}
+
+// recommendationSeed derives the shuffle seed for a user; tests may override it
+var recommendationSeed = func(sessionID string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(sessionID))
+	return hash.Sum64()
+}
+
+// profileDecayHalfLife enables recency weighting of profile tracks: a track chosen this long before the
+// user's latest pick counts half as much. Zero (the default) weighs all profile tracks equally
+var profileDecayHalfLife time.Duration
+
+// SetProfileDecayHalfLife configures recency weighting of profile tracks, see profileDecayHalfLife
+// Zero or a negative value disables decay, weighing all profile tracks equally
+func SetProfileDecayHalfLife(halfLife time.Duration) {
+	profileDecayHalfLife = max(halfLife, 0)
+}
+
+// scoreSession scores how well a session matches the user's profile: up to ProfileMatchScore for the track,
+// plus SpeakerFollowScore when a speaker of the session is already on the user's schedule
+func scoreSession(session Session, state *UserState) int {
+	score := trackScore(session, state)
+	if slices.ContainsFunc(state.Profile, func(interest string) bool {
+		return slices.ContainsFunc(session.Tags, func(tag string) bool { return tagMatches(tag, interest) })
+	}) {
+		score += ProfileTagScore
+	}
+	followed := FollowedSpeakers(state)
+	if slices.ContainsFunc(session.Speakers, func(speaker string) bool { return followed[speaker] }) {
+		score += SpeakerFollowScore
+	}
+	return score
+}
+
+// RecommendedCodes returns the codes of the sessions that match the user's interests (a positive scoreSession),
+// in the given order, with each session's score, so clients can highlight top picks
+func RecommendedCodes(sessions []Session, state *UserState) ([]string, map[string]int) {
+	codes := []string{}
+	scores := make(map[string]int, len(sessions))
+	for _, session := range sessions {
+		score := scoreSession(session, state)
+		scores[session.Code] = score
+		if score > 0 {
+			codes = append(codes, session.Code)
+		}
+	}
+	return codes, scores
+}
+
+// FollowedSpeakers returns the set of speakers of the sessions on the user's schedule
+func FollowedSpeakers(state *UserState) map[string]bool {
+	followed := make(map[string]bool)
+	for _, scheduled := range state.Schedule {
+		for _, speaker := range scheduled.Speakers {
+			followed[speaker] = true
+		}
+	}
+	return followed
+}
+
+// trackScore scores how well a session's track matches the user's profile, from 0 (no match) to ProfileMatchScore
+func trackScore(session Session, state *UserState) int {
+	if !slices.Contains(state.Profile, session.Track) {
+		return 0
+	}
+	if profileDecayHalfLife <= 0 {
+		return ProfileMatchScore
+	}
+
+	addedAt, known := state.ProfileAddedAt[session.Track]
+	if !known {
+		return ProfileMatchScore
+	}
+
+	// Age relative to the most recent pick, so scores only depend on the order of choices
+	var latest time.Time
+	for _, t := range state.ProfileAddedAt {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	halfLives := float64(latest.Sub(addedAt)) / float64(profileDecayHalfLife)
+
+	return int(math.Round(ProfileMatchScore * math.Pow(0.5, halfLives)))
+}
+
+// PreferSameBuildingOnTies makes recommendations starting together with the same score list the ones in the
+// building of the user's last scheduled session first, for plans with less walking. Set it at startup to change it
+var PreferSameBuildingOnTies = true
+
+// orderRecommendations sorts sessions by score (highest first), then start time, then sessions in preferBuilding
+// first, and shuffles each group of equal score, start time and building preference with the given seed, so the
+// same seed always yields the same order while different users see variety. A nil scoreOf scores every session
+// equally; an empty preferBuilding disables the building tie-break
+func orderRecommendations(sessions []Session, seed uint64, preferBuilding string, scoreOf func(Session) int) {
+	if scoreOf == nil {
+		scoreOf = func(Session) int { return 0 }
+	}
+	scores := make(map[string]int, len(sessions))
+	for _, session := range sessions {
+		scores[session.Code] = scoreOf(session)
+	}
+	inBuilding := func(session Session) bool {
+		return preferBuilding != "" && getBuildingFromRoom(session.Room) == preferBuilding
+	}
+
+	// Canonical order first so the shuffle does not depend on the input order
+	sort.Slice(sessions, func(i, j int) bool {
+		if scores[sessions[i].Code] != scores[sessions[j].Code] {
+			return scores[sessions[i].Code] > scores[sessions[j].Code]
+		}
+		startI, startJ := timeToMinutes(sessions[i].Start), timeToMinutes(sessions[j].Start)
+		if startI != startJ {
+			return startI < startJ
+		}
+		if inBuilding(sessions[i]) != inBuilding(sessions[j]) {
+			return inBuilding(sessions[i])
+		}
+		return sessions[i].Code < sessions[j].Code
+	})
+
+	rng := mathrand.New(mathrand.NewPCG(seed, seed))
+	for groupStart := 0; groupStart < len(sessions); {
+		groupEnd := groupStart + 1
+		for groupEnd < len(sessions) && sessions[groupEnd].Start == sessions[groupStart].Start &&
+			scores[sessions[groupEnd].Code] == scores[sessions[groupStart].Code] &&
+			inBuilding(sessions[groupEnd]) == inBuilding(sessions[groupStart]) {
+			groupEnd++
+		}
+
+		group := sessions[groupStart:groupEnd]
+		rng.Shuffle(len(group), func(i, j int) {
+			group[i], group[j] = group[j], group[i]
+		})
+
+		groupStart = groupEnd
+	}
+}
+
+// GetRecommendationsInSameBuilding returns recommendations limited to the building of the
+// user's last scheduled session. Falls back to all recommendations (and an empty building)
+// when the building is unknown or no session there is available.
+func GetRecommendationsInSameBuilding(sessionID string) ([]Session, string, error) {
+	recommendations, err := GetRecommendations(sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil, "", fmt.Errorf("session %s not found", sessionID)
+	}
+
+	lastSession := findLastScheduledSession(state.Schedule)
+	if lastSession == nil {
+		return recommendations, "", nil
+	}
+
+	building := getBuildingFromRoom(lastSession.Room)
+	if building == "Unknown" {
+		return recommendations, "", nil
+	}
+
+	sameBuilding := filterSessionsByBuilding(recommendations, building)
+	if len(sameBuilding) == 0 {
+		log.Printf("[%s] No sessions available in building %s, falling back to all rooms", sessionID, building)
+		return recommendations, "", nil
+	}
+
+	return sameBuilding, building, nil
+}
+
+// SuggestBackup picks an alternative for a scheduled session in case it is full or cancelled:
+// a concurrent session that doesn't clash with the rest of the schedule, preferring shared tags,
+// the same track and then the shortest walk at the user's mobility. Returns nil when no such session exists
+func SuggestBackup(session Session, day string, schedule []Session, mobility Mobility) *Session {
+	// The backup replaces this session, so only the other entries constrain it
+	var others []Session
+	for _, scheduled := range schedule {
+		if scheduled.Code != session.Code {
+			others = append(others, scheduled)
+		}
+	}
+
+	var best *Session
+	bestTags, bestSameTrack, bestWalk := -1, false, 0
+	for _, candidate := range filterOutSocialActivities(sessionData().byDay[day]) {
+		if candidate.Code == session.Code || slices.ContainsFunc(schedule, func(s Session) bool { return s.Code == candidate.Code }) {
+			continue
+		}
+		if !hasTimeConflict(candidate.Start, candidate.End, session.Start, session.End) || hasConflictWithSchedule(candidate, others) {
+			continue
+		}
+
+		sharedTags := countSharedTags(candidate.Tags, session.Tags)
+		sameTrack := candidate.Track == session.Track
+		walk := scaleWalkingTime(calculateWalkingTime(session.Room, candidate.Room), mobility)
+
+		better := best == nil ||
+			sharedTags > bestTags ||
+			(sharedTags == bestTags && sameTrack && !bestSameTrack) ||
+			(sharedTags == bestTags && sameTrack == bestSameTrack && walk < bestWalk) ||
+			(sharedTags == bestTags && sameTrack == bestSameTrack && walk == bestWalk && candidate.Code < best.Code)
+		if better {
+			c := candidate
+			best = &c
+			bestTags, bestSameTrack, bestWalk = sharedTags, sameTrack, walk
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	simplified := getSimplifiedSessions([]Session{*best})[0]
+	return &simplified
+}
+
+// countSharedTags counts tags present in both lists
+func countSharedTags(a, b []string) int {
+	shared := 0
+	for _, tag := range a {
+		if slices.Contains(b, tag) {
+			shared++
+		}
+	}
+	return shared
+}
+
+// orderBySurprise reorders recommendations for "surprise me" mode, most off-profile first
+// Ties keep their existing (seeded) order
+func orderBySurprise(sessions []Session, state *UserState) {
+	profileTags := make(map[string]bool)
+	for _, scheduled := range state.Schedule {
+		for _, tag := range scheduled.Tags {
+			profileTags[tag] = true
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return surpriseScore(sessions[i], state.Profile, profileTags) > surpriseScore(sessions[j], state.Profile, profileTags)
+	})
+}
+
+// surpriseScore is an anti-match score: tags the user hasn't picked before count up,
+// tags they already picked count down, and a track from their profile counts down further
+func surpriseScore(session Session, profileTracks []string, profileTags map[string]bool) int {
+	score := 0
+	for _, tag := range session.Tags {
+		if profileTags[tag] {
+			score--
+		} else {
+			score++
+		}
+	}
+	if slices.Contains(profileTracks, session.Track) {
+		score -= 2
+	}
+	return score
+}
+
+// UnsampledTracks returns the tracks of the user's day that are not in their profile yet,
+// each with a representative teaser session
+func UnsampledTracks(sessionID string) map[string]Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	unsampled := make(map[string]Session)
+	for _, summary := range GetTrackSummary(state.Day) {
+		if slices.Contains(state.Profile, summary.Track) || len(summary.Representatives) == 0 {
+			continue
+		}
+		unsampled[summary.Track] = summary.Representatives[0]
+	}
+	return unsampled
+}
+
+// findLastScheduledSession returns the scheduled session that ends last
+func findLastScheduledSession(schedule []Session) *Session {
+	var last *Session
+	for i := range schedule {
+		if last == nil || endTimeToMinutes(schedule[i].Start, schedule[i].End) > endTimeToMinutes(last.Start, last.End) {
+			last = &schedule[i]
+		}
+	}
+	return last
+}
+
+// filterSessionsByBuilding keeps only sessions located in the given building
+func filterSessionsByBuilding(sessions []Session, building string) []Session {
+	var filtered []Session
+	for _, session := range sessions {
+		if getBuildingFromRoom(session.Room) == building {
+			filtered = append(filtered, session)
+		}
+	}
+	return filtered
+}
+
+// Inactivity TTLs used by CleanupOldSessions, see SetSessionTTLs
+var (
+	incompleteSessionTTL = SessionCleanupHours * time.Hour
+	completedSessionTTL  = CompletedSessionCleanupHours * time.Hour
+)
+
+// SetSessionTTLs configures how long inactive sessions are kept: incomplete for plans still being drafted,
+// completed for plans the user finished. Non-positive values keep the current setting
+func SetSessionTTLs(incomplete, completed time.Duration) {
+	if incomplete > 0 {
+		incompleteSessionTTL = incomplete
+	}
+	if completed > 0 {
+		completedSessionTTL = completed
+	}
+}
+
+// CleanupOldSessions removes sessions inactive for longer than their TTL (parallel cleanup)
+// Completed plans use completedSessionTTL, everything else incompleteSessionTTL
+func CleanupOldSessions() {
+	now := time.Now()
+	incompleteCutoff := now.Add(-incompleteSessionTTL)
+	completedCutoff := now.Add(-completedSessionTTL)
+	totalCleaned := 0
+
+	// Clean each shard in parallel
+	var wg sync.WaitGroup
+	cleanedCounts := make([]int, NumShards)
 
 	for i := range NumShards {
 		wg.Add(1)
@@ -367,11 +1501,15 @@ func CleanupOldSessions() {
 			defer wg.Done()
 
 			shard := sessionShards[shardIndex]
-			shard.mu.Lock()
+			shard.lock()
 			defer shard.mu.Unlock()
 
 			cleaned := 0
 			for sessionID, state := range shard.sessions {
+				cutoff := incompleteCutoff
+				if state.IsCompleted {
+					cutoff = completedCutoff
+				}
 				if state.LastActivity.Before(cutoff) {
 					log.Printf("[%s] Cleaning up expired session (inactive since %v)",
 						sessionID, state.LastActivity.Format("2006-01-02 15:04:05"))
@@ -379,67 +1517,488 @@ func CleanupOldSessions() {
 					cleaned++
 				}
 			}
-			cleanedCounts[shardIndex] = cleaned
-		}(i)
+			cleanedCounts[shardIndex] = cleaned
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Sum up cleaned sessions
+	for _, count := range cleanedCounts {
+		totalCleaned += count
+	}
+
+	if totalCleaned > 0 {
+		activeCount := 0
+		for i := range NumShards {
+			shard := sessionShards[i]
+			shard.rlock()
+			activeCount += len(shard.sessions)
+			shard.mu.RUnlock()
+		}
+		log.Printf("Cleaned up %d expired sessions, %d sessions remain active", totalCleaned, activeCount)
+	}
+}
+
+// reconcileSchedules flags scheduled sessions that no longer exist in the dataset as cancelled
+// and refreshes the time and room of sessions that moved, which may put them in conflict
+// Entries are kept rather than deleted so users can still see what they had planned
+// Returns the number of scheduled entries currently marked cancelled
+func reconcileSchedules() int {
+	sessions := sessionData().all
+	existing := make(map[string]Session, len(sessions))
+	for _, session := range sessions {
+		existing[session.Code] = session
+	}
+
+	cancelled := 0
+	for i := range NumShards {
+		shard := sessionShards[i]
+		shard.lock()
+		for _, state := range shard.sessions {
+			for j := range state.Schedule {
+				scheduled := &state.Schedule[j]
+				current, ok := existing[scheduled.Code]
+				scheduled.Cancelled = !ok
+				if !ok {
+					cancelled++
+					continue
+				}
+
+				if scheduled.Start != current.Start || scheduled.End != current.End || scheduled.Room != current.Room {
+					log.Printf("[%s] Scheduled session %s moved to %s-%s in %s",
+						state.SessionID, scheduled.Code, current.Start, current.End, current.Room)
+					scheduled.Start, scheduled.End, scheduled.Room = current.Start, current.End, current.Room
+				}
+			}
+			if len(state.Schedule) > 0 {
+				state.LastEndTime = latestEndTime(state.Schedule)
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	return cancelled
+}
+
+// latestEndTime returns the latest end time of the schedule's sessions that are not cancelled,
+// PlanningStartTime when there are none. A session past midnight ends the day at 23:59
+func latestEndTime(schedule []Session) string {
+	latest := timeToMinutes(PlanningStartTime)
+	for _, session := range schedule {
+		if !session.Cancelled {
+			latest = max(latest, endTimeToMinutes(session.Start, session.End))
+		}
+	}
+	return minutesToTime(latest)
+}
+
+// ConflictChoice is a group of scheduled sessions that overlap each other, of which the user keeps one
+type ConflictChoice struct {
+	Start   string    `json:"start"`
+	End     string    `json:"end"`
+	Options []Session `json:"options"`
+}
+
+// ResolvePostReloadConflicts returns the groups of overlapping sessions in the user's schedule
+// AddSessionToSchedule rejects conflicts, so any overlap was introduced by a data reload moving a session
+// Cancelled entries are ignored since they no longer take up time
+func ResolvePostReloadConflicts(sessionID string) []ConflictChoice {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	var active []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			active = append(active, session)
+		}
+	}
+	sortSessionsByStartTime(active)
+
+	var choices []ConflictChoice
+	var group []Session
+	groupEnd := ""
+	flush := func() {
+		if len(group) > 1 {
+			choices = append(choices, ConflictChoice{Start: group[0].Start, End: groupEnd, Options: group})
+		}
+	}
+
+	groupEndMin := 0
+	for _, session := range active {
+		endMin := endTimeToMinutes(session.Start, session.End)
+		if len(group) > 0 && timeToMinutes(session.Start) < groupEndMin {
+			group = append(group, session)
+			if endMin > groupEndMin {
+				groupEnd, groupEndMin = session.End, endMin
+			}
+			continue
+		}
+		flush()
+		group = []Session{session}
+		groupEnd, groupEndMin = session.End, endMin
 	}
+	flush()
 
-	wg.Wait()
+	return choices
+}
 
-	// Sum up cleaned sessions
-	for _, count := range cleanedCounts {
-		totalCleaned += count
+// KeepConflictingSession resolves a conflict by keeping the given scheduled session and removing
+// every other active session that overlaps it. Returns the removed sessions.
+func KeepConflictingSession(sessionID, sessionCode string) ([]Session, error) {
+	var inSchedule bool
+	var removed []Session
+	err := UpdateUserState(sessionID, func(state *UserState) {
+		idx := slices.IndexFunc(state.Schedule, func(s Session) bool { return s.Code == sessionCode })
+		if idx < 0 {
+			return
+		}
+		inSchedule = true
+		kept := state.Schedule[idx]
+
+		remaining := make([]Session, 0, len(state.Schedule))
+		for _, session := range state.Schedule {
+			if session.Code != kept.Code && !session.Cancelled &&
+				hasTimeConflict(kept.Start, kept.End, session.Start, session.End) {
+				removed = append(removed, session)
+				delete(state.Locked, session.Code)
+				continue
+			}
+			remaining = append(remaining, session)
+		}
+		state.Schedule = remaining
+		state.LastEndTime = latestEndTime(remaining)
+
+		log.Printf("[%s] Kept session %s, removed %d conflicting sessions", sessionID, sessionCode, len(removed))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !inSchedule {
+		return nil, fmt.Errorf("session %s is not in your schedule", sessionCode)
+	}
+	return removed, nil
+}
+
+// activeSessions returns the sessions of a schedule that are not cancelled
+func activeSessions(schedule []Session) []Session {
+	var active []Session
+	for _, session := range schedule {
+		if !session.Cancelled {
+			active = append(active, session)
+		}
 	}
+	return active
+}
 
-	if totalCleaned > 0 {
-		activeCount := 0
-		for i := range NumShards {
-			shard := sessionShards[i]
-			shard.mu.RLock()
-			activeCount += len(shard.sessions)
-			shard.mu.RUnlock()
+// countCancelledSessions returns how many sessions in a schedule are marked cancelled
+func countCancelledSessions(schedule []Session) int {
+	count := 0
+	for _, session := range schedule {
+		if session.Cancelled {
+			count++
 		}
-		log.Printf("Cleaned up %d expired sessions, %d sessions remain active", totalCleaned, activeCount)
 	}
+	return count
 }
 
 // GetSessionStats returns basic statistics about active sessions
 func GetSessionStats() map[string]any {
 	totalSessions := 0
 	shardStats := make([]int, NumShards)
+	lockAcquisitions := make([]int64, NumShards)
+	lockContended := make([]int64, NumShards)
 
 	for i := range NumShards {
 		shard := sessionShards[i]
+		// Locked directly so reading stats doesn't count towards contention
 		shard.mu.RLock()
 		count := len(shard.sessions)
 		shard.mu.RUnlock()
 
 		shardStats[i] = count
 		totalSessions += count
+		lockAcquisitions[i] = shard.acquisitions.Load()
+		lockContended[i] = shard.contended.Load()
+	}
+
+	return map[string]any{
+		"active_sessions":         totalSessions,
+		"shard_stats":             shardStats,
+		"shard_lock_acquisitions": lockAcquisitions,
+		"shard_lock_contended":    lockContended,
+		"num_shards":              NumShards,
+		"schedule_additions":      scheduleAdditions.Load(),
+		"conflict_rejections":     conflictRejections.Load(),
+		"rejected_sessions":       sessionData().rejected,
+		"timestamp":               conferenceNow().Format(time.RFC3339),
+	}
+}
+
+// AggregateSchedulePopularity counts how many active users have each session code on their schedule
+// Only counts are returned, so no user can be identified; cancelled entries are skipped
+func AggregateSchedulePopularity() map[string]int {
+	counts := make(map[string]int)
+	for i := range NumShards {
+		shard := sessionShards[i]
+		shard.rlock()
+		for _, state := range shard.sessions {
+			for _, session := range state.Schedule {
+				if !session.Cancelled {
+					counts[session.Code]++
+				}
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return counts
+}
+
+// GetScheduleStatistics summarizes the quality of a user's schedule: track coverage,
+// total talk time, longest gap between sessions and estimated walking time
+func GetScheduleStatistics(sessionID string) (map[string]any, error) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	return scheduleStatistics(state), nil
+}
+
+// scheduleStatistics computes GetScheduleStatistics for a state
+func scheduleStatistics(state *UserState) map[string]any {
+	sortedSchedule := slices.Clone(state.Schedule)
+	sortSessionsByStartTime(sortedSchedule)
+
+	trackCoverage := make(map[string]int)
+	totalTalkMinutes := 0
+	longestGapMinutes := 0
+	totalWalkingMinutes := 0
+
+	for i, session := range sortedSchedule {
+		if session.Track != "" {
+			trackCoverage[session.Track]++
+		}
+		totalTalkMinutes += sessionDurationMinutes(session.Start, session.End)
+
+		if i == 0 {
+			continue
+		}
+		prev := sortedSchedule[i-1]
+		longestGapMinutes = max(longestGapMinutes, timeToMinutes(session.Start)-endTimeToMinutes(prev.Start, prev.End))
+		totalWalkingMinutes += scaleWalkingTime(transferWalkingTime(prev.Room, session.Room), state.Mobility)
 	}
 
 	return map[string]any{
-		"active_sessions": totalSessions,
-		"shard_stats":     shardStats,
-		"num_shards":      NumShards,
-		"timestamp":       time.Now().Format(time.RFC3339),
+		"session_count":         len(sortedSchedule),
+		"track_coverage":        trackCoverage,
+		"tracks_covered":        len(trackCoverage),
+		"total_talk_minutes":    totalTalkMinutes,
+		"longest_gap_minutes":   longestGapMinutes,
+		"total_walking_minutes": totalWalkingMinutes,
+	}
+}
+
+// ScheduleBalanceScore rates a schedule from 0 to 100 as the average of three components (each 0-100):
+//   - variety:  distinct tracks / sessions
+//   - walking:  100 minus total walking time relative to the worst case (UnknownWalkTime per transfer)
+//   - breaks:   share of transfers that are neither tight (see AssessPlanDensity) nor longer than LongGapMinutes
+//
+// Schedules with a single session score 100 on walking and breaks. Returns 0 and nil for empty schedules.
+// All components come from one snapshot, so a concurrent choose_session can't mix two schedules
+func ScheduleBalanceScore(sessionID string) (int, map[string]int) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil || len(state.Schedule) == 0 {
+		return 0, nil
+	}
+	stats := scheduleStatistics(state)
+
+	sessionCount := stats["session_count"].(int)
+	variety := stats["tracks_covered"].(int) * 100 / sessionCount
+
+	walking, breaks := 100, 100
+	tightTransfers, transfers := planDensity(state)
+	if transfers > 0 {
+		worstWalk := transfers * UnknownWalkTime
+		walking = 100 - min(100, stats["total_walking_minutes"].(int)*100/worstWalk)
+
+		longGaps := countLongGaps(state)
+		breaks = 100 - min(100, (tightTransfers+longGaps)*100/transfers)
+	}
+
+	components := map[string]int{
+		"variety": variety,
+		"walking": walking,
+		"breaks":  breaks,
 	}
+
+	return (variety + walking + breaks) / 3, components
+}
+
+// countLongGaps counts breaks between consecutive scheduled sessions longer than LongGapMinutes
+func countLongGaps(state *UserState) int {
+	sortedSchedule := slices.Clone(state.Schedule)
+	sortSessionsByStartTime(sortedSchedule)
+
+	longGaps := 0
+	for i := 1; i < len(sortedSchedule); i++ {
+		prev := sortedSchedule[i-1]
+		if timeToMinutes(sortedSchedule[i].Start)-endTimeToMinutes(prev.Start, prev.End) > LongGapMinutes {
+			longGaps++
+		}
+	}
+	return longGaps
+}
+
+// FindFreeSlots returns the user's free time windows within conference hours as [start, end] pairs
+// Cancelled sessions don't occupy time
+func FindFreeSlots(sessionID string) [][2]string {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	var busy []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			busy = append(busy, session)
+		}
+	}
+	sortSessionsByStartTime(busy)
+
+	var slots [][2]string
+	cursor := ConferenceStartHour * 60
+	for _, session := range busy {
+		start := timeToMinutes(session.Start)
+		if start > cursor {
+			slots = append(slots, [2]string{minutesToTime(cursor), minutesToTime(min(start, ConferenceEndHour*60))})
+		}
+		cursor = max(cursor, endTimeToMinutes(session.Start, session.End))
+		if cursor >= ConferenceEndHour*60 {
+			return slots
+		}
+	}
+	return append(slots, [2]string{minutesToTime(cursor), minutesToTime(ConferenceEndHour * 60)})
+}
+
+// GetFreeSlots returns the user's free windows within conference hours that are long enough for a talk
+// (at least MinSessionMinutes), each with "start", "end" and "duration" in minutes, earliest first
+func GetFreeSlots(sessionID string) []map[string]string {
+	slots := []map[string]string{}
+	for _, slot := range FindFreeSlots(sessionID) {
+		duration := timeToMinutes(slot[1]) - timeToMinutes(slot[0])
+		if duration >= MinSessionMinutes {
+			slots = append(slots, map[string]string{
+				"start":    slot[0],
+				"end":      slot[1],
+				"duration": strconv.Itoa(duration),
+			})
+		}
+	}
+	return slots
+}
+
+// FindFreeSlotOfLength returns the earliest free slot within conference hours lasting at least the given minutes
+func FindFreeSlotOfLength(sessionID string, minutes int) (start, end string, ok bool) {
+	for _, slot := range FindFreeSlots(sessionID) {
+		if timeToMinutes(slot[1])-timeToMinutes(slot[0]) >= minutes {
+			return slot[0], slot[1], true
+		}
+	}
+	return "", "", false
+}
+
+// SuggestLunchTime picks the free slot with the most time inside the lunch window and returns that part
+// of it as the user's lunch break, so an empty afternoon suggests the lunch window rather than the whole slot
+// ok is false when no slot leaves at least MinLunchMinutes inside the window, i.e. the schedule is too packed for lunch
+func SuggestLunchTime(sessionID string) (start, end string, ok bool) {
+	longest := 0
+	for _, slot := range FindFreeSlots(sessionID) {
+		lunchStart := max(timeToMinutes(slot[0]), LunchWindowStartMinutes)
+		lunchEnd := min(timeToMinutes(slot[1]), LunchWindowEndMinutes)
+		length := lunchEnd - lunchStart
+		if length >= MinLunchMinutes && length > longest {
+			start, end, ok, longest = minutesToTime(lunchStart), minutesToTime(lunchEnd), true, length
+		}
+	}
+	return start, end, ok
+}
+
+// FindBreakForExcursion returns the first break between two planned sessions long enough to leave campus:
+// the round trip outside plus walking from the first room out to the gate and back in to the next room
+// ok is false when no break is long enough. Cancelled sessions don't count as planned
+func FindBreakForExcursion(sessionID string, roundTripMinutes int) (start, end string, ok bool) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return "", "", false
+	}
+
+	var planned []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			planned = append(planned, session)
+		}
+	}
+	sortSessionsByStartTime(planned)
+
+	for i := 1; i < len(planned); i++ {
+		prev, next := planned[i-1], planned[i]
+		breakMinutes := timeToMinutes(next.Start) - endTimeToMinutes(prev.Start, prev.End)
+		gateWalks := scaleWalkingTime(calculateEntryTime(prev.Room)+calculateEntryTime(next.Room), state.Mobility)
+		if breakMinutes >= roundTripMinutes+gateWalks {
+			return prev.End, next.Start, true
+		}
+	}
+	return "", "", false
+}
+
+// FindCommonFreeSlots returns the free time windows two users share
+// Users planning different days have no overlap
+func FindCommonFreeSlots(idA, idB string) [][2]string {
+	stateA := GetUserStateSnapshot(idA)
+	stateB := GetUserStateSnapshot(idB)
+	if stateA == nil || stateB == nil || stateA.Day != stateB.Day {
+		return nil
+	}
+
+	slotsA := FindFreeSlots(idA)
+	slotsB := FindFreeSlots(idB)
+
+	// Both lists are sorted and non-overlapping, so walk them together
+	var common [][2]string
+	for i, j := 0, 0; i < len(slotsA) && j < len(slotsB); {
+		startA, endA := timeToMinutes(slotsA[i][0]), timeToMinutes(slotsA[i][1])
+		startB, endB := timeToMinutes(slotsB[j][0]), timeToMinutes(slotsB[j][1])
+
+		if start, end := max(startA, startB), min(endA, endB); start < end {
+			common = append(common, [2]string{minutesToTime(start), minutesToTime(end)})
+		}
+
+		if endA < endB {
+			i++
+		} else {
+			j++
+		}
+	}
+	return common
 }
 
 // IsScheduleComplete checks if the user has planned the full day
 func IsScheduleComplete(sessionID string) bool {
-	state := GetUserState(sessionID)
+	state := GetUserStateSnapshot(sessionID)
 	if state == nil {
 		return false
 	}
 
 	// Check if there are still available sessions to choose from
-	nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, state.Schedule)
+	nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, activeSessions(state.Schedule))
 
 	// Schedule is complete only if:
 	// 1. No more available sessions, OR
-	// 2. Last end time is after 17:00 (5 PM) AND user has selected at least 3 sessions
+	// 2. Last end time is after the conference end hour (17:00) AND user has selected at least 3 sessions
 	lastEndMinutes := timeToMinutes(state.LastEndTime)
-	hasLateEndTime := lastEndMinutes >= 17*60 // 17:00 = 17*60 minutes
+	hasLateEndTime := lastEndMinutes >= ConferenceEndHour*60
 	hasEnoughSessions := len(state.Schedule) >= 3
 
 	return len(nextSessions) == 0 || (hasLateEndTime && hasEnoughSessions)
@@ -456,7 +2015,7 @@ func generateTimelineView(state *UserState) string {
 	copy(sortedSchedule, state.Schedule)
 	sortSessionsByStartTime(sortedSchedule)
 
-	timeline := fmt.Sprintf("您的 %s 議程安排\n\n", state.Day)
+	timeline := fmt.Sprintf("您的 %s 議程安排\n\n", toUserDayFormat(state.Day))
 
 	for i, session := range sortedSchedule {
 		// Add time gap if needed
@@ -464,37 +2023,31 @@ func generateTimelineView(state *UserState) string {
 			prevEndTime := sortedSchedule[i-1].End
 			currentStartTime := session.Start
 
-			prevEndMin := timeToMinutes(prevEndTime)
+			prevEndMin := endTimeToMinutes(sortedSchedule[i-1].Start, prevEndTime)
 			currentStartMin := timeToMinutes(currentStartTime)
 
 			if currentStartMin > prevEndMin {
 				gapMinutes := currentStartMin - prevEndMin
-				timeline += fmt.Sprintf("⏰ %s-%s | 🆓 空檔時間 (%d分鐘)\n\n",
-					prevEndTime, currentStartTime, gapMinutes)
+				gapLabel := "🆓 空檔時間"
+				if isLunchBreak(prevEndTime, currentStartTime) {
+					gapLabel = "🍱 午餐時間"
+				}
+				walkMinutes := scaleWalkingTime(gapWalkingTime(sortedSchedule[i-1].Room, session.Room), state.Mobility)
+				timeline += fmt.Sprintf("⏰ %s-%s | %s (%d分鐘，步行約 %d 分鐘，實際空閒約 %d 分鐘)\n\n",
+					prevEndTime, currentStartTime, gapLabel, gapMinutes, walkMinutes, max(gapMinutes-walkMinutes, 0))
 			}
 		}
 
-		// Format session info
-		tags := ""
-		if len(session.Tags) > 0 {
-			tags = session.Tags[0] // Use first tag as primary
-		}
-
-		timeline += fmt.Sprintf("%s-%s | %s\n   %s %s\n   %s | %s | %s %s\n\n",
-			session.Start, session.End, session.Room,
-			tags, session.Title,
-			formatSpeakers(session.Speakers), session.Track,
-			session.Language, session.Difficulty)
+		timeline += formatTimelineSession(session)
 	}
 
 	// Add statistics
 	totalSessions := len(sortedSchedule)
 	if totalSessions > 0 {
-		firstStart := sortedSchedule[0].Start
-		lastEnd := sortedSchedule[totalSessions-1].End
+		first, last := sortedSchedule[0], sortedSchedule[totalSessions-1]
 
-		startMin := timeToMinutes(firstStart)
-		endMin := timeToMinutes(lastEnd)
+		startMin := timeToMinutes(first.Start)
+		endMin := endTimeToMinutes(last.Start, last.End)
 		totalHours := (endMin - startMin) / 60
 
 		timeline += fmt.Sprintf("統計：共選擇 %d 個 session，總時間跨度 %d 小時",
@@ -504,6 +2057,114 @@ func generateTimelineView(state *UserState) string {
 	return timeline
 }
 
+// formatTimelineSession formats a single session entry of the timeline view
+func formatTimelineSession(session Session) string {
+	tags := ""
+	if len(session.Tags) > 0 {
+		tags = session.Tags[0] // Use first tag as primary
+	}
+	if session.Cancelled {
+		tags = "❌ 已取消 " + tags
+	}
+
+	return fmt.Sprintf("%s-%s | %s\n   %s %s\n   %s | %s | %s %s\n\n",
+		session.Start, session.End, session.Room,
+		tags, session.Title,
+		formatSpeakers(session.Speakers), session.Track,
+		session.Language, session.Difficulty)
+}
+
+// TimelineEntry is a scheduled session with how the user gets there from the previous one
+type TimelineEntry struct {
+	Session     Session `json:"session"`
+	RoomChange  bool    `json:"room_change"`            // the previous session was in another room
+	FromRoom    string  `json:"from_room,omitempty"`    // room of the previous session when changing rooms
+	WalkMinutes int     `json:"walk_minutes,omitempty"` // mobility-scaled walk when changing rooms
+}
+
+// buildTimelineEntries lists the user's schedule chronologically, flagging every room change and its walking time
+func buildTimelineEntries(state *UserState) []TimelineEntry {
+	sortedSchedule := make([]Session, len(state.Schedule))
+	copy(sortedSchedule, state.Schedule)
+	sortSessionsByStartTime(sortedSchedule)
+
+	entries := make([]TimelineEntry, len(sortedSchedule))
+	for i, session := range sortedSchedule {
+		entries[i].Session = session
+		if i == 0 || sortedSchedule[i-1].Room == session.Room {
+			continue
+		}
+		entries[i].RoomChange = true
+		entries[i].FromRoom = sortedSchedule[i-1].Room
+		entries[i].WalkMinutes = scaleWalkingTime(transferWalkingTime(entries[i].FromRoom, session.Room), state.Mobility)
+	}
+	return entries
+}
+
+// generateMovesTimelineView creates a chronological view that highlights each room change as an action point
+// Staying in the same room is only noted briefly
+func generateMovesTimelineView(state *UserState) string {
+	if len(state.Schedule) == 0 {
+		return "尚未選擇任何議程"
+	}
+
+	entries := buildTimelineEntries(state)
+	timeline := fmt.Sprintf("您的 %s 議程安排（標示換教室）\n\n", toUserDayFormat(state.Day))
+
+	moves := 0
+	for i, entry := range entries {
+		switch {
+		case entry.RoomChange:
+			moves++
+			timeline += fmt.Sprintf("🚶 換教室：%s 結束後從 %s 前往 %s，步行約 %d 分鐘\n\n",
+				entries[i-1].Session.End, entry.FromRoom, entry.Session.Room, entry.WalkMinutes)
+		case i > 0:
+			timeline += "   ↓ 留在同一教室\n\n"
+		}
+		timeline += formatTimelineSession(entry.Session)
+	}
+
+	timeline += fmt.Sprintf("統計：共選擇 %d 個 session，需要換教室 %d 次", len(entries), moves)
+
+	return timeline
+}
+
+// generateRoomTimelineView creates a venue-centric view of user's schedule, grouped by room
+// Rooms are ordered by their first scheduled session; sessions within a room stay chronological
+func generateRoomTimelineView(state *UserState) string {
+	if len(state.Schedule) == 0 {
+		return "尚未選擇任何議程"
+	}
+
+	sortedSchedule := make([]Session, len(state.Schedule))
+	copy(sortedSchedule, state.Schedule)
+	sortSessionsByStartTime(sortedSchedule)
+
+	// Group by room, keeping first-seen order from the chronological list
+	var rooms []string
+	roomSessions := make(map[string][]Session)
+	for _, session := range sortedSchedule {
+		if _, exists := roomSessions[session.Room]; !exists {
+			rooms = append(rooms, session.Room)
+		}
+		roomSessions[session.Room] = append(roomSessions[session.Room], session)
+	}
+
+	timeline := fmt.Sprintf("您的 %s 議程安排（依場地）\n\n", toUserDayFormat(state.Day))
+
+	for _, room := range rooms {
+		sessions := roomSessions[room]
+		timeline += fmt.Sprintf("📍 %s（%d 場）\n\n", room, len(sessions))
+		for _, session := range sessions {
+			timeline += formatTimelineSession(session)
+		}
+	}
+
+	timeline += fmt.Sprintf("統計：共選擇 %d 個 session，分佈在 %d 個場地", len(sortedSchedule), len(rooms))
+
+	return timeline
+}
+
 // formatSpeakers formats speaker list for display
 func formatSpeakers(speakers []string) string {
 	if len(speakers) == 0 {
@@ -533,17 +2194,23 @@ func GetNextSession(sessionID string) (map[string]any, error) {
 
 // GetNextSessionWithTime returns next session information with injectable time provider
 func GetNextSessionWithTime(sessionID string, timeProvider TimeProvider) (map[string]any, error) {
-	state := GetUserState(sessionID)
+	return GetNextSessionWithVerbosity(sessionID, timeProvider, VerbosityNormal)
+}
+
+// GetNextSessionWithVerbosity returns next session information with the given level of detail
+func GetNextSessionWithVerbosity(sessionID string, timeProvider TimeProvider, verbosity Verbosity) (map[string]any, error) {
+	// Work on a snapshot: status analysis must not race with concurrent schedule updates
+	state := GetUserStateSnapshot(sessionID)
 	if state == nil {
 		return nil, fmt.Errorf("session %s not found", sessionID)
 	}
 
-	// Get current time from provider
-	now := timeProvider.Now()
+	// Get current time from provider, as conference wall-clock time
+	now := timeProvider.Now().In(conferenceLocation())
 
 	// Check if within COSCUP period
 	if !isInCOSCUPPeriod(now) {
-		return buildOutsideCOSCUPPeriodResponse(), nil
+		return buildOutsideCOSCUPPeriodResponse(now), nil
 	}
 
 	// If no schedule planned yet
@@ -560,28 +2227,32 @@ func GetNextSessionWithTime(sessionID string, timeProvider TimeProvider) (map[st
 
 	switch currentStatus.Status {
 	case "ongoing":
-		return buildOngoingResponse(currentStatus), nil
+		return applyStatusVerbosity(buildOngoingResponse(currentStatus), currentStatus, verbosity), nil
 	case "break":
-		return buildBreakResponse(currentStatus), nil
+		return applyStatusVerbosity(buildBreakResponse(currentStatus), currentStatus, verbosity), nil
 	case "just_ended":
-		return buildJustEndedResponse(currentStatus), nil
+		return applyStatusVerbosity(buildJustEndedResponse(currentStatus), currentStatus, verbosity), nil
+	case "lunch_break":
+		return applyStatusVerbosity(buildLunchResponse(currentStatus), currentStatus, verbosity), nil
 	case "schedule_complete":
-		// Check if user has manually finished planning
-		if state.IsCompleted {
-			return buildCompleteResponse(currentStatus), nil
+		// Check if user has manually finished planning, or it was finished for them
+		if state.IsCompleted || AutoFinishIfComplete(sessionID) {
+			return buildCompleteResponse(currentStatus, completeReason(state.Day, now, true)), nil
 		}
 
 		// Before returning complete status, check if there are still sessions available to choose
-		nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, state.Schedule)
-		if len(nextSessions) > 0 {
+		// Skip the nudge late in the day when another session can no longer fit
+		nextSessions := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, activeSessions(state.Schedule))
+		if len(nextSessions) > 0 && isPlanningStillUseful(currentTime) {
 			// There are still sessions available, suggest continuing planning
+			recordPlanningOffer(sessionID, len(state.Schedule))
 			return map[string]any{
 				"status":             "planning_available",
 				"message":            fmt.Sprintf("您目前已安排 %d 個議程，結束時間是 %s。系統發現還有 %d 個時段可以選擇更多議程。\n\n**重要提示給 LLM：請主動詢問用戶：**\n1. 是否滿意目前的規劃想要結束？請使用 finish_planning 工具\n2. 還是想要查看更多議程選項？請使用 get_options 工具\n\n請根據用戶回應採取相應行動，主動引導用戶做出選擇，不要讓用戶自己決定使用哪個工具。", len(state.Schedule), state.LastEndTime, len(nextSessions)),
 				"available_sessions": len(nextSessions),
 			}, nil
 		}
-		return buildCompleteResponse(currentStatus), nil
+		return buildCompleteResponse(currentStatus, completeReason(state.Day, now, false)), nil
 	default:
 		return map[string]any{
 			"status":  "unknown",
@@ -590,44 +2261,325 @@ func GetNextSessionWithTime(sessionID string, timeProvider TimeProvider) (map[st
 	}
 }
 
-// TimeProvider interface for time dependency injection (used in tests)
-type TimeProvider interface {
-	Now() time.Time
+// isPlanningStillUseful reports whether a minimum-length session can still fit before the conference ends
+func isPlanningStillUseful(currentTime string) bool {
+	return timeToMinutes(currentTime)+MinSessionMinutes <= ConferenceEndHour*60
+}
+
+// TimeProvider interface for time dependency injection (used in tests)
+type TimeProvider interface {
+	Now() time.Time
+}
+
+// RealTimeProvider implements TimeProvider using actual system time
+type RealTimeProvider struct{}
+
+func (r *RealTimeProvider) Now() time.Time {
+	// Return actual current time in the conference timezone
+	return conferenceNow()
+}
+
+// MockTimeProvider for testing with custom time
+type MockTimeProvider struct {
+	fixedTime time.Time
+}
+
+func (m *MockTimeProvider) Now() time.Time {
+	return m.fixedTime
+}
+
+// conferenceTZ is a fixed zone so that exports don't depend on tzdata being installed
+var conferenceTZ = time.FixedZone(ConferenceTimezone, ConferenceUTCOffsetSecs)
+
+// conferenceLocation returns the timezone all session times are expressed in
+func conferenceLocation() *time.Location {
+	return conferenceTZ
+}
+
+// conferenceNow returns the current time in the conference timezone
+func conferenceNow() time.Time {
+	return time.Now().In(conferenceLocation())
+}
+
+// Helper functions for time handling
+// All helpers convert to the conference timezone first, so callers may pass times in any zone
+func formatTimeForSession(t time.Time) string {
+	return t.In(conferenceLocation()).Format("15:04")
+}
+
+// COSCUPConfig holds the conference dates; the first day maps to DayAug9 and the second to DayAug10
+type COSCUPConfig struct {
+	Year  int
+	Month time.Month
+	Day1  int
+	Day2  int
+}
+
+// coscupConfig is the active conference calendar, replaced with SetCOSCUPConfig
+var coscupConfig = DefaultCOSCUPConfig()
+
+// DefaultCOSCUPConfig returns the COSCUP 2025 dates
+func DefaultCOSCUPConfig() COSCUPConfig {
+	return COSCUPConfig{Year: COSCUPYear, Month: COSCUPMonth, Day1: COSCUPDay1, Day2: COSCUPDay2}
+}
+
+// LoadCOSCUPConfigFromEnv reads the conference dates from COSCUP_YEAR, COSCUP_MONTH, COSCUP_DAY1 and COSCUP_DAY2
+// Unset variables keep their default; malformed values or impossible dates wrap ErrInvalidCOSCUPConfig
+func LoadCOSCUPConfigFromEnv() (COSCUPConfig, error) {
+	config := DefaultCOSCUPConfig()
+	month := int(config.Month)
+
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{
+		{"COSCUP_YEAR", &config.Year},
+		{"COSCUP_MONTH", &month},
+		{"COSCUP_DAY1", &config.Day1},
+		{"COSCUP_DAY2", &config.Day2},
+	} {
+		raw := os.Getenv(field.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return config, fmt.Errorf("%w: %s=%q is not a number", ErrInvalidCOSCUPConfig, field.name, raw)
+		}
+		*field.value = value
+	}
+	config.Month = time.Month(month)
+
+	if err := config.validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// validate checks that both days are real dates and the second day comes after the first
+func (c COSCUPConfig) validate() error {
+	if c.Month < time.January || c.Month > time.December {
+		return fmt.Errorf("%w: month %d", ErrInvalidCOSCUPConfig, c.Month)
+	}
+	for _, day := range []int{c.Day1, c.Day2} {
+		if c.date(day).Day() != day {
+			return fmt.Errorf("%w: %d-%02d-%02d is not a date", ErrInvalidCOSCUPConfig, c.Year, c.Month, day)
+		}
+	}
+	if c.Day2 <= c.Day1 {
+		return fmt.Errorf("%w: second day %d must come after first day %d", ErrInvalidCOSCUPConfig, c.Day2, c.Day1)
+	}
+	return nil
+}
+
+// date returns midnight of the given day of the conference month in the conference timezone
+func (c COSCUPConfig) date(day int) time.Time {
+	return time.Date(c.Year, c.Month, day, 0, 0, 0, 0, conferenceLocation())
+}
+
+// dateLabel formats the conference days for messages, e.g. "8月9-10日"
+func (c COSCUPConfig) dateLabel() string {
+	return fmt.Sprintf("%d月%d-%d日", c.Month, c.Day1, c.Day2)
+}
+
+// SetCOSCUPConfig replaces the conference dates
+func SetCOSCUPConfig(config COSCUPConfig) {
+	coscupConfig = config
+}
+
+func getCOSCUPDay(t time.Time) string {
+	t = t.In(conferenceLocation())
+	if t.Year() != coscupConfig.Year || t.Month() != coscupConfig.Month {
+		return StatusOutsideCOSCUP
+	}
+	switch t.Day() {
+	case coscupConfig.Day1:
+		return DayAug9
+	case coscupConfig.Day2:
+		return DayAug10
+	}
+	return StatusOutsideCOSCUP
+}
+
+// OutsideCOSCUPDefaultDays maps a conference phase to the day (user format) queried outside COSCUP:
+// the first day before the event, when people are previewing it, and the last day afterwards, when the
+// most recent talks are the likeliest to be looked up; replace entries at startup to change the defaults
+var OutsideCOSCUPDefaultDays = map[string]string{
+	ConferencePhaseBefore: DayAug9,
+	ConferencePhaseAfter:  DayAug10,
+}
+
+// getQueryDay returns the COSCUP day (user format) for data lookups at time t
+// Outside the conference it falls back to the phase's entry in OutsideCOSCUPDefaultDays, or to
+// DefaultOutsideCOSCUPDay when there is no valid entry, so it never returns StatusOutsideCOSCUP
+func getQueryDay(t time.Time) string {
+	day := getCOSCUPDay(t)
+	if day != StatusOutsideCOSCUP {
+		return day
+	}
+	if phaseDay := OutsideCOSCUPDefaultDays[conferencePhase(t)]; IsValidDay(phaseDay) {
+		return phaseDay
+	}
+	return DefaultOutsideCOSCUPDay
+}
+
+func isInCOSCUPPeriod(t time.Time) bool {
+	return getCOSCUPDay(t) != StatusOutsideCOSCUP
+}
+
+// dayMidnight returns the start of the given internal day in the conference timezone
+func dayMidnight(day string) time.Time {
+	if day == DayFormatAug10 {
+		return coscupConfig.date(coscupConfig.Day2)
+	}
+	return coscupConfig.date(coscupConfig.Day1)
+}
+
+// sessionEndTime returns when a session of the given internal day ends, in the conference timezone
+func sessionEndTime(day string, session Session) time.Time {
+	return dayMidnight(day).Add(time.Duration(endTimeToMinutes(session.Start, session.End)) * time.Minute)
+}
+
+// RemainingConferenceTime returns how many minutes of conference hours are left after the user's plan,
+// counted from the later of the last planned session's end and now, up to ConferenceEndHour of the planned day
+// An empty plan counts from ConferenceStartHour. Returns 0 for unknown sessions or once the day's hours are over
+func RemainingConferenceTime(sessionID string, now time.Time) int {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return 0
+	}
+
+	planEndMinutes := ConferenceStartHour * 60
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			planEndMinutes = max(planEndMinutes, endTimeToMinutes(session.Start, session.End))
+		}
+	}
+
+	dayStart := dayMidnight(state.Day)
+	from := dayStart.Add(time.Duration(planEndMinutes) * time.Minute)
+	if now.After(from) {
+		from = now
+	}
+	conferenceEnd := dayStart.Add(ConferenceEndHour * time.Hour)
+	return max(0, int(conferenceEnd.Sub(from).Minutes()))
+}
+
+// UpcomingSession is a planned session together with the route to it from the session before
+type UpcomingSession struct {
+	Session Session    `json:"session"`
+	Route   *RouteInfo `json:"route,omitempty"`
 }
 
-// RealTimeProvider implements TimeProvider using actual system time
-type RealTimeProvider struct{}
+// SummarizeRemainingDay gathers what the rest of the user's planned day looks like at now: the current status,
+// the next MaxSummaryNextSessions sessions with their routes, the walking still ahead and when the plan and the
+// conference hours end. Cancelled sessions are left out. Returns nil when the session does not exist
+func SummarizeRemainingDay(sessionID string, now time.Time) map[string]any {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
 
-func (r *RealTimeProvider) Now() time.Time {
-	// Return actual current time
-	return time.Now()
-}
+	currentTime := formatTimeForSession(now)
+	currentMinutes := timeToMinutes(currentTime)
 
-// MockTimeProvider for testing with custom time
-type MockTimeProvider struct {
-	fixedTime time.Time
-}
+	var active []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			active = append(active, session)
+		}
+	}
+	sortSessionsByStartTime(active)
+	activeState := *state
+	activeState.Schedule = active
+
+	// The latest session that already started is where the user walks from next
+	var previous *Session
+	var upcoming []Session
+	for i := range active {
+		if timeToMinutes(active[i].Start) <= currentMinutes {
+			previous = &active[i]
+		} else {
+			upcoming = append(upcoming, active[i])
+		}
+	}
 
-func (m *MockTimeProvider) Now() time.Time {
-	return m.fixedTime
+	walkingMinutes := 0
+	from := previous
+	nextSessions := []UpcomingSession{}
+	for i := range upcoming {
+		next := &upcoming[i]
+		if from != nil {
+			walkingMinutes += scaleWalkingTime(transferWalkingTime(from.Room, next.Room), state.Mobility)
+		}
+		if len(nextSessions) < MaxSummaryNextSessions {
+			availableMinutes := timeToMinutes(next.Start) - currentMinutes
+			if from != nil {
+				availableMinutes = min(availableMinutes, timeToMinutes(next.Start)-endTimeToMinutes(from.Start, from.End))
+			}
+			nextSessions = append(nextSessions, UpcomingSession{Session: *next, Route: userRoute(state, from, next, availableMinutes)})
+		}
+		from = next
+	}
+
+	summary := map[string]any{
+		"day":                       toUserDayFormat(state.Day),
+		"current_time":              currentTime,
+		"status":                    analyzeCurrentStatus(&activeState, currentTime).Status,
+		"next_sessions":             nextSessions,
+		"remaining_sessions":        len(upcoming),
+		"remaining_walking_minutes": walkingMinutes,
+		"conference_end":            minutesToTime(ConferenceEndHour * 60),
+	}
+	if previous != nil && currentMinutes < endTimeToMinutes(previous.Start, previous.End) {
+		summary["current_session"] = *previous
+	}
+	if len(active) > 0 {
+		last := active[len(active)-1]
+		planEnd := endTimeToMinutes(last.Start, last.End)
+		summary["plan_end"] = minutesToTime(planEnd)
+		summary["finished"] = currentMinutes >= planEnd
+	}
+	return summary
 }
 
-// Helper functions for time handling
-func formatTimeForSession(t time.Time) string {
-	return t.Format("15:04")
+// MissedSince returns the planned sessions that ended after since and before now, sorted by start time,
+// so the assistant can recap what the user missed while away (e.g. since their LastActivity)
+func MissedSince(sessionID string, since time.Time) []Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+	return missedBetween(state, since, conferenceNow())
 }
 
-func getCOSCUPDay(t time.Time) string {
-	if t.Year() == COSCUPYear && t.Month() == COSCUPMonth && t.Day() == COSCUPDay1 {
-		return DayAug9
-	} else if t.Year() == COSCUPYear && t.Month() == COSCUPMonth && t.Day() == COSCUPDay2 {
-		return DayAug10
+// missedBetween returns the non-cancelled scheduled sessions that ended in (since, now]
+func missedBetween(state *UserState, since, now time.Time) []Session {
+	var missed []Session
+	for _, session := range state.Schedule {
+		if session.Cancelled {
+			continue
+		}
+		end := sessionEndTime(state.Day, session)
+		if end.After(since) && !end.After(now) {
+			missed = append(missed, session)
+		}
 	}
-	return StatusOutsideCOSCUP
+	sortSessionsByStartTime(missed)
+	return missed
 }
 
-func isInCOSCUPPeriod(t time.Time) bool {
-	return t.Year() == COSCUPYear && t.Month() == COSCUPMonth && (t.Day() == COSCUPDay1 || t.Day() == COSCUPDay2)
+// lastActivityOf returns when the session was last used, without counting this lookup as activity
+func lastActivityOf(sessionID string) (time.Time, bool) {
+	shard := sessionShards[getShardIndex(sessionID)]
+	shard.rlock()
+	defer shard.mu.RUnlock()
+
+	state, exists := shard.sessions[sessionID]
+	if !exists {
+		return time.Time{}, false
+	}
+	return state.LastActivity, true
 }
 
 // SessionStatus represents current session status
@@ -638,6 +2590,9 @@ type SessionStatus struct {
 	RemainingMinutes int
 	BreakMinutes     int
 	Route            *RouteInfo
+	FillInOptions    []Session // Sessions that fit into a long break
+
+	MinutesUntilNextStart int // Countdown to the next session's start, never negative
 }
 
 // RouteInfo represents route between venues
@@ -647,6 +2602,8 @@ type RouteInfo struct {
 	WalkingTime int // minutes
 	RouteDesc   string
 	EnoughTime  bool
+
+	RequiredTime int // Walking time plus ArrivalBufferMinutes to settle in, 0 when staying in the same room
 }
 
 // analyzeCurrentStatus analyzes user's current status
@@ -663,7 +2620,7 @@ func analyzeCurrentStatus(state *UserState, currentTime string) *SessionStatus {
 
 	for i, session := range sortedSchedule {
 		startMin := timeToMinutes(session.Start)
-		endMin := timeToMinutes(session.End)
+		endMin := endTimeToMinutes(session.Start, session.End)
 
 		// Check if currently in this session
 		if currentMinutes >= startMin && currentMinutes < endMin {
@@ -672,13 +2629,17 @@ func analyzeCurrentStatus(state *UserState, currentTime string) *SessionStatus {
 				nextSession = &sortedSchedule[i+1]
 			}
 
-			return &SessionStatus{
+			status := &SessionStatus{
 				Status:           "ongoing",
 				CurrentSession:   currentSession,
 				NextSession:      nextSession,
 				RemainingMinutes: endMin - currentMinutes,
-				Route:            calculateRoute(currentSession, nextSession),
 			}
+			if nextSession != nil {
+				status.MinutesUntilNextStart = minutesUntil(currentTime, nextSession.Start)
+				status.Route = userRoute(state, currentSession, nextSession, timeToMinutes(nextSession.Start)-endMin)
+			}
+			return status
 		}
 
 		// Check if this is the next session
@@ -686,29 +2647,48 @@ func analyzeCurrentStatus(state *UserState, currentTime string) *SessionStatus {
 			nextSession = &session
 
 			// Find if there was a previous session that just ended
+			// A long gap over lunchtime is a lunch break from start to end, whatever the time within it
 			var prevSession *Session
+			lunchGap := false
 			if i > 0 {
 				prevSession = &sortedSchedule[i-1]
-				prevEndMin := timeToMinutes(prevSession.End)
+				prevEndMin := endTimeToMinutes(prevSession.Start, prevSession.End)
+				lunchGap = startMin-prevEndMin > LunchBreakMinutes && isLunchBreak(prevSession.End, nextSession.Start)
 
 				// If just ended (within 10 minutes)
-				if currentMinutes-prevEndMin <= 10 && currentMinutes >= prevEndMin {
+				if !lunchGap && currentMinutes-prevEndMin <= 10 && currentMinutes >= prevEndMin {
+					breakMinutes := minutesUntil(currentTime, nextSession.Start)
 					return &SessionStatus{
-						Status:       "just_ended",
-						NextSession:  nextSession,
-						BreakMinutes: startMin - currentMinutes,
-						Route:        calculateRoute(prevSession, nextSession),
+						Status:                "just_ended",
+						NextSession:           nextSession,
+						BreakMinutes:          breakMinutes,
+						Route:                 userRoute(state, prevSession, nextSession, breakMinutes),
+						MinutesUntilNextStart: breakMinutes,
 					}
 				}
 			}
 
 			// In break time
-			return &SessionStatus{
-				Status:       "break",
-				NextSession:  nextSession,
-				BreakMinutes: startMin - currentMinutes,
-				Route:        calculateRoute(nil, nextSession),
+			breakMinutes := minutesUntil(currentTime, nextSession.Start)
+			status := &SessionStatus{
+				Status:                "break",
+				NextSession:           nextSession,
+				BreakMinutes:          breakMinutes,
+				Route:                 userRoute(state, nil, nextSession, breakMinutes),
+				MinutesUntilNextStart: breakMinutes,
+			}
+
+			// Turn a long idle break into an opportunity
+			if status.BreakMinutes > LongGapMinutes {
+				status.FillInOptions = findBreakFillIns(state.Day, currentTime, state.Schedule, nextSession)
+			}
+
+			// A long gap over lunchtime is time to eat rather than a break to rush through
+			if lunchGap {
+				status.Status = "lunch_break"
 			}
+
+			return status
 		}
 	}
 
@@ -718,8 +2698,39 @@ func analyzeCurrentStatus(state *UserState, currentTime string) *SessionStatus {
 	}
 }
 
+// findBreakFillIns returns up to MaxBreakFillInSuggestions sessions that start now or later
+// and finish before the next scheduled session, earliest first
+func findBreakFillIns(day, currentTime string, schedule []Session, nextSession *Session) []Session {
+	candidates := filterOutSocialActivities(FindNextAvailableInEachRoom(day, currentTime, schedule))
+
+	var fillIns []Session
+	for _, candidate := range candidates {
+		if endTimeToMinutes(candidate.Start, candidate.End) <= timeToMinutes(nextSession.Start) {
+			fillIns = append(fillIns, candidate)
+		}
+	}
+
+	sortSessionsByStartTime(fillIns)
+
+	if len(fillIns) > MaxBreakFillInSuggestions {
+		fillIns = fillIns[:MaxBreakFillInSuggestions]
+	}
+	return fillIns
+}
+
+// minutesUntil returns the minutes from currentTime until startTime, clamped to zero once started
+func minutesUntil(currentTime, startTime string) int {
+	return max(0, timeToMinutes(startTime)-timeToMinutes(currentTime))
+}
+
+// isLunchBreak reports whether a break between two "HH:MM" times overlaps the lunch window
+func isLunchBreak(start, end string) bool {
+	return timeToMinutes(start) < LunchWindowEndMinutes && timeToMinutes(end) > LunchWindowStartMinutes
+}
+
 // calculateRoute calculates route information between sessions
-func calculateRoute(fromSession, toSession *Session) *RouteInfo {
+// EnoughTime tells whether breakMinutes leaves room for the walk, see isTransferFeasible
+func calculateRoute(fromSession, toSession *Session, breakMinutes int) *RouteInfo {
 	if toSession == nil {
 		return nil
 	}
@@ -745,16 +2756,72 @@ func calculateRoute(fromSession, toSession *Session) *RouteInfo {
 	walkingTime := calculateWalkingTime(fromRoom, toRoom)
 	routeDesc := generateRouteDescription(fromRoom, toRoom)
 
-	return &RouteInfo{
-		FromRoom:    fromRoom,
-		ToRoom:      toRoom,
-		WalkingTime: walkingTime,
-		RouteDesc:   routeDesc,
-		EnoughTime:  true, // We'll calculate this based on break time in the calling function
+	route := &RouteInfo{
+		FromRoom:     fromRoom,
+		ToRoom:       toRoom,
+		WalkingTime:  walkingTime,
+		RouteDesc:    routeDesc,
+		RequiredTime: walkingTime + ArrivalBufferMinutes,
+	}
+	route.EnoughTime = isTransferFeasible(route, breakMinutes)
+	return route
+}
+
+// mobilityWalkFactors scales base walking times per mobility setting; missing settings use the base times
+var mobilityWalkFactors = map[Mobility]float64{
+	MobilityFast: 0.75,
+	MobilitySlow: 1.5,
+}
+
+// parseMobility validates a mobility setting
+func parseMobility(value string) (Mobility, bool) {
+	switch Mobility(value) {
+	case MobilityFast, MobilityNormal, MobilitySlow:
+		return Mobility(value), true
+	default:
+		return "", false
+	}
+}
+
+// scaleWalkingTime adjusts a base walking time to the user's mobility, rounding up so a move never
+// drops to zero minutes
+func scaleWalkingTime(minutes int, mobility Mobility) int {
+	factor, exists := mobilityWalkFactors[mobility]
+	if !exists || minutes == 0 {
+		return minutes
+	}
+	return int(math.Ceil(float64(minutes) * factor))
+}
+
+// userRoute calculates the route between sessions with walking times scaled to the user's mobility
+func userRoute(state *UserState, fromSession, toSession *Session, breakMinutes int) *RouteInfo {
+	route := calculateRoute(fromSession, toSession, breakMinutes)
+	if route == nil || route.WalkingTime == 0 {
+		return route
+	}
+	route.WalkingTime = scaleWalkingTime(route.WalkingTime, state.Mobility)
+	route.RequiredTime = route.WalkingTime + ArrivalBufferMinutes
+	route.EnoughTime = isTransferFeasible(route, breakMinutes)
+	return route
+}
+
+// isTightConnection reports whether a route cannot be walked in the time available
+func isTightConnection(route *RouteInfo) bool {
+	return route != nil && !route.EnoughTime
+}
+
+// isTransferFeasible reports whether a break is long enough to walk the route and still settle in
+// before the next session starts
+func isTransferFeasible(route *RouteInfo, breakMinutes int) bool {
+	if route == nil || route.WalkingTime == 0 {
+		return true
 	}
+	return breakMinutes >= route.WalkingTime+ArrivalBufferMinutes
 }
 
 // getBuildingFromRoom returns building code from room name
+// Other rooms named like "OT101" or "OT-101" map to their letter prefix, so a new building only
+// needs a RegisterBuilding call rather than another special case here
 func getBuildingFromRoom(room string) string {
 	if room == BuildingAU || room == "AU101" {
 		return BuildingAU
@@ -765,9 +2832,176 @@ func getBuildingFromRoom(room string) string {
 	if len(room) >= 2 && room[:2] == BuildingTR {
 		return BuildingTR
 	}
+	if match := roomPrefixPattern.FindStringSubmatch(room); match != nil {
+		return match[1]
+	}
 	return "Unknown"
 }
 
+// roomPrefixPattern matches a 2-3 letter building prefix followed by a room number
+var roomPrefixPattern = regexp.MustCompile(`^([A-Z]{2,3})-?\d`)
+
+// BuildingInfo is the routing configuration of a venue building
+type BuildingInfo struct {
+	Name          string         // Display name used in route descriptions
+	EntryTime     int            // Minutes from the campus gate to the building
+	WalkTimes     map[string]int // Minutes to other building codes, including itself
+	WalkDistances map[string]int // Meters to other building codes, including itself
+}
+
+// venueBuildings is the building registry used for routing, keyed by building code
+var venueBuildings = map[string]BuildingInfo{
+	BuildingAU: {
+		Name:          "視聽館",
+		EntryTime:     AUEntryTime,
+		WalkTimes:     map[string]int{BuildingAU: SameBuildingWalkTime, BuildingRB: AUToRBWalkTime, BuildingTR: AUToTRWalkTime},
+		WalkDistances: map[string]int{BuildingAU: SameBuildingWalkDistance, BuildingRB: AUToRBWalkDistance, BuildingTR: AUToTRWalkDistance},
+	},
+	BuildingRB: {
+		Name:          "綜合研究大樓",
+		EntryTime:     RBEntryTime,
+		WalkTimes:     map[string]int{BuildingAU: RBToAUWalkTime, BuildingRB: RBToRBWalkTime, BuildingTR: RBToTRWalkTime},
+		WalkDistances: map[string]int{BuildingAU: RBToAUWalkDistance, BuildingRB: RBToRBWalkDistance, BuildingTR: RBToTRWalkDistance},
+	},
+	BuildingTR: {
+		Name:          "研揚大樓",
+		EntryTime:     TREntryTime,
+		WalkTimes:     map[string]int{BuildingAU: TRToAUWalkTime, BuildingRB: TRToRBWalkTime, BuildingTR: TRInternalWalkTime},
+		WalkDistances: map[string]int{BuildingAU: TRToAUWalkDistance, BuildingRB: TRToRBWalkDistance, BuildingTR: TRInternalWalkDistance},
+	},
+}
+
+// RegisterBuilding adds or replaces a building in the registry
+// Walk times and distances only need to be listed on one side; lookups fall back to the reverse direction
+func RegisterBuilding(code string, info BuildingInfo) {
+	venueBuildings[code] = info
+}
+
+// VenueBuilding describes a building for visitors, as opposed to BuildingInfo which drives routing
+// GetVenueInfo fills Name from the routing registry, so building names are only defined in venueBuildings;
+// a Name given here is only used for buildings missing from the registry
+type VenueBuilding struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	EnglishName string   `json:"english_name"`
+	Floors      []int    `json:"floors"`
+	Facilities  []string `json:"facilities"`
+}
+
+// VenueInfo is the visitor-facing venue description served by get_venue_map
+type VenueInfo struct {
+	MapURL         string          `json:"venue_map_url"`
+	MapFeatures    []string        `json:"map_features"`
+	Buildings      []VenueBuilding `json:"buildings"`
+	NavigationTips []string        `json:"navigation_tips"`
+	FoodAreas      []string        `json:"food_areas"`
+}
+
+// defaultVenueInfo describes the COSCUP 2025 venue, served until ReloadVenueInfo replaces it
+var defaultVenueInfo = VenueInfo{
+	MapURL: "https://coscup.org/2025/venue/",
+	MapFeatures: []string{
+		"Interactive campus map",
+		"Building locations and layouts",
+		"Room numbers and capacity",
+		"Parking areas and entrances",
+		"Accessible routes and facilities",
+		"Food courts and rest areas",
+	},
+	Buildings: []VenueBuilding{
+		{
+			Code:        BuildingAU,
+			EnglishName: "Audio-Visual Hall",
+			Floors:      []int{1},
+			Facilities:  []string{"Main auditorium for keynotes", "Registration desk nearby"},
+		},
+		{
+			Code:        BuildingRB,
+			EnglishName: "Research Building",
+			Floors:      []int{1},
+			Facilities:  []string{"Ground-floor session rooms", "Restrooms"},
+		},
+		{
+			Code:        BuildingTR,
+			EnglishName: "TR Building",
+			Floors:      []int{2, 3, 4, 5},
+			Facilities:  []string{"Session rooms on every floor", "Elevators and stairs", "Hallway activity areas on floors 3 and 4", "Restrooms"},
+		},
+	},
+	NavigationTips: []string{
+		"Use building codes (AU, RB, TR) to identify locations",
+		"Check room numbers - first digits indicate floor",
+		"Follow directional signs throughout campus",
+		"Ask volunteers wearing COSCUP shirts for assistance",
+	},
+	FoodAreas: []string{
+		"Food stalls next to the booth area",
+		"Campus cafeteria",
+		"Restaurants and convenience stores just outside campus",
+	},
+}
+
+// currentVenueInfo is the venue description served by GetVenueInfo, published atomically like sessionData
+var currentVenueInfo atomic.Pointer[VenueInfo]
+
+func init() {
+	currentVenueInfo.Store(&defaultVenueInfo)
+}
+
+// GetVenueInfo returns a copy of the venue description
+func GetVenueInfo() VenueInfo {
+	current := currentVenueInfo.Load()
+	info := *current
+	info.MapFeatures = slices.Clone(current.MapFeatures)
+	info.NavigationTips = slices.Clone(current.NavigationTips)
+	info.FoodAreas = slices.Clone(current.FoodAreas)
+	info.Buildings = make([]VenueBuilding, len(current.Buildings))
+	for i, building := range current.Buildings {
+		if registered, exists := venueBuildings[building.Code]; exists {
+			building.Name = registered.Name
+		}
+		building.Floors = slices.Clone(building.Floors)
+		building.Facilities = slices.Clone(building.Facilities)
+		info.Buildings[i] = building
+	}
+	return info
+}
+
+// VenueBuildingLabels maps each building code to "Name (EnglishName)", the original get_venue_map buildings shape
+func VenueBuildingLabels(buildings []VenueBuilding) map[string]string {
+	labels := make(map[string]string, len(buildings))
+	for _, building := range buildings {
+		labels[building.Code] = building.Name
+		if building.EnglishName != "" {
+			labels[building.Code] = fmt.Sprintf("%s (%s)", building.Name, building.EnglishName)
+		}
+	}
+	return labels
+}
+
+// ReloadVenueInfo replaces the venue description, e.g. when the venue changes between years
+// Requests being served keep reading the old description until the new one is published
+// The caller must not modify info afterwards
+func ReloadVenueInfo(info VenueInfo) {
+	currentVenueInfo.Store(&info)
+	log.Printf("Reloaded venue info: %d buildings", len(info.Buildings))
+}
+
+// buildingRouteValue looks up a per-building route value from either direction
+func buildingRouteValue(fromBuilding, toBuilding string, values func(BuildingInfo) map[string]int) (int, bool) {
+	if info, exists := venueBuildings[fromBuilding]; exists {
+		if value, exists := values(info)[toBuilding]; exists {
+			return value, true
+		}
+	}
+	if info, exists := venueBuildings[toBuilding]; exists {
+		if value, exists := values(info)[fromBuilding]; exists {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
 // calculateWalkingTime returns estimated walking time in minutes between rooms
 // WARNING: These are rough estimates only. Actual travel time may be longer due to:
 // - Crowded hallways during session breaks
@@ -778,36 +3012,310 @@ func calculateWalkingTime(fromRoom, toRoom string) int {
 	fromBuilding := getBuildingFromRoom(fromRoom)
 	toBuilding := getBuildingFromRoom(toRoom)
 
-	// Estimated walking times between buildings (minutes)
 	// NOTE: These are conservative estimates and actual time may vary
-	walkingTimes := map[string]map[string]int{
-		BuildingAU: {BuildingAU: SameBuildingWalkTime, BuildingRB: AUToRBWalkTime, BuildingTR: AUToTRWalkTime},
-		BuildingRB: {BuildingAU: RBToAUWalkTime, BuildingRB: RBToRBWalkTime, BuildingTR: RBToTRWalkTime},
-		BuildingTR: {BuildingAU: TRToAUWalkTime, BuildingRB: TRToRBWalkTime, BuildingTR: TRInternalWalkTime},
+	walkTimes := func(info BuildingInfo) map[string]int { return info.WalkTimes }
+	if time, exists := buildingRouteValue(fromBuilding, toBuilding, walkTimes); exists {
+		return time
+	}
+
+	return UnknownWalkTime // Default safe estimate
+}
+
+// transferWalkingTime returns the walking time between consecutive sessions, 0 when staying in the same room
+func transferWalkingTime(fromRoom, toRoom string) int {
+	if fromRoom == toRoom {
+		return 0
+	}
+	return calculateWalkingTime(fromRoom, toRoom)
+}
+
+// gapWalkingTime returns the walking time shown on timeline gaps, 0 within the same building:
+// a change of floor is not worth annotating, while routing keeps the intra-building walk
+func gapWalkingTime(fromRoom, toRoom string) int {
+	if building := getBuildingFromRoom(fromRoom); building != "Unknown" && building == getBuildingFromRoom(toRoom) {
+		return 0
+	}
+	return transferWalkingTime(fromRoom, toRoom)
+}
+
+// calculateWalkingDistance returns estimated walking distance in meters between rooms
+func calculateWalkingDistance(fromRoom, toRoom string) int {
+	if fromRoom == toRoom {
+		return 0
+	}
+
+	fromBuilding := getBuildingFromRoom(fromRoom)
+	toBuilding := getBuildingFromRoom(toRoom)
+
+	walkDistances := func(info BuildingInfo) map[string]int { return info.WalkDistances }
+	if distance, exists := buildingRouteValue(fromBuilding, toBuilding, walkDistances); exists {
+		return distance
+	}
+
+	return UnknownWalkDistance
+}
+
+// EstimateWalkingDistance returns the approximate total walking distance in meters
+// for moving between consecutive sessions of the user's schedule
+func EstimateWalkingDistance(sessionID string) int {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return 0
+	}
+
+	sortedSchedule := state.Schedule
+	sortSessionsByStartTime(sortedSchedule)
+
+	total := 0
+	for i := 1; i < len(sortedSchedule); i++ {
+		total += calculateWalkingDistance(sortedSchedule[i-1].Room, sortedSchedule[i].Room)
+	}
+	return total
+}
+
+// AssessPlanDensity counts how many consecutive transfers in the user's schedule are tight,
+// i.e. the break minus the walking time leaves at most TightTransferBufferMinutes of slack
+// Staying in the same room is never tight
+func AssessPlanDensity(sessionID string) (tightTransfers int, total int) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return 0, 0
 	}
+	return planDensity(state)
+}
 
-	if times, exists := walkingTimes[fromBuilding]; exists {
-		if time, exists := times[toBuilding]; exists {
-			return time
+// planDensity computes AssessPlanDensity for a state
+func planDensity(state *UserState) (tightTransfers int, total int) {
+	sortedSchedule := slices.Clone(state.Schedule)
+	sortSessionsByStartTime(sortedSchedule)
+
+	for i := 1; i < len(sortedSchedule); i++ {
+		prev, next := sortedSchedule[i-1], sortedSchedule[i]
+		total++
+
+		if prev.Room == next.Room {
+			continue
+		}
+
+		breakMinutes := timeToMinutes(next.Start) - endTimeToMinutes(prev.Start, prev.End)
+		if breakMinutes-scaleWalkingTime(calculateWalkingTime(prev.Room, next.Room), state.Mobility) <= TightTransferBufferMinutes {
+			tightTransfers++
 		}
 	}
 
-	return UnknownWalkTime // Default safe estimate
+	return tightTransfers, total
 }
 
-// generateRouteDescription generates human-readable route description
-func generateRouteDescription(fromRoom, toRoom string) string {
-	buildingNames := map[string]string{
-		"AU": "視聽館",
-		"RB": "綜合研究大樓",
-		"TR": "研揚大樓",
+// transferFits reports whether next can be reached after prev ends: the break must cover the
+// (mobility-scaled) walk plus ArrivalBufferMinutes, or just not overlap when staying in the same room
+func transferFits(prev, next Session, mobility Mobility) bool {
+	breakMinutes := timeToMinutes(next.Start) - endTimeToMinutes(prev.Start, prev.End)
+	walk := scaleWalkingTime(transferWalkingTime(prev.Room, next.Room), mobility)
+	if walk == 0 {
+		return breakMinutes >= 0
+	}
+	return breakMinutes >= walk+ArrivalBufferMinutes
+}
+
+// SuggestFeasibleSubset proposes the largest part of the user's schedule in which every transfer is feasible,
+// i.e. each break covers the (mobility-scaled) walk plus ArrivalBufferMinutes, so the fewest sessions are dropped
+// Cancelled sessions are left out. The schedule itself is not changed
+func SuggestFeasibleSubset(sessionID string) []Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	var sessions []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessionsByStartTime(sessions)
+
+	canFollow := func(prev, next Session) bool {
+		return transferFits(prev, next, state.Mobility)
+	}
+
+	// Longest chain of sessions where each one can follow the previous
+	chainLength := make([]int, len(sessions))
+	previous := make([]int, len(sessions))
+	best := -1
+	for i := range sessions {
+		chainLength[i], previous[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if chainLength[j]+1 > chainLength[i] && canFollow(sessions[j], sessions[i]) {
+				chainLength[i], previous[i] = chainLength[j]+1, j
+			}
+		}
+		if best == -1 || chainLength[i] > chainLength[best] {
+			best = i
+		}
+	}
+
+	var subset []Session
+	for i := best; i >= 0; i = previous[i] {
+		subset = append(subset, sessions[i])
+	}
+	slices.Reverse(subset)
+	return subset
+}
+
+// ImpossibleTransfer is a move between adjacent scheduled sessions whose gap is shorter than the walk
+type ImpossibleTransfer struct {
+	FromCode       string `json:"from_code"`
+	ToCode         string `json:"to_code"`
+	GapMinutes     int    `json:"gap_minutes"`
+	WalkingMinutes int    `json:"walking_minutes"`
+	DeficitMinutes int    `json:"deficit_minutes"`
+}
+
+// FindImpossibleTransfers lists every move between adjacent scheduled sessions that cannot be walked
+// at all, i.e. the gap is shorter than the (mobility-scaled) walking time, with how many minutes are missing
+// Unlike transferFits no arrival buffer is counted. Cancelled sessions are left out. Returns nil when every move works
+func FindImpossibleTransfers(sessionID string) []ImpossibleTransfer {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	var sessions []Session
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessionsByStartTime(sessions)
+
+	var transfers []ImpossibleTransfer
+	for i := 1; i < len(sessions); i++ {
+		prev, next := sessions[i-1], sessions[i]
+		walk := scaleWalkingTime(transferWalkingTime(prev.Room, next.Room), state.Mobility)
+		gap := timeToMinutes(next.Start) - endTimeToMinutes(prev.Start, prev.End)
+		if walk > 0 && gap < walk {
+			transfers = append(transfers, ImpossibleTransfer{
+				FromCode:       prev.Code,
+				ToCode:         next.Code,
+				GapMinutes:     gap,
+				WalkingMinutes: walk,
+				DeficitMinutes: walk - gap,
+			})
+		}
+	}
+
+	return transfers
+}
+
+// impossibleTransfersWarning returns an advisory message listing transfers that cannot be walked, or "" when there are none
+func impossibleTransfersWarning(transfers []ImpossibleTransfer) string {
+	if len(transfers) == 0 {
+		return ""
+	}
+	var moves []string
+	for _, transfer := range transfers {
+		moves = append(moves, fmt.Sprintf("%s → %s 差 %d 分鐘", transfer.FromCode, transfer.ToCode, transfer.DeficitMinutes))
+	}
+	return fmt.Sprintf(" ⚠️ 有 %d 次換場的間隔比步行時間還短，實際上趕不上：%s。議程已保留，請提醒用戶勢必會遲到或提早離場，或考慮換掉其中一場。",
+		len(transfers), strings.Join(moves, "、"))
+}
+
+// planDensityWarning returns an advisory message when most transfers are tight, or "" otherwise
+func planDensityWarning(tightTransfers, total int) string {
+	if total == 0 || tightTransfers*2 <= total {
+		return ""
+	}
+	return fmt.Sprintf(" ⚠️ 行程相當緊湊：%d 次移動中有 %d 次緩衝時間僅 %d 分鐘以內，請提醒用戶預留移動時間或考慮刪減部分議程。",
+		total, tightTransfers, TightTransferBufferMinutes)
+}
+
+// ReviewSchedule checks the user's schedule for common planning mistakes and returns one warning per problem:
+// transfers too short for the walk, no time for lunch, breaks longer than LongGapMinutes, and a single-track plan
+// The break picked as lunch by SuggestLunchTime is not offered for filling
+// Cancelled sessions are left out. Returns nil when nothing needs attention
+func ReviewSchedule(sessionID string) []string {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	var sessions []Session
+	tracks := make(map[string]bool)
+	for _, session := range state.Schedule {
+		if !session.Cancelled {
+			sessions = append(sessions, session)
+			if session.Track != "" {
+				tracks[session.Track] = true
+			}
+		}
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+	sortSessionsByStartTime(sessions)
+
+	lunchStart, lunchEnd, hasLunch := SuggestLunchTime(sessionID)
+
+	var warnings []string
+	for i := 1; i < len(sessions); i++ {
+		prev, next := sessions[i-1], sessions[i]
+		prevEnd := endTimeToMinutes(prev.Start, prev.End)
+		breakMinutes := timeToMinutes(next.Start) - prevEnd
+		isLunchGap := hasLunch && timeToMinutes(lunchStart) >= prevEnd && timeToMinutes(lunchEnd) <= timeToMinutes(next.Start)
+
+		if prev.Room != next.Room && !transferFits(prev, next, state.Mobility) {
+			walk := scaleWalkingTime(transferWalkingTime(prev.Room, next.Room), state.Mobility)
+			warnings = append(warnings, fmt.Sprintf("%s %s → %s %s：只有 %d 分鐘可以換場，但步行約需 %d 分鐘，很可能會遲到。",
+				prev.End, prev.Room, next.Start, next.Room, breakMinutes, walk))
+		}
+		if breakMinutes > LongGapMinutes && !isLunchGap {
+			warnings = append(warnings, fmt.Sprintf("%s-%s 有 %d 分鐘的空檔，可以考慮補上一場議程或安排逛攤位。",
+				prev.End, next.Start, breakMinutes))
+		}
+	}
+
+	if !hasLunch {
+		warnings = append(warnings, fmt.Sprintf("中午時段沒有至少 %d 分鐘的空檔可以吃午餐。", MinLunchMinutes))
+	}
+
+	if len(sessions) >= MinReviewVarietySessions && len(tracks) == 1 {
+		warnings = append(warnings, fmt.Sprintf("全部 %d 場議程都在同一個議程軌，可以考慮穿插其他主題增加多樣性。", len(sessions)))
+	}
+
+	return warnings
+}
+
+// EarliestArrivalAdvice returns the user's first scheduled session and how many minutes before
+// its start they should arrive on campus. Returns nil and 0 when the schedule is empty
+func EarliestArrivalAdvice(sessionID string) (session *Session, leaveBuffer int) {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil || len(state.Schedule) == 0 {
+		return nil, 0
 	}
 
+	sortedSchedule := state.Schedule
+	sortSessionsByStartTime(sortedSchedule)
+	first := sortedSchedule[0]
+
+	return &first, ArrivalBaseBuffer + calculateEntryTime(first.Room)
+}
+
+// calculateEntryTime returns the estimated minutes from the campus gate to a room's building
+func calculateEntryTime(room string) int {
+	if info, exists := venueBuildings[getBuildingFromRoom(room)]; exists && info.EntryTime > 0 {
+		return info.EntryTime
+	}
+	return UnknownEntryTime
+}
+
+// generateRouteDescription generates human-readable route description
+func generateRouteDescription(fromRoom, toRoom string) string {
 	fromBuilding := getBuildingFromRoom(fromRoom)
 	toBuilding := getBuildingFromRoom(toRoom)
 
-	fromName, fromExists := buildingNames[fromBuilding]
-	toName, toExists := buildingNames[toBuilding]
+	fromInfo, fromExists := venueBuildings[fromBuilding]
+	toInfo, toExists := venueBuildings[toBuilding]
+	fromName, toName := fromInfo.Name, toInfo.Name
 
 	// Handle unknown buildings
 	if !fromExists {
@@ -821,7 +3329,81 @@ func generateRouteDescription(fromRoom, toRoom string) string {
 		return fmt.Sprintf("在 %s 內移動：%s → %s", fromName, fromRoom, toRoom)
 	}
 
-	return fmt.Sprintf("%s %s → %s %s", fromName, fromRoom, toName, toRoom)
+	return fmt.Sprintf("%s %s → %s %s", fromName, fromRoom, toName, toRoom)
+}
+
+// RouteStep is a single step of a trip between two rooms, for clients rendering step-by-step directions
+type RouteStep struct {
+	Action      string `json:"action"` // "stay", "leave", "walk" or "arrive"
+	Description string `json:"description"`
+	Room        string `json:"room,omitempty"`
+	Building    string `json:"building,omitempty"`
+	Minutes     int    `json:"minutes"`
+}
+
+// BuildTripPlan breaks the move between two rooms into ordered route steps
+func BuildTripPlan(fromRoom, toRoom string) []RouteStep {
+	toBuilding := getBuildingFromRoom(toRoom)
+
+	// Same room or no previous room: nothing to walk
+	if fromRoom == "" || fromRoom == toRoom {
+		return []RouteStep{{
+			Action:      "stay",
+			Description: fmt.Sprintf("留在 %s", toRoom),
+			Room:        toRoom,
+			Building:    toBuilding,
+		}}
+	}
+
+	fromBuilding := getBuildingFromRoom(fromRoom)
+	walkingTime := calculateWalkingTime(fromRoom, toRoom)
+
+	steps := []RouteStep{{
+		Action:      "leave",
+		Description: fmt.Sprintf("離開 %s", fromRoom),
+		Room:        fromRoom,
+		Building:    fromBuilding,
+	}}
+
+	if fromBuilding == toBuilding {
+		steps = append(steps, RouteStep{
+			Action:      "walk",
+			Description: fmt.Sprintf("在%s內步行", buildingDisplayName(fromBuilding)),
+			Building:    fromBuilding,
+			Minutes:     walkingTime,
+		})
+	} else {
+		steps = append(steps, RouteStep{
+			Action:      "walk",
+			Description: fmt.Sprintf("從%s步行至%s", buildingDisplayName(fromBuilding), buildingDisplayName(toBuilding)),
+			Minutes:     walkingTime,
+		})
+	}
+
+	steps = append(steps, RouteStep{
+		Action:      "arrive",
+		Description: fmt.Sprintf("抵達 %s", toRoom),
+		Room:        toRoom,
+		Building:    toBuilding,
+	})
+
+	return steps
+}
+
+// buildingDisplayName returns the display name of a building code, or the code itself if unknown
+func buildingDisplayName(building string) string {
+	if info, exists := venueBuildings[building]; exists {
+		return info.Name
+	}
+	return building
+}
+
+// routeSteps returns the structured steps for a route, or nil when there is no route
+func routeSteps(route *RouteInfo) []RouteStep {
+	if route == nil {
+		return nil
+	}
+	return BuildTripPlan(route.FromRoom, route.ToRoom)
 }
 
 // Response builders
@@ -836,6 +3418,8 @@ func buildOngoingResponse(status *SessionStatus) map[string]any {
 	if status.NextSession != nil {
 		data["next_session"] = status.NextSession
 		data["route"] = status.Route
+		data["route_steps"] = routeSteps(status.Route)
+		data["minutes_until_next_start"] = status.MinutesUntilNextStart
 
 		message = fmt.Sprintf("🎯 您目前正在 %s 參加「%s」，還有 %d 分鐘結束。\n\n下一場：%s-%s 在 %s\n「%s」\n\n",
 			status.CurrentSession.Room,
@@ -851,6 +3435,14 @@ func buildOngoingResponse(status *SessionStatus) map[string]any {
 				status.Route.RouteDesc,
 				status.Route.WalkingTime)
 		}
+
+		if isTightConnection(status.Route) {
+			data["tight_connection"] = true
+		}
+		if needsWrapUp(status) {
+			data["wrap_up"] = true
+			message = wrapUpNudge(status) + "\n\n" + message
+		}
 	} else {
 		message = fmt.Sprintf("🎯 您目前正在 %s 參加「%s」，還有 %d 分鐘結束。這是今天最後一場議程。",
 			status.CurrentSession.Room,
@@ -862,34 +3454,108 @@ func buildOngoingResponse(status *SessionStatus) map[string]any {
 	return data
 }
 
+// needsWrapUp reports whether the current session ends so soon that walking to the next room
+// (plus time to settle in) no longer fits in what is left of it
+func needsWrapUp(status *SessionStatus) bool {
+	if status.CurrentSession == nil || status.NextSession == nil || status.Route == nil || status.Route.WalkingTime == 0 {
+		return false
+	}
+	return status.RemainingMinutes <= status.Route.WalkingTime+ArrivalBufferMinutes
+}
+
+// wrapUpNudge returns the escalated ongoing message shown when needsWrapUp is true
+// An infeasible transfer means the user should leave now rather than at the end
+func wrapUpNudge(status *SessionStatus) string {
+	if !status.Route.EnoughTime {
+		return fmt.Sprintf("🏃 只剩 %d 分鐘，走到 %s 要 %d 分鐘且換場時間不夠，建議現在就離場出發！",
+			status.RemainingMinutes, status.NextSession.Room, status.Route.WalkingTime)
+	}
+	return fmt.Sprintf("⏳ 只剩 %d 分鐘，走到 %s 要 %d 分鐘，可以開始收拾東西，結束後直接出發。",
+		status.RemainingMinutes, status.NextSession.Room, status.Route.WalkingTime)
+}
+
+// parseVerbosity converts a tool argument to a Verbosity, defaulting to normal
+func parseVerbosity(value string) Verbosity {
+	switch Verbosity(value) {
+	case VerbosityTerse, VerbosityRich:
+		return Verbosity(value)
+	default:
+		return VerbosityNormal
+	}
+}
+
+// applyStatusVerbosity trims or extends a status response built at normal verbosity
+// Terse drops route details for a one-line message; rich appends step-by-step directions
+func applyStatusVerbosity(data map[string]any, status *SessionStatus, verbosity Verbosity) map[string]any {
+	switch verbosity {
+	case VerbosityTerse:
+		delete(data, "route")
+		delete(data, "route_steps")
+		delete(data, "fill_in_options")
+		if needsWrapUp(status) {
+			data["message"] = wrapUpNudge(status)
+		} else if status.NextSession != nil {
+			data["message"] = fmt.Sprintf("下一場 %s %s「%s」", status.NextSession.Start, status.NextSession.Room, status.NextSession.Title)
+		} else if status.CurrentSession != nil {
+			data["message"] = fmt.Sprintf("%s「%s」，還有 %d 分鐘，今天最後一場", status.CurrentSession.Room, status.CurrentSession.Title, status.RemainingMinutes)
+		}
+	case VerbosityRich:
+		steps := routeSteps(status.Route)
+		if status.Route != nil && status.Route.WalkingTime > 0 && len(steps) > 0 {
+			message := data["message"].(string) + "\n\n🗺️ 詳細路線："
+			for i, step := range steps {
+				message += fmt.Sprintf("\n%d. %s", i+1, step.Description)
+			}
+			data["message"] = message
+		}
+	}
+	data["verbosity"] = string(verbosity)
+	return data
+}
+
 func buildBreakResponse(status *SessionStatus) map[string]any {
+	breakStart := minutesToTime(timeToMinutes(status.NextSession.Start) - status.BreakMinutes)
+	isLunch := isLunchBreak(breakStart, status.NextSession.Start)
+
 	data := map[string]any{
-		"status":        "break",
-		"next_session":  status.NextSession,
-		"break_minutes": status.BreakMinutes,
-		"route":         status.Route,
+		"status":                   "break",
+		"next_session":             status.NextSession,
+		"break_minutes":            status.BreakMinutes,
+		"minutes_until_next_start": status.MinutesUntilNextStart,
+		"route":                    status.Route,
+		"route_steps":              routeSteps(status.Route),
+		"is_lunch":                 isLunch,
 	}
 
-	message := fmt.Sprintf("⏰ 您目前有 %d 分鐘空檔時間。\n\n下一場：%s-%s 在 %s\n「%s」\n\n",
-		status.BreakMinutes,
+	breakIntro := fmt.Sprintf("⏰ 您目前有 %d 分鐘空檔時間。", status.BreakMinutes)
+	if isLunch {
+		breakIntro = fmt.Sprintf("🍱 午餐時間！您有 %d 分鐘可以用餐，可以到攤位區附近的餐飲區或校園周邊吃點東西，記得預留移動時間。", status.BreakMinutes)
+	}
+
+	message := fmt.Sprintf("%s\n\n下一場：%s-%s 在 %s\n「%s」\n\n",
+		breakIntro,
 		status.NextSession.Start,
 		status.NextSession.End,
 		status.NextSession.Room,
 		status.NextSession.Title)
 
+	if isTightConnection(status.Route) {
+		data["tight_connection"] = true
+	}
+
 	if status.Route != nil && status.Route.WalkingTime > 0 {
-		timeBuffer := status.BreakMinutes - status.Route.WalkingTime
-		if timeBuffer > 5 {
+		timeBuffer := status.BreakMinutes - status.Route.RequiredTime
+		if !status.Route.EnoughTime {
+			message += fmt.Sprintf("🚶 移動建議：%s（預估 %d 分鐘，實際可能更久）\n🏃 時間較緊迫，建議立即前往！",
+				status.Route.RouteDesc,
+				status.Route.WalkingTime)
+		} else if timeBuffer > TightTransferBufferMinutes {
 			message += fmt.Sprintf("🚶 移動建議：%s（預估 %d 分鐘，實際可能更久）\n✅ 時間很充裕，您還有 %d 分鐘可以休息或逛攤位。",
 				status.Route.RouteDesc,
 				status.Route.WalkingTime,
 				timeBuffer)
-		} else if timeBuffer > 0 {
-			message += fmt.Sprintf("🚶 移動建議：%s（預估 %d 分鐘，實際可能更久）\n⏱️ 建議現在就開始移動。",
-				status.Route.RouteDesc,
-				status.Route.WalkingTime)
 		} else {
-			message += fmt.Sprintf("🚶 移動建議：%s（預估 %d 分鐘，實際可能更久）\n🏃 時間較緊迫，建議立即前往！",
+			message += fmt.Sprintf("🚶 移動建議：%s（預估 %d 分鐘，實際可能更久）\n⏱️ 建議現在就開始移動。",
 				status.Route.RouteDesc,
 				status.Route.WalkingTime)
 		}
@@ -897,16 +3563,70 @@ func buildBreakResponse(status *SessionStatus) map[string]any {
 		message += "📍 下一場議程在相同地點，您可以繼續留在原地。"
 	}
 
+	if len(status.FillInOptions) > 0 {
+		data["fill_in_options"] = status.FillInOptions
+		message += "\n\n💡 空檔很長，可以考慮先去聽："
+		for _, option := range status.FillInOptions {
+			message += fmt.Sprintf("\n- %s-%s 在 %s「%s」", option.Start, option.End, option.Room, option.Title)
+		}
+	}
+
+	data["message"] = message
+	return data
+}
+
+// buildLunchResponse suggests where to eat during a long midday gap and which sessions could fit
+// around the meal; unlike buildBreakResponse it never urges the user to head to the next room
+func buildLunchResponse(status *SessionStatus) map[string]any {
+	foodAreas := GetVenueInfo().FoodAreas
+
+	data := map[string]any{
+		"status":                   "lunch_break",
+		"next_session":             status.NextSession,
+		"break_minutes":            status.BreakMinutes,
+		"minutes_until_next_start": status.MinutesUntilNextStart,
+		"route":                    status.Route,
+		"route_steps":              routeSteps(status.Route),
+		"food_areas":               foodAreas,
+	}
+
+	message := fmt.Sprintf("🍱 午餐時間！距離下一場還有 %d 分鐘，可以好好吃頓飯、休息一下。", status.BreakMinutes)
+	if len(foodAreas) > 0 {
+		message += "\n\n🍽️ 用餐地點："
+		for _, area := range foodAreas {
+			message += "\n- " + area
+		}
+	}
+
+	message += fmt.Sprintf("\n\n下午第一場：%s-%s 在 %s\n「%s」",
+		status.NextSession.Start,
+		status.NextSession.End,
+		status.NextSession.Room,
+		status.NextSession.Title)
+	if status.Route != nil && status.Route.WalkingTime > 0 {
+		message += fmt.Sprintf("\n吃完後預留約 %d 分鐘走過去就可以了。", status.Route.RequiredTime)
+	}
+
+	if len(status.FillInOptions) > 0 {
+		data["fill_in_options"] = status.FillInOptions
+		message += "\n\n💡 吃得快的話，附近還有這些議程可以順便聽："
+		for _, option := range status.FillInOptions {
+			message += fmt.Sprintf("\n- %s-%s 在 %s「%s」", option.Start, option.End, option.Room, option.Title)
+		}
+	}
+
 	data["message"] = message
 	return data
 }
 
 func buildJustEndedResponse(status *SessionStatus) map[string]any {
 	data := map[string]any{
-		"status":        "just_ended",
-		"next_session":  status.NextSession,
-		"break_minutes": status.BreakMinutes,
-		"route":         status.Route,
+		"status":                   "just_ended",
+		"next_session":             status.NextSession,
+		"break_minutes":            status.BreakMinutes,
+		"minutes_until_next_start": status.MinutesUntilNextStart,
+		"route":                    status.Route,
+		"route_steps":              routeSteps(status.Route),
 	}
 
 	message := fmt.Sprintf("✅ 議程剛結束！距離下一場還有 %d 分鐘。\n\n下一場：%s-%s 在 %s\n「%s」\n\n",
@@ -916,9 +3636,17 @@ func buildJustEndedResponse(status *SessionStatus) map[string]any {
 		status.NextSession.Room,
 		status.NextSession.Title)
 
+	if isTightConnection(status.Route) {
+		data["tight_connection"] = true
+	}
+
 	if status.Route != nil && status.Route.WalkingTime > 0 {
-		timeBuffer := status.BreakMinutes - status.Route.WalkingTime
-		if timeBuffer > 5 {
+		timeBuffer := status.BreakMinutes - status.Route.RequiredTime
+		if !status.Route.EnoughTime {
+			message += fmt.Sprintf("🚶 移動路線：%s（預估 %d 分鐘，實際可能更久）\n🏃 換場時間不夠，請立刻出發，可能會晚到幾分鐘。",
+				status.Route.RouteDesc,
+				status.Route.WalkingTime)
+		} else if timeBuffer > TightTransferBufferMinutes {
 			message += fmt.Sprintf("🚶 移動路線：%s（預估 %d 分鐘，實際可能更久）\n😌 時間充裕，可以先休息一下再出發。",
 				status.Route.RouteDesc,
 				status.Route.WalkingTime)
@@ -935,18 +3663,103 @@ func buildJustEndedResponse(status *SessionStatus) map[string]any {
 	return data
 }
 
-func buildCompleteResponse(status *SessionStatus) map[string]any {
+// completeReason explains a schedule_complete status for a user planning the given internal day:
+// the day's program being over takes precedence over the user having finished planning
+func completeReason(day string, now time.Time, finishedPlanning bool) string {
+	today := convertDayFormat(getCOSCUPDay(now))
+	if (day == DayFormatAug9 && today == DayFormatAug10) ||
+		(day == today && timeToMinutes(formatTimeForSession(now)) >= dayProgramEndMinutes(day)) {
+		return CompleteReasonConferenceOver
+	}
+	if finishedPlanning {
+		return CompleteReasonFinishedPlanning
+	}
+	return CompleteReasonNoMoreSessions
+}
+
+// dayProgramEndMinutes returns when the last session of an internal day ends, in minutes since midnight
+func dayProgramEndMinutes(day string) int {
+	end := 0
+	for _, session := range sessionData().byDay[day] {
+		end = max(end, endTimeToMinutes(session.Start, session.End))
+	}
+	return end
+}
+
+// completeReasonMessages explains each schedule_complete reason to the user
+var completeReasonMessages = map[string]string{
+	CompleteReasonFinishedPlanning: "您已完成今天的行程規劃，所有安排的議程都結束了。",
+	CompleteReasonNoMoreSessions:   "您安排的最後一場議程已經結束，今天也沒有適合再加入的議程了。",
+	CompleteReasonConferenceOver:   "今天的 COSCUP 議程已經全部結束。",
+}
+
+func buildCompleteResponse(status *SessionStatus, reason string) map[string]any {
 	return map[string]any{
 		"status":  "schedule_complete",
-		"message": "🎉 恭喜！您今天的所有議程都已完成。希望您在 COSCUP 2025 度過了充實的一天！\n\n您可以：\n- 逛逛攤位區域\n- 參加 BoF 活動\n- 與其他與會者交流",
+		"reason":  reason,
+		"message": completeReasonMessages[reason] + "\n\n🎉 恭喜！您今天的所有議程都已完成。希望您在 COSCUP 2025 度過了充實的一天！\n\n您可以：\n- 逛逛攤位區域\n- 參加 BoF 活動\n- 與其他與會者交流",
 	}
 }
 
-func buildOutsideCOSCUPPeriodResponse() map[string]any {
-	return map[string]any{
-		"status":  "outside_coscup_period",
-		"message": "🗓️ 目前不在 COSCUP 2025 活動期間內（8月9-10日）。\n\n如果您想：\n- 📋 查看已規劃的議程：使用 get_schedule\n- 🔍 瀏覽議程資訊：使用 get_session_detail 加上議程代碼\n- 📍 查看會場資訊：使用 get_venue_map\n\n期待與您在 COSCUP 2025 相見！",
+// PostEventResource is a link shown once the conference is over
+type PostEventResource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// PostEventResources lists the links offered after the conference; replace it at startup to customize them
+var PostEventResources = []PostEventResource{
+	{Name: "議程錄影", URL: "https://www.youtube.com/@coscup"},
+	{Name: "議程簡報與共筆", URL: "https://coscup.org/2025/sessions/"},
+	{Name: "COSCUP 官網（下一屆資訊）", URL: "https://coscup.org/"},
+}
+
+// conferencePhase reports whether t is before, during or after the conference days
+func conferencePhase(t time.Time) string {
+	t = t.In(conferenceLocation())
+	start := coscupConfig.date(coscupConfig.Day1)
+	end := coscupConfig.date(coscupConfig.Day2 + 1)
+	switch {
+	case t.Before(start):
+		return ConferencePhaseBefore
+	case t.Before(end):
+		return ConferencePhaseDuring
+	default:
+		return ConferencePhaseAfter
+	}
+}
+
+// buildOutsideCOSCUPPeriodResponse builds the get_next_session response for times outside the conference days,
+// with a countdown before the event and post-event resources after it
+func buildOutsideCOSCUPPeriodResponse(now time.Time) map[string]any {
+	phase := conferencePhase(now)
+	response := map[string]any{
+		"status": "outside_coscup_period",
+		"phase":  phase,
+	}
+
+	switch phase {
+	case ConferencePhaseBefore:
+		now = now.In(conferenceLocation())
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, conferenceLocation())
+		start := coscupConfig.date(coscupConfig.Day1)
+		daysUntil := int(start.Sub(today).Hours() / 24)
+		response["days_until_start"] = daysUntil
+		response["message"] = fmt.Sprintf("🗓️ 距離 COSCUP %d（%s）還有 %d 天！\n\n趁現在先做準備：\n- 📋 使用 start_planning 提前規劃想聽的議程\n- 📚 使用 get_track_catalog 瀏覽各議程軌\n- 📍 使用 get_venue_map 熟悉會場與交通\n\n期待與您在 COSCUP %d 相見！",
+			coscupConfig.Year, coscupConfig.dateLabel(), daysUntil, coscupConfig.Year)
+	case ConferencePhaseAfter:
+		var resources strings.Builder
+		for _, resource := range PostEventResources {
+			fmt.Fprintf(&resources, "- %s：%s\n", resource.Name, resource.URL)
+		}
+		response["resources"] = PostEventResources
+		response["message"] = fmt.Sprintf("🎉 COSCUP %d 已經圓滿落幕，感謝您的參與！\n\n錯過的議程可以透過以下資源回顧：\n", coscupConfig.Year) + resources.String() + "\n您仍可使用 get_schedule 回顧已規劃的議程。我們明年見！"
+	default:
+		response["message"] = fmt.Sprintf("🗓️ 目前不在 COSCUP %d 活動期間內（%s）。\n\n如果您想：\n- 📋 查看已規劃的議程：使用 get_schedule\n- 🔍 瀏覽議程資訊：使用 get_session_detail 加上議程代碼\n- 📍 查看會場資訊：使用 get_venue_map\n\n期待與您在 COSCUP %d 相見！",
+			coscupConfig.Year, coscupConfig.dateLabel(), coscupConfig.Year)
 	}
+
+	return response
 }
 
 // filterOutSocialActivities removes long-duration social activities from recommendations
@@ -980,10 +3793,7 @@ func isSocialActivity(session Session) bool {
 	}
 
 	// Check for very long sessions
-	startMin := timeToMinutes(session.Start)
-	endMin := timeToMinutes(session.End)
-	duration := endMin - startMin
-	if duration >= LongSessionMinutes {
+	if sessionDurationMinutes(session.Start, session.End) >= LongSessionMinutes {
 		return true
 	}
 
@@ -994,7 +3804,7 @@ func isSocialActivity(session Session) bool {
 func FindRoomSessions(day, room string) []Session {
 
 	var roomSessions []Session
-	for _, session := range sessionsByDay[day] {
+	for _, session := range sessionData().byDay[day] {
 		if session.Room == room {
 			roomSessions = append(roomSessions, session)
 		}
@@ -1002,14 +3812,342 @@ func FindRoomSessions(day, room string) []Session {
 
 	result := getSimplifiedSessions(roomSessions)
 
-	// Sort by start time using efficient sort.Slice
+	sortSessionsByStartTime(result)
+
+	return result
+}
+
+// otherDayRoomHint explains that a room without sessions on day is used on the other COSCUP day
+// Returns "" when the room is empty on both days
+func otherDayRoomHint(room, day string) string {
+	otherDay := DayFormatAug10
+	if day == DayFormatAug10 {
+		otherDay = DayFormatAug9
+	}
+
+	count := len(FindRoomSessions(otherDay, room))
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s has no sessions on %s but %d on %s", room, toUserDayFormat(day), count, toUserDayFormat(otherDay))
+}
+
+// roomFloorPattern matches room codes with a 3-digit room number whose first digit is the floor,
+// e.g. "TR509", "RB-105" or "TR310-2"
+var roomFloorPattern = regexp.MustCompile(`^[A-Z]{2,3}-?(\d)\d{2}`)
+
+// roomFloor returns the floor encoded in a room code, or false for rooms like "AU" without a floor
+func roomFloor(room string) (int, bool) {
+	match := roomFloorPattern.FindStringSubmatch(room)
+	if match == nil {
+		return 0, false
+	}
+	return int(match[1][0] - '0'), true
+}
+
+// FindSessionsByFloor returns the sessions on one floor of a building, sorted by start time then room
+// With a currentTime only sessions that haven't ended yet are returned; rooms without an encoded floor never match
+func FindSessionsByFloor(day, building string, floor int, currentTime string) []Session {
+	var result []Session
+	for _, session := range sessionData().byDay[day] {
+		if getBuildingFromRoom(session.Room) != building {
+			continue
+		}
+		if sessionFloor, ok := roomFloor(session.Room); !ok || sessionFloor != floor {
+			continue
+		}
+		if currentTime != "" && endTimeToMinutes(session.Start, session.End) <= timeToMinutes(currentTime) {
+			continue
+		}
+		result = append(result, session)
+	}
+
+	result = getSimplifiedSessions(result)
+	sortSessionsByStartTime(result)
+	return result
+}
+
+// GetRoomsByBuilding groups the rooms that have sessions on the given internal day by building code
+// Rooms are sorted within each building; rooms of unrecognized buildings are grouped under "Unknown"
+func GetRoomsByBuilding(day string) map[string][]string {
+	seen := make(map[string]bool)
+	roomsByBuilding := make(map[string][]string)
+
+	for _, session := range sessionData().byDay[day] {
+		if seen[session.Room] {
+			continue
+		}
+		seen[session.Room] = true
+
+		building := getBuildingFromRoom(session.Room)
+		roomsByBuilding[building] = append(roomsByBuilding[building], session.Room)
+	}
+
+	for _, rooms := range roomsByBuilding {
+		sort.Strings(rooms)
+	}
+	return roomsByBuilding
+}
+
+// BuildingConcurrencyAt counts the sessions running at currentTime in each building
+// Social activities count too, they fill the hallways as much as talks do; buildings with nothing running are left out
+func BuildingConcurrencyAt(day, currentTime string) map[string]int {
+	currentMinutes := timeToMinutes(currentTime)
+
+	concurrency := make(map[string]int)
+	for _, session := range sessionData().byDay[day] {
+		if currentMinutes >= timeToMinutes(session.Start) && currentMinutes < endTimeToMinutes(session.Start, session.End) {
+			concurrency[getBuildingFromRoom(session.Room)]++
+		}
+	}
+	return concurrency
+}
+
+// busiestBuildings returns the buildings with the most concurrent sessions, sorted by code, or nil when nothing runs
+func busiestBuildings(concurrency map[string]int) []string {
+	var busiest []string
+	most := 0
+	for building, count := range concurrency {
+		if count > most {
+			busiest, most = nil, count
+		}
+		if count == most {
+			busiest = append(busiest, building)
+		}
+	}
+	sort.Strings(busiest)
+	return busiest
+}
+
+// GetAllRoomsWithBuildings maps every room with sessions on either day to its building display name
+func GetAllRoomsWithBuildings() map[string]string {
+	rooms := make(map[string]string)
+	for _, session := range sessionData().all {
+		if _, seen := rooms[session.Room]; seen {
+			continue
+		}
+		rooms[session.Room] = buildingDisplayName(getBuildingFromRoom(session.Room))
+	}
+	return rooms
+}
+
+// withSessionDetails replaces simplified sessions with their full versions (including abstracts)
+// At most limit sessions are returned to keep responses small; the bool reports whether the list was truncated
+func withSessionDetails(sessions []Session, limit int) ([]Session, bool) {
+	truncated := len(sessions) > limit
+	if truncated {
+		sessions = sessions[:limit]
+	}
+
+	detailed := make([]Session, 0, len(sessions))
+	for _, session := range sessions {
+		if full := FindSessionByCode(session.Code); full != nil {
+			detailed = append(detailed, *full)
+		} else {
+			detailed = append(detailed, session)
+		}
+	}
+
+	return detailed, truncated
+}
+
+// GetLivestreamedSessions returns streamed sessions running at currentTime, sorted by room
+func GetLivestreamedSessions(day, currentTime string) []Session {
+	currentMinutes := timeToMinutes(currentTime)
+
+	var streamed []Session
+	for _, session := range sessionData().byDay[day] {
+		if session.StreamURL == "" {
+			continue
+		}
+		if currentMinutes >= timeToMinutes(session.Start) && currentMinutes < endTimeToMinutes(session.Start, session.End) {
+			streamed = append(streamed, session)
+		}
+	}
+
+	result := getSimplifiedSessions(streamed)
 	sort.Slice(result, func(i, j int) bool {
-		return timeToMinutes(result[i].Start) < timeToMinutes(result[j].Start)
+		return result[i].Room < result[j].Room
+	})
+
+	return result
+}
+
+// GetNextSessionAnywhere returns the sessions in any room with the earliest start after currentTime, sorted by room
+// Social activities are left out; the result is empty when nothing else starts today
+func GetNextSessionAnywhere(day, currentTime string) []Session {
+	currentMinutes := timeToMinutes(currentTime)
+
+	var next []Session
+	nextStart := -1
+	for _, session := range filterOutSocialActivities(sessionData().byDay[day]) {
+		start := timeToMinutes(session.Start)
+		if start <= currentMinutes {
+			continue
+		}
+		if nextStart == -1 || start < nextStart {
+			next, nextStart = nil, start
+		}
+		if start == nextStart {
+			next = append(next, session)
+		}
+	}
+
+	result := getSimplifiedSessions(next)
+	sortSessionsByStartTime(result) // all start together, so this orders by room
+	return result
+}
+
+// GetOngoingSocialActivities returns the social activities (e.g. Hacking Corner) running at currentTime
+func GetOngoingSocialActivities(day, currentTime string) []Session {
+	currentMinutes := timeToMinutes(currentTime)
+
+	var ongoing []Session
+	for _, session := range sessionData().byDay[day] {
+		if isSocialActivity(session) && currentMinutes >= timeToMinutes(session.Start) &&
+			currentMinutes < endTimeToMinutes(session.Start, session.End) {
+			ongoing = append(ongoing, session)
+		}
+	}
+	return getSimplifiedSessions(ongoing)
+}
+
+// OngoingMatchingInterests returns the sessions running at currentTime whose track is in the user's profile
+// or that share a tag with the user's schedule, best match first. Social activities and planned sessions are left out
+func OngoingMatchingInterests(sessionID, day, currentTime string) []Session {
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return nil
+	}
+
+	profileTags := make(map[string]bool)
+	planned := make(map[string]bool)
+	for _, scheduled := range state.Schedule {
+		planned[scheduled.Code] = true
+		for _, tag := range scheduled.Tags {
+			profileTags[tag] = true
+		}
+	}
+
+	currentMinutes := timeToMinutes(currentTime)
+	var matching []Session
+	for _, session := range filterOutSocialActivities(sessionData().byDay[day]) {
+		if planned[session.Code] || currentMinutes < timeToMinutes(session.Start) ||
+			currentMinutes >= endTimeToMinutes(session.Start, session.End) {
+			continue
+		}
+		if !slices.Contains(state.Profile, session.Track) && !sharesTag(session.Tags, profileTags) {
+			continue
+		}
+		matching = append(matching, session)
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		scoreI, scoreJ := scoreSession(matching[i], state), scoreSession(matching[j], state)
+		if scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+		return matching[i].Room < matching[j].Room
 	})
+	return getSimplifiedSessions(matching)
+}
 
+// sharesTag reports whether any of tags is in the given tag set
+func sharesTag(tags []string, set map[string]bool) bool {
+	for _, tag := range tags {
+		if set[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchSessions finds sessions of an internal day, or of both days when day is "", whose title, abstract or
+// speakers contain query (case-insensitive) and that carry any of tags exactly, emoji included. An empty query or
+// tag list doesn't filter. Results are simplified and sorted by day, then start time
+func SearchSessions(day, query string, tags []string) []Session {
+	days := []string{DayFormatAug9, DayFormatAug10}
+	if day != "" {
+		days = []string{day}
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var result []Session
+	for _, d := range days {
+		var matching []Session
+		for _, session := range sessionData().byDay[d] {
+			if query != "" && !sessionContainsText(session, query) {
+				continue
+			}
+			if len(tags) > 0 && !slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(session.Tags, tag) }) {
+				continue
+			}
+			matching = append(matching, session)
+		}
+		matching = getSimplifiedSessions(matching)
+		sortSessionsByStartTime(matching)
+		result = append(result, matching...)
+	}
+	return result
+}
+
+// FindSessionsBySpeaker returns the simplified sessions of both days with a speaker whose name contains name
+// (case-insensitive, so "張" matches "張三"), sorted by day, then start time
+func FindSessionsBySpeaker(name string) []Session {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+
+	var matching []Session
+	for _, session := range sessionData().all {
+		if slices.ContainsFunc(session.Speakers, func(speaker string) bool {
+			return strings.Contains(strings.ToLower(speaker), name)
+		}) {
+			matching = append(matching, session)
+		}
+	}
+
+	result := getSimplifiedSessions(matching)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Day != result[j].Day {
+			return result[i].Day == DayFormatAug9
+		}
+		return sessionStartsBefore(result[i], result[j])
+	})
 	return result
 }
 
+// AllSessionsPaged returns one page of an internal day's sessions, sorted by start time, then room and
+// code so pages stay stable between calls, along with the day's total session count
+// Sessions are full copies; offsets past the end give an empty page and a non-positive limit the rest of the day
+func AllSessionsPaged(day string, offset, limit int) ([]Session, int) {
+	sorted := slices.Clone(sessionData().byDay[day])
+	sortSessionsByStartTime(sorted)
+	return pageSessions(sorted, offset, limit), len(sorted)
+}
+
+// pageSessions returns up to limit sessions starting at offset, clamped to the slice
+// A non-positive limit means no limit, returning everything from offset on
+func pageSessions(sessions []Session, offset, limit int) []Session {
+	start := min(max(offset, 0), len(sessions))
+	end := len(sessions)
+	if limit > 0 {
+		end = min(start+limit, end)
+	}
+	return sessions[start:end]
+}
+
+// sessionContainsText reports whether a session's title, abstract or any speaker contains the lowercased text
+func sessionContainsText(session Session, text string) bool {
+	if strings.Contains(strings.ToLower(session.Title), text) || strings.Contains(strings.ToLower(session.Abstract), text) {
+		return true
+	}
+	return slices.ContainsFunc(session.Speakers, func(speaker string) bool {
+		return strings.Contains(strings.ToLower(speaker), text)
+	})
+}
+
 // GetCurrentRoomSession returns the session currently running in a room
 func GetCurrentRoomSession(room, day, currentTime string) *Session {
 	roomSessions := FindRoomSessions(day, room)
@@ -1017,7 +4155,7 @@ func GetCurrentRoomSession(room, day, currentTime string) *Session {
 
 	for _, session := range roomSessions {
 		startMin := timeToMinutes(session.Start)
-		endMin := timeToMinutes(session.End)
+		endMin := endTimeToMinutes(session.Start, session.End)
 
 		// Check if current time is within session period
 		if currentMinutes >= startMin && currentMinutes < endMin {
@@ -1044,3 +4182,70 @@ func GetNextRoomSession(room, day, currentTime string) *Session {
 
 	return nil
 }
+
+// LiveGrid returns the session currently running in every room with sessions on day, grouped by
+// building display name and sorted by room; rooms between talks are left out
+func LiveGrid(day, currentTime string) map[string][]Session {
+	var rooms []string
+	for _, session := range sessionData().byDay[day] {
+		if !slices.Contains(rooms, session.Room) {
+			rooms = append(rooms, session.Room)
+		}
+	}
+	sort.Strings(rooms)
+
+	grid := make(map[string][]Session)
+	for _, room := range rooms {
+		if current := GetCurrentRoomSession(room, day, currentTime); current != nil {
+			building := buildingDisplayName(getBuildingFromRoom(room))
+			grid[building] = append(grid[building], *current)
+		}
+	}
+	return grid
+}
+
+// SessionsStartingSoon returns sessions starting after currentTime and within the next N minutes
+// Results are simplified and sorted by start time
+func SessionsStartingSoon(day, currentTime string, within int) []Session {
+	currentMinutes := timeToMinutes(currentTime)
+
+	var startingSoon []Session
+	for _, session := range sessionData().byDay[day] {
+		startMin := timeToMinutes(session.Start)
+		if startMin > currentMinutes && startMin-currentMinutes <= within {
+			startingSoon = append(startingSoon, session)
+		}
+	}
+
+	result := getSimplifiedSessions(startingSoon)
+	sortSessionsByStartTime(result)
+
+	return result
+}
+
+// SessionsEndingSoon returns sessions already running at currentTime that end within the next N minutes
+// Useful for catching the tail of a talk; results are sorted by end time
+func SessionsEndingSoon(day, currentTime string, within int) []Session {
+	currentMinutes := timeToMinutes(currentTime)
+
+	var endingSoon []Session
+	for _, session := range sessionData().byDay[day] {
+		startMin := timeToMinutes(session.Start)
+		endMin := endTimeToMinutes(session.Start, session.End)
+
+		if startMin <= currentMinutes && endMin > currentMinutes && endMin-currentMinutes <= within {
+			endingSoon = append(endingSoon, session)
+		}
+	}
+
+	result := getSimplifiedSessions(endingSoon)
+	sort.Slice(result, func(i, j int) bool {
+		endI, endJ := endTimeToMinutes(result[i].Start, result[i].End), endTimeToMinutes(result[j].Start, result[j].End)
+		if endI != endJ {
+			return endI < endJ
+		}
+		return sessionStartsBefore(result[i], result[j])
+	})
+
+	return result
+}
@@ -1,14 +1,51 @@
 package mcp
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"mcp-coscup/mcp/testutil"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 // Tests for functions in session.go
 
+// setTestSessions replaces the global session data for the duration of a test
+func setTestSessions(t *testing.T, byDay map[string][]Session) {
+	t.Helper()
+	original := sessionData()
+
+	var all []Session
+	for _, sessions := range byDay {
+		all = append(all, sessions...)
+	}
+	currentSessionIndex.Store(newSessionIndex(all, byDay, 0))
+
+	t.Cleanup(func() {
+		currentSessionIndex.Store(original)
+	})
+}
+
+// storeTestUserState puts a user state into its shard and removes it when the test ends
+func storeTestUserState(t *testing.T, state *UserState) {
+	t.Helper()
+	shardIndex := getShardIndex(state.SessionID)
+	sessionShards[shardIndex].mu.Lock()
+	sessionShards[shardIndex].sessions[state.SessionID] = state
+	sessionShards[shardIndex].mu.Unlock()
+
+	t.Cleanup(func() {
+		sessionShards[shardIndex].mu.Lock()
+		delete(sessionShards[shardIndex].sessions, state.SessionID)
+		sessionShards[shardIndex].mu.Unlock()
+	})
+}
+
 // Building utility functions tests
 func TestGetBuildingFromRoom(t *testing.T) {
 	tests := []struct {
@@ -25,6 +62,8 @@ func TestGetBuildingFromRoom(t *testing.T) {
 		{"TR405 room", "TR405", "TR"},
 		{"TR515 room", "TR515", "TR"},
 		{"TR312 room", "TR312", "TR"},
+		{"RB101 room without hyphen", "RB101", "RB"},
+		{"Prefixed room in new building", "OT-201", "OT"},
 		{"Unknown room", "UNKNOWN", "Unknown"},
 		{"Empty string", "", "Unknown"},
 		{"Single character", "T", "Unknown"},
@@ -38,6 +77,31 @@ func TestGetBuildingFromRoom(t *testing.T) {
 	}
 }
 
+func TestRegisterBuildingRoutes(t *testing.T) {
+	RegisterBuilding("OT", BuildingInfo{
+		Name:          "國際大樓",
+		EntryTime:     6,
+		WalkTimes:     map[string]int{"OT": 1, BuildingAU: 6},
+		WalkDistances: map[string]int{"OT": 40, BuildingAU: 450},
+	})
+	t.Cleanup(func() { delete(venueBuildings, "OT") })
+
+	testutil.AssertEqual(t, "OT", getBuildingFromRoom("OT101"), "OT rooms should map to the OT building")
+	testutil.AssertEqual(t, 6, calculateWalkingTime("OT101", "AU"), "Walk time should come from the registered config")
+	testutil.AssertEqual(t, 6, calculateWalkingTime("AU", "OT101"), "Reverse direction should fall back to the registered config")
+	testutil.AssertEqual(t, 1, calculateWalkingTime("OT101", "OT102"), "Same-building walk time should be configurable")
+	testutil.AssertEqual(t, UnknownWalkTime, calculateWalkingTime("OT101", "TR405"), "Unconfigured pairs use the default")
+	testutil.AssertEqual(t, 450, calculateWalkingDistance("AU", "OT101"), "Walk distance should come from the registered config")
+	testutil.AssertEqual(t, 6, calculateEntryTime("OT101"), "Entry time should come from the registered config")
+
+	testutil.AssertEqual(t, "視聽館 AU → 國際大樓 OT101", generateRouteDescription("AU", "OT101"), "Route should use the configured name")
+	testutil.AssertEqual(t, "在 國際大樓 內移動：OT101 → OT102", generateRouteDescription("OT101", "OT102"), "Same-building route should use the configured name")
+
+	steps := BuildTripPlan("AU", "OT101")
+	testutil.AssertEqual(t, "arrive", steps[len(steps)-1].Action, "Trip should end by arriving")
+	testutil.AssertEqual(t, "OT", steps[len(steps)-1].Building, "Trip should arrive at the OT building")
+}
+
 func TestCalculateWalkingTime(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -122,6 +186,7 @@ func TestCalculateRoute(t *testing.T) {
 		name          string
 		fromSession   *Session
 		toSession     *Session
+		breakMinutes  int
 		expectedRoute *RouteInfo
 		shouldBeNil   bool
 	}{
@@ -132,9 +197,10 @@ func TestCalculateRoute(t *testing.T) {
 			shouldBeNil: true,
 		},
 		{
-			name:        "No origin session, same room destination",
-			fromSession: nil,
-			toSession:   &Session{Room: "AU"},
+			name:         "No origin session, same room destination",
+			fromSession:  nil,
+			toSession:    &Session{Room: "AU"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "",
 				ToRoom:      "AU",
@@ -144,9 +210,10 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "Same room transition",
-			fromSession: &Session{Room: "AU"},
-			toSession:   &Session{Room: "AU"},
+			name:         "Same room transition",
+			fromSession:  &Session{Room: "AU"},
+			toSession:    &Session{Room: "AU"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "AU",
 				ToRoom:      "AU",
@@ -156,9 +223,10 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "AU to RB transition",
-			fromSession: &Session{Room: "AU"},
-			toSession:   &Session{Room: "RB-105"},
+			name:         "AU to RB transition",
+			fromSession:  &Session{Room: "AU"},
+			toSession:    &Session{Room: "RB-105"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "AU",
 				ToRoom:      "RB-105",
@@ -168,9 +236,10 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "RB to TR transition",
-			fromSession: &Session{Room: "RB-101"},
-			toSession:   &Session{Room: "TR405"},
+			name:         "RB to TR transition",
+			fromSession:  &Session{Room: "RB-101"},
+			toSession:    &Session{Room: "TR405"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "RB-101",
 				ToRoom:      "TR405",
@@ -180,9 +249,10 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "TR to AU transition",
-			fromSession: &Session{Room: "TR209"},
-			toSession:   &Session{Room: "AU101"},
+			name:         "TR to AU transition",
+			fromSession:  &Session{Room: "TR209"},
+			toSession:    &Session{Room: "AU101"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "TR209",
 				ToRoom:      "AU101",
@@ -192,9 +262,10 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "Within TR building",
-			fromSession: &Session{Room: "TR209"},
-			toSession:   &Session{Room: "TR405"},
+			name:         "Within TR building",
+			fromSession:  &Session{Room: "TR209"},
+			toSession:    &Session{Room: "TR405"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "TR209",
 				ToRoom:      "TR405",
@@ -204,9 +275,36 @@ func TestCalculateRoute(t *testing.T) {
 			},
 		},
 		{
-			name:        "Unknown room transition",
-			fromSession: &Session{Room: "UNKNOWN1"},
-			toSession:   &Session{Room: "UNKNOWN2"},
+			name:         "Break too short for the walk",
+			fromSession:  &Session{Room: "TR209"},
+			toSession:    &Session{Room: "AU"},
+			breakMinutes: 5,
+			expectedRoute: &RouteInfo{
+				FromRoom:    "TR209",
+				ToRoom:      "AU",
+				WalkingTime: 4,
+				RouteDesc:   "研揚大樓 TR209 → 視聽館 AU",
+				EnoughTime:  false,
+			},
+		},
+		{
+			name:         "Same room needs no break",
+			fromSession:  &Session{Room: "AU"},
+			toSession:    &Session{Room: "AU"},
+			breakMinutes: 0,
+			expectedRoute: &RouteInfo{
+				FromRoom:    "AU",
+				ToRoom:      "AU",
+				WalkingTime: 0,
+				RouteDesc:   "議程在相同地點 AU",
+				EnoughTime:  true,
+			},
+		},
+		{
+			name:         "Unknown room transition",
+			fromSession:  &Session{Room: "UNKNOWN1"},
+			toSession:    &Session{Room: "UNKNOWN2"},
+			breakMinutes: 10,
 			expectedRoute: &RouteInfo{
 				FromRoom:    "UNKNOWN1",
 				ToRoom:      "UNKNOWN2",
@@ -219,7 +317,7 @@ func TestCalculateRoute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := calculateRoute(tt.fromSession, tt.toSession)
+			result := calculateRoute(tt.fromSession, tt.toSession, tt.breakMinutes)
 
 			if tt.shouldBeNil {
 				testutil.AssertEqual(t, (*RouteInfo)(nil), result, "Expected nil route")
@@ -405,6 +503,64 @@ func TestAnalyzeCurrentStatusSingleSession(t *testing.T) {
 	}
 }
 
+func TestAnalyzeCurrentStatusAfterMidnightEnd(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_status_midnight",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "LATE-001", Title: "Evening Talk", Start: "21:00", End: "22:00", Room: "AU"},
+			{Code: "LATE-002", Title: "Night Hack", Start: "22:30", End: "01:00", Room: "TR211"},
+		},
+		LastEndTime: "22:00",
+	}
+
+	result := analyzeCurrentStatus(state, "23:30")
+	testutil.AssertEqual(t, "ongoing", result.Status, "A session ending after midnight is still running before midnight")
+	testutil.AssertEqual(t, "LATE-002", result.CurrentSession.Code, "The overnight session should be current")
+	testutil.AssertEqual(t, 90, result.RemainingMinutes, "Remaining time should run past midnight")
+
+	setTestSessions(t, map[string][]Session{"Aug.9": state.Schedule})
+	ending := SessionsEndingSoon("Aug.9", "23:30", 120)
+	testutil.AssertEqual(t, "LATE-002", recommendationCodes(ending), "An overnight session ends within two hours of 23:30")
+}
+
+func TestGapsAroundMidnightEnd(t *testing.T) {
+	// A data reload may leave a session overlapping one that runs past midnight; that is no gap at all
+	state := &UserState{
+		SessionID: "test_gaps_midnight",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "GAP-001", Start: "21:00", End: "22:00", Room: "AU"},
+			{Code: "GAP-002", Start: "22:30", End: "01:00", Room: "TR211"},
+			{Code: "GAP-003", Start: "23:00", End: "23:30", Room: "AU"},
+		},
+		LastEndTime: "23:30",
+	}
+	storeTestUserState(t, state)
+
+	testutil.AssertEqual(t, 0, countLongGaps(state), "No gap should be longer than an hour")
+	stats, err := GetScheduleStatistics(state.SessionID)
+	testutil.AssertNoError(t, err, "Statistics should be computed")
+	testutil.AssertEqual(t, 30, stats["longest_gap_minutes"], "The longest gap is the half hour before the overnight session")
+	testutil.AssertEqual(t, false, strings.Contains(generateTimelineView(state), "01:00-23:00"), "Timeline should not show a gap after the overnight session")
+}
+
+func TestLastEndTimeAfterMidnightEnd(t *testing.T) {
+	setTestSessions(t, map[string][]Session{"Aug.9": {
+		{Code: "LATE-001", Title: "Evening Talk", Start: "21:00", End: "22:00", Room: "AU", Day: "Aug.9"},
+		{Code: "LATE-002", Title: "Overnight Hack", Start: "23:30", End: "00:30", Room: "TR211", Day: "Aug.9"},
+	}})
+	state := &UserState{SessionID: "test_last_end_midnight", Day: "Aug.9", LastEndTime: PlanningStartTime}
+	storeTestUserState(t, state)
+
+	for _, code := range []string{"LATE-001", "LATE-002"} {
+		_, err := ScheduleSession(state.SessionID, code)
+		testutil.AssertNoError(t, err, "Scheduling "+code+" should succeed")
+	}
+	testutil.AssertEqual(t, "23:59", GetUserStateSnapshot(state.SessionID).LastEndTime, "A session past midnight should end the day")
+	testutil.AssertEqual(t, "23:59", latestEndTime(GetUserStateSnapshot(state.SessionID).Schedule), "latestEndTime should agree")
+}
+
 // GetNextSession integration tests
 func TestGetNextSessionWithTime(t *testing.T) {
 	// Setup test data
@@ -601,6 +757,65 @@ func TestGetNextSessionWithTimeInvalidSession(t *testing.T) {
 	testutil.AssertEqual(t, "session nonexistent_session not found", err.Error(), "Error message should be correct")
 }
 
+func TestTransferFeasibilityIncludesArrivalBuffer(t *testing.T) {
+	tests := []struct {
+		name        string
+		nextStart   string
+		currentTime string
+		enoughTime  bool
+	}{
+		// AU → TR405 is a 4 minute walk; a 5 minute break covers the walk but not the settle-in buffer
+		{"Feasible on raw walk but not with buffer", "10:05", "10:00", false},
+		{"Break covers walk and buffer", "10:07", "10:00", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &UserState{
+				SessionID: "transfer_buffer",
+				Day:       "Aug.9",
+				Schedule: []Session{
+					{Code: "PREV", Start: "09:30", End: "10:00", Room: "AU"},
+					{Code: "NEXT", Start: tt.nextStart, End: "10:40", Room: "TR405"},
+				},
+			}
+
+			status := analyzeCurrentStatus(state, tt.currentTime)
+			testutil.AssertEqual(t, "just_ended", status.Status, "Should be just_ended")
+			testutil.AssertEqual(t, true, status.BreakMinutes >= status.Route.WalkingTime, "Raw walking time should fit the break")
+			testutil.AssertEqual(t, 4, status.Route.WalkingTime, "Raw walking time stays separate")
+			testutil.AssertEqual(t, 4+ArrivalBufferMinutes, status.Route.RequiredTime, "Required time includes buffer")
+			testutil.AssertEqual(t, tt.enoughTime, status.Route.EnoughTime, "EnoughTime should account for buffer")
+		})
+	}
+}
+
+func TestMobilityScalesTransferFeasibility(t *testing.T) {
+	testutil.AssertEqual(t, 6, scaleWalkingTime(4, MobilitySlow), "Slow mobility should inflate walking time")
+	testutil.AssertEqual(t, 3, scaleWalkingTime(4, MobilityFast), "Fast mobility should shorten walking time")
+	testutil.AssertEqual(t, 4, scaleWalkingTime(4, ""), "Unset mobility should use the base time")
+	testutil.AssertEqual(t, 0, scaleWalkingTime(0, MobilitySlow), "Staying put takes no time")
+
+	// AU → TR405 is a 4 minute walk; a 7 minute break just covers it with the settle-in buffer
+	state := &UserState{
+		SessionID: "mobility_transfer",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "PREV", Start: "09:30", End: "10:00", Room: "AU"},
+			{Code: "NEXT", Start: "10:07", End: "10:40", Room: "TR405"},
+		},
+	}
+
+	status := analyzeCurrentStatus(state, "10:00")
+	testutil.AssertEqual(t, true, status.Route.EnoughTime, "Normal pace should make the borderline transfer")
+
+	state.Mobility = MobilitySlow
+	status = analyzeCurrentStatus(state, "10:00")
+	testutil.AssertEqual(t, 6, status.Route.WalkingTime, "Slow pace walking time")
+	testutil.AssertEqual(t, 6+ArrivalBufferMinutes, status.Route.RequiredTime, "Required time should follow the scaled walk")
+	testutil.AssertEqual(t, false, status.Route.EnoughTime, "Slow pace should flip the transfer to hurry")
+}
+
 // Response builder tests
 func TestBuildOngoingResponse(t *testing.T) {
 	currentSession := &Session{
@@ -691,6 +906,48 @@ func TestBuildOngoingResponse(t *testing.T) {
 	}
 }
 
+func TestBuildOngoingResponseWrapUpNudge(t *testing.T) {
+	currentSession := &Session{Code: "WRAP-CUR", Title: "Current Session", Room: "AU", Start: "10:00", End: "10:30"}
+	nextSession := &Session{Code: "WRAP-NEXT", Title: "Next Session", Room: "TR211", Start: "10:40", End: "11:10"}
+
+	tests := []struct {
+		name       string
+		remaining  int
+		walking    int
+		enough     bool
+		wantWrapUp bool
+		wantText   string
+	}{
+		{"Plenty of time left", 20, AUToTRWalkTime, true, false, ""},
+		{"Few minutes left, long walk", 5, AUToTRWalkTime, true, true, "收拾東西"},
+		{"Few minutes left, tight transfer", 3, AUToTRWalkTime, false, true, "現在就離場"},
+		{"Same room", 2, 0, true, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &SessionStatus{
+				Status:           "ongoing",
+				CurrentSession:   currentSession,
+				NextSession:      nextSession,
+				RemainingMinutes: tt.remaining,
+				Route:            &RouteInfo{FromRoom: "AU", ToRoom: "TR211", WalkingTime: tt.walking, EnoughTime: tt.enough},
+			}
+			result := buildOngoingResponse(status)
+
+			_, wrapUp := result["wrap_up"]
+			testutil.AssertEqual(t, tt.wantWrapUp, wrapUp, "wrap_up flag")
+			message := result["message"].(string)
+			if tt.wantText != "" && !strings.HasPrefix(message, wrapUpNudge(status)) {
+				t.Errorf("Message should open with the nudge, got %q", message)
+			}
+			if tt.wantText != "" && !strings.Contains(message, tt.wantText) {
+				t.Errorf("Message should contain %q, got %q", tt.wantText, message)
+			}
+		})
+	}
+}
+
 func TestBuildBreakResponse(t *testing.T) {
 	nextSession := &Session{
 		Code:  "NEXT001",
@@ -784,7 +1041,7 @@ func TestBuildJustEndedResponse(t *testing.T) {
 	result := buildJustEndedResponse(status)
 
 	// Check required fields
-	expectedFields := []string{"status", "next_session", "break_minutes", "route", "message"}
+	expectedFields := []string{"status", "next_session", "break_minutes", "route", "route_steps", "message"}
 	for _, field := range expectedFields {
 		_, exists := result[field]
 		testutil.AssertEqual(t, true, exists, "Field "+field+" should exist")
@@ -804,10 +1061,11 @@ func TestBuildCompleteResponse(t *testing.T) {
 		Status: "schedule_complete",
 	}
 
-	result := buildCompleteResponse(status)
+	result := buildCompleteResponse(status, CompleteReasonFinishedPlanning)
 
 	// Check basic structure
 	testutil.AssertEqual(t, "schedule_complete", result["status"], "Status should be schedule_complete")
+	testutil.AssertEqual(t, CompleteReasonFinishedPlanning, result["reason"], "Reason should be included")
 
 	message, ok := result["message"].(string)
 	testutil.AssertEqual(t, true, ok, "Message should be string")
@@ -854,6 +1112,98 @@ func TestBuildStandardResponseNilData(t *testing.T) {
 	testutil.AssertEqual(t, 1, len(resultData), "Data should only contain sessionId")
 }
 
+// scanNextAvailableInEachRoom is the uncached reference for FindNextAvailableInEachRoom
+func scanNextAvailableInEachRoom(day, afterTime string, userSchedule []Session) []string {
+	roomSessions := make(map[string][]Session)
+	for _, session := range sessionData().byDay[day] {
+		roomSessions[session.Room] = append(roomSessions[session.Room], session)
+	}
+
+	var codes []string
+	for _, sessions := range roomSessions {
+		sorted := slices.Clone(sessions)
+		sortSessionsByStartTime(sorted)
+		for _, session := range sorted {
+			if timeToMinutes(session.Start) >= timeToMinutes(afterTime) && !hasConflictWithSchedule(session, userSchedule) {
+				codes = append(codes, session.Code)
+				break
+			}
+		}
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+func TestFindNextAvailableInEachRoomMatchesScan(t *testing.T) {
+	schedule := []Session{{Code: "BUSY", Start: "11:00", End: "12:00"}}
+	for _, day := range []string{DayFormatAug9, DayFormatAug10} {
+		for _, afterTime := range []string{"08:00", "10:00", "13:30", "16:00"} {
+			cached := FindNextAvailableInEachRoom(day, afterTime, schedule)
+			codes := make([]string, 0, len(cached))
+			for _, session := range cached {
+				codes = append(codes, session.Code)
+			}
+			slices.Sort(codes)
+
+			testutil.AssertEqual(t, strings.Join(scanNextAvailableInEachRoom(day, afterTime, schedule), ","), strings.Join(codes, ","),
+				fmt.Sprintf("Cached result should match a fresh scan on %s after %s", day, afterTime))
+		}
+	}
+}
+
+func TestFindNextAvailableInEachRoomCacheUpdatesAfterReload(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {
+			"AU": {
+				{Code: "ROOMCACHE-LATE", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+				{Code: "ROOMCACHE-EARLY", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			},
+		},
+	})
+
+	next := FindNextAvailableInEachRoom("Aug.9", "09:00", nil)
+	testutil.AssertEqual(t, "ROOMCACHE-EARLY", recommendationCodes(next), "Cache should reflect reloaded data sorted by start")
+	testutil.AssertEqual(t, 0, len(FindNextAvailableInEachRoom("Aug.10", "09:00", nil)), "Days missing from reloaded data should be empty")
+}
+
+func TestGetStartOptionsGracePeriod(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "GRACE-001", Title: "Welcome", Start: "09:00", End: "09:40", Room: "AU", Day: "Aug.9"},
+			{Code: "GRACE-002", Title: "Early Talk", Start: "09:00", End: "09:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "GRACE-003", Title: "Next Talk", Start: "09:30", End: "10:00", Room: "TR211", Day: "Aug.9"},
+			{Code: "GRACE-004", Title: "Keynote", Start: "09:50", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		currentTime string
+		grace       int
+		expected    string
+	}{
+		{"Before the first session", "08:30", 15, "GRACE-001,GRACE-002"},
+		{"Mid-session within grace", "09:15", 15, "GRACE-001,GRACE-002,GRACE-003,GRACE-004"},
+		{"Mid-session past grace", "09:25", 15, "GRACE-003,GRACE-004"},
+		{"Larger grace", "09:25", 30, "GRACE-001,GRACE-002,GRACE-003,GRACE-004"},
+		{"After the last session", "11:00", 15, "GRACE-001,GRACE-002"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := GetStartOptions("Aug.9", tt.currentTime, tt.grace)
+			codes := make([]string, 0, len(options))
+			for _, option := range options {
+				codes = append(codes, option.Code)
+			}
+			slices.Sort(codes)
+			testutil.AssertEqual(t, tt.expected, strings.Join(codes, ","), "Start options")
+		})
+	}
+}
+
 func TestGetFirstSessionClearsAbstract(t *testing.T) {
 	// Test that GetFirstSession returns sessions with cleared abstracts
 	firstSessions := GetFirstSession("Aug.10")
@@ -1114,6 +1464,18 @@ func TestPlanningAvailableStatusTrigger(t *testing.T) {
 			expectedStatus: "planning_available", // System finds available sessions
 			description:    "Should check for available sessions after completing planned ones",
 		},
+		{
+			name:           "Mid-afternoon with available slots",
+			currentTime:    "14:00",
+			expectedStatus: "planning_available",
+			description:    "Should still nudge when another session fits before the conference ends",
+		},
+		{
+			name:           "Near day's end",
+			currentTime:    "16:45",
+			expectedStatus: "schedule_complete",
+			description:    "Should not nudge when no minimum-length session fits before the conference ends",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1259,12 +1621,44 @@ func TestFinishPlanningWithDifferentScheduleSizes(t *testing.T) {
 
 // Room Schedule Tests
 
+func TestSortSessionsByStartTimeTieBreak(t *testing.T) {
+	sessions := []Session{
+		{Code: "TIE-C", Start: "10:00", Room: "TR211"},
+		{Code: "TIE-LATE", Start: "11:00", Room: "AU"},
+		{Code: "TIE-B", Start: "10:00", Room: "TR211"},
+		{Code: "TIE-A", Start: "10:00", Room: "TR212"},
+		{Code: "TIE-AU", Start: "10:00", Room: "AU"},
+	}
+
+	// Run repeatedly from shuffled input; the order must not depend on where each session started
+	for i := 0; i < 20; i++ {
+		shuffled := slices.Clone(sessions)
+		rand.New(rand.NewSource(int64(i))).Shuffle(len(shuffled), func(a, b int) {
+			shuffled[a], shuffled[b] = shuffled[b], shuffled[a]
+		})
+		sortSessionsByStartTime(shuffled)
+		testutil.AssertEqual(t, "TIE-AU,TIE-B,TIE-C,TIE-A,TIE-LATE", recommendationCodes(shuffled),
+			"Equal start times should sort by room, then code")
+	}
+}
+
+func TestFindRoomSessionsSameStartOrder(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "SAME-2", Start: "10:00", End: "10:15", Room: "TR211", Day: "Aug.9"},
+			{Code: "SAME-3", Start: "09:00", End: "09:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "SAME-1", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+
+	testutil.AssertEqual(t, "SAME-3,SAME-1,SAME-2", recommendationCodes(FindRoomSessions("Aug.9", "TR211")),
+		"Same-room sessions starting together should sort by code")
+}
+
 func TestFindRoomSessions(t *testing.T) {
 	// Mock session data for testing
-	originalSessionsByDay := sessionsByDay
-
 	// Setup test data
-	sessionsByDay = map[string][]Session{
+	setTestSessions(t, map[string][]Session{
 		"Aug.9": {
 			{
 				Code:  "TR211-001",
@@ -1309,12 +1703,7 @@ func TestFindRoomSessions(t *testing.T) {
 				Track: "ML",
 			},
 		},
-	}
-
-	// Restore original data after test
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	})
 
 	tests := []struct {
 		name          string
@@ -1424,15 +1813,9 @@ func TestGetCurrentRoomSession(t *testing.T) {
 	}
 
 	// Mock FindRoomSessions to return our test data
-	originalSessionsByDay := sessionsByDay
-
-	sessionsByDay = map[string][]Session{
+	setTestSessions(t, map[string][]Session{
 		"TestDay": testSessions,
-	}
-
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	})
 
 	tests := []struct {
 		name         string
@@ -1534,15 +1917,9 @@ func TestGetNextRoomSession(t *testing.T) {
 	}
 
 	// Mock FindRoomSessions
-	originalSessionsByDay := sessionsByDay
-
-	sessionsByDay = map[string][]Session{
+	setTestSessions(t, map[string][]Session{
 		"TestDay": testSessions,
-	}
-
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	})
 
 	tests := []struct {
 		name         string
@@ -1628,13 +2005,7 @@ func TestRoomScheduleEdgeCases(t *testing.T) {
 	// Test edge cases for room schedule functions
 
 	// Test with empty session data
-	originalSessionsByDay := sessionsByDay
-
-	sessionsByDay = map[string][]Session{}
-
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	setTestSessions(t, map[string][]Session{})
 
 	t.Run("Empty session data", func(t *testing.T) {
 		// Test FindRoomSessions with no data
@@ -1663,15 +2034,9 @@ func TestRoomScheduleTimeEdgeCases(t *testing.T) {
 		},
 	}
 
-	originalSessionsByDay := sessionsByDay
-
-	sessionsByDay = map[string][]Session{
+	setTestSessions(t, map[string][]Session{
 		"EdgeDay": testSessions,
-	}
-
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	})
 
 	tests := []struct {
 		name        string
@@ -1777,15 +2142,9 @@ func TestRoomScheduleMultipleRoomsData(t *testing.T) {
 		},
 	}
 
-	originalSessionsByDay := sessionsByDay
-
-	sessionsByDay = map[string][]Session{
+	setTestSessions(t, map[string][]Session{
 		"MixedDay": mixedSessions,
-	}
-
-	defer func() {
-		sessionsByDay = originalSessionsByDay
-	}()
+	})
 
 	t.Run("Filter TR211 sessions", func(t *testing.T) {
 		result := FindRoomSessions("MixedDay", "TR211")
@@ -1835,3 +2194,2750 @@ func TestRoomScheduleMultipleRoomsData(t *testing.T) {
 		testutil.AssertEqual(t, "AU-A", auNext.Code, "Next AU should be AU-A")
 	})
 }
+
+// Same building recommendation tests
+
+func TestGetRecommendationsInSameBuilding(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TR-A", Title: "TR Morning", Start: "09:00", End: "09:30", Room: "TR209", Day: "Aug.9"},
+			{Code: "TR-B", Title: "TR Next", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "TR-C", Title: "TR Upstairs", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"},
+			{Code: "AU-A", Title: "AU Next", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "RB-A", Title: "RB Next", Start: "10:00", End: "10:30", Room: "RB-105", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:    "test_same_building",
+		Day:          "Aug.9",
+		Schedule:     []Session{{Code: "TR-A", Start: "09:00", End: "09:30", Room: "TR209", Day: "Aug.9"}},
+		LastEndTime:  "09:30",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	result, building, err := GetRecommendationsInSameBuilding(state.SessionID)
+	testutil.AssertNoError(t, err, "Should not return error")
+	testutil.AssertEqual(t, "TR", building, "Building should be derived from last session")
+	testutil.AssertEqual(t, 2, len(result), "Should only return TR sessions")
+	for _, session := range result {
+		testutil.AssertEqual(t, "TR", getBuildingFromRoom(session.Room), "Session "+session.Code+" should be in TR")
+	}
+}
+
+func TestGetRecommendationsInSameBuildingFallback(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TR-A", Title: "TR Morning", Start: "09:00", End: "09:30", Room: "TR209", Day: "Aug.9"},
+			{Code: "AU-A", Title: "AU Next", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "RB-A", Title: "RB Next", Start: "10:00", End: "10:30", Room: "RB-105", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:    "test_same_building_fallback",
+		Day:          "Aug.9",
+		Schedule:     []Session{{Code: "TR-A", Start: "09:00", End: "09:30", Room: "TR209", Day: "Aug.9"}},
+		LastEndTime:  "09:30",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	result, building, err := GetRecommendationsInSameBuilding(state.SessionID)
+	testutil.AssertNoError(t, err, "Should not return error")
+	testutil.AssertEqual(t, "", building, "Building should be empty when falling back")
+	testutil.AssertEqual(t, 2, len(result), "Should fall back to all rooms")
+}
+
+func TestGetRecommendationsInSameBuildingEmptySchedule(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "AU-A", Title: "AU Next", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "TR-B", Title: "TR Next", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:    "test_same_building_empty",
+		Day:          "Aug.9",
+		Schedule:     []Session{},
+		LastEndTime:  "08:00",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	result, building, err := GetRecommendationsInSameBuilding(state.SessionID)
+	testutil.AssertNoError(t, err, "Should not return error")
+	testutil.AssertEqual(t, "", building, "No building without a schedule")
+	testutil.AssertEqual(t, 2, len(result), "Should return all rooms without a schedule")
+}
+
+// Conference timezone tests
+
+func TestConferenceLocation(t *testing.T) {
+	_, offset := time.Date(2025, 8, 9, 12, 0, 0, 0, conferenceLocation()).Zone()
+	testutil.AssertEqual(t, 8*60*60, offset, "Conference timezone should be UTC+8")
+
+	now := conferenceNow()
+	testutil.AssertEqual(t, "+08:00", now.Format("-07:00"), "conferenceNow should carry +08:00 offset")
+}
+
+func TestUTCClockMapsToTaipeiWallClock(t *testing.T) {
+	tests := []struct {
+		name         string
+		utcTime      time.Time
+		expectedTime string
+		expectedDay  string
+	}{
+		{"Morning Aug9", time.Date(2025, 8, 9, 2, 15, 0, 0, time.UTC), "10:15", DayAug9},
+		{"Aug8 UTC evening is Aug9 morning", time.Date(2025, 8, 8, 23, 30, 0, 0, time.UTC), "07:30", DayAug9},
+		{"Aug9 UTC evening is Aug10", time.Date(2025, 8, 9, 17, 0, 0, 0, time.UTC), "01:00", DayAug10},
+		{"Aug10 UTC evening is after COSCUP", time.Date(2025, 8, 10, 16, 30, 0, 0, time.UTC), "00:30", StatusOutsideCOSCUP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.AssertEqual(t, tt.expectedTime, formatTimeForSession(tt.utcTime), "Wall-clock time should be in Taipei")
+			testutil.AssertEqual(t, tt.expectedDay, getCOSCUPDay(tt.utcTime), "COSCUP day should be derived in Taipei")
+			testutil.AssertEqual(t, tt.expectedDay != StatusOutsideCOSCUP, isInCOSCUPPeriod(tt.utcTime), "COSCUP period should be derived in Taipei")
+		})
+	}
+}
+
+func TestGetNextSessionWithUTCClock(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_utc_clock",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "UTC001", Title: "Morning Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+		LastEndTime:  "10:30",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	// 02:15 UTC is 10:15 in Taipei, during the session
+	utcClock := testutil.NewMockTimeProviderWithTime(time.Date(2025, 8, 9, 2, 15, 0, 0, time.UTC))
+	result, err := GetNextSessionWithTime(state.SessionID, utcClock)
+	testutil.AssertNoError(t, err, "Should not return error")
+	testutil.AssertEqual(t, "ongoing", result["status"], "UTC clock should map to an ongoing Taipei session")
+	testutil.AssertEqual(t, 15, result["remaining_minutes"], "Remaining minutes should use Taipei wall-clock")
+}
+
+func TestExportedTimestampsCarryTaipeiOffset(t *testing.T) {
+	stats := GetSessionStats()
+	timestamp, ok := stats["timestamp"].(string)
+	testutil.AssertEqual(t, true, ok, "timestamp should be string")
+	testutil.AssertEqual(t, true, strings.HasSuffix(timestamp, "+08:00"), "Stats timestamp should carry +08:00, got "+timestamp)
+
+	sessionID := "test_export_timestamps"
+	CreateUserState(sessionID, "Aug.9")
+	defer func() {
+		shardIndex := getShardIndex(sessionID)
+		sessionShards[shardIndex].mu.Lock()
+		delete(sessionShards[shardIndex].sessions, sessionID)
+		sessionShards[shardIndex].mu.Unlock()
+	}()
+
+	encoded, err := json.Marshal(GetUserState(sessionID))
+	testutil.AssertNoError(t, err, "User state should marshal")
+	testutil.AssertEqual(t, true, strings.Contains(string(encoded), "+08:00"), "User state timestamps should carry +08:00")
+}
+
+// Walking distance tests
+
+func TestCalculateWalkingDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		fromRoom string
+		toRoom   string
+		expected int
+	}{
+		{"Same room", "TR209", "TR209", 0},
+		{"AU to RB", "AU", "RB-105", AUToRBWalkDistance},
+		{"RB to TR", "RB-101", "TR405", RBToTRWalkDistance},
+		{"TR to AU", "TR515", "AU", TRToAUWalkDistance},
+		{"Within TR", "TR209", "TR515", TRInternalWalkDistance},
+		{"Unknown building", "UNKNOWN", "AU", UnknownWalkDistance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := calculateWalkingDistance(tt.fromRoom, tt.toRoom)
+			testutil.AssertEqual(t, tt.expected, result, "calculateWalkingDistance result")
+		})
+	}
+}
+
+func TestEstimateWalkingDistance(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_walking_distance",
+		Day:       "Aug.9",
+		// Deliberately out of order to verify the schedule is sorted first
+		Schedule: []Session{
+			{Code: "D", Start: "13:00", End: "13:30", Room: "TR209"},
+			{Code: "A", Start: "09:00", End: "09:30", Room: "AU"},
+			{Code: "C", Start: "11:00", End: "11:30", Room: "TR405"},
+			{Code: "B", Start: "10:00", End: "10:30", Room: "RB-105"},
+			{Code: "E", Start: "14:00", End: "14:30", Room: "TR209"},
+		},
+		LastEndTime:  "14:30",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	// AU -> RB -> TR -> TR (other room) -> same room
+	expected := AUToRBWalkDistance + RBToTRWalkDistance + TRInternalWalkDistance + 0
+	testutil.AssertEqual(t, expected, EstimateWalkingDistance(state.SessionID), "Total distance should sum every transition")
+	testutil.AssertEqual(t, 0, EstimateWalkingDistance("nonexistent_session"), "Unknown session should have no distance")
+}
+
+// Wrong day guard tests
+
+func TestAddSessionToScheduleRejectsWrongDay(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "DAY9-001", Title: "Aug9 Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Track: "AI"},
+		},
+		"Aug.10": {
+			{Code: "DAY10-001", Title: "Aug10 Session", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.10", Track: "AI"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:    "test_wrong_day",
+		Day:          "Aug.9",
+		Schedule:     []Session{},
+		LastEndTime:  "08:00",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	err := AddSessionToSchedule(state.SessionID, "DAY10-001")
+	testutil.AssertError(t, err, "Should reject session from another day")
+	testutil.AssertEqual(t, true, strings.Contains(err.Error(), "Aug.10"), "Error should name the session's day")
+	testutil.AssertEqual(t, true, strings.Contains(err.Error(), "Aug.9"), "Error should name the planning day")
+	testutil.AssertEqual(t, 0, len(GetUserState(state.SessionID).Schedule), "Schedule should be untouched")
+
+	err = AddSessionToSchedule(state.SessionID, "DAY9-001")
+	testutil.AssertNoError(t, err, "Should accept session from the planning day")
+	testutil.AssertEqual(t, 1, len(GetUserState(state.SessionID).Schedule), "Schedule should contain the same-day session")
+}
+
+// Session ID parsing tests
+
+func TestParseDayFromSessionID(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionID   string
+		expected    string
+		expectError bool
+	}{
+		{"Aug9 session", "user_09_1754700000_0123456789abcdef", DayAug9, false},
+		{"Aug10 session", "user_10_1754700000_0123456789abcdef", DayAug10, false},
+		{"Fallback format", "user_09_1754700000123456789_fallback", DayAug9, false},
+		{"Generated Aug10 ID", GenerateSecureSessionID("10"), DayAug10, false},
+		{"Unknown day code", "user_11_1754700000_0123456789abcdef", "", true},
+		{"Wrong prefix", "guest_09_1754700000_0123456789abcdef", "", true},
+		{"Too few parts", "user_09", "", true},
+		{"Empty string", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day, err := ParseDayFromSessionID(tt.sessionID)
+			if tt.expectError {
+				testutil.AssertEqual(t, ErrInvalidSessionID, err, "Should reject malformed session ID")
+				return
+			}
+			testutil.AssertNoError(t, err, "Should parse session ID")
+			testutil.AssertEqual(t, tt.expected, day, "Parsed day")
+		})
+	}
+}
+
+// Concurrency tests (run with -race)
+
+func TestGetNextSessionWithTimeConcurrentAdds(t *testing.T) {
+	sessionID := "test_concurrent_status"
+	CreateUserState(sessionID, "Aug.9")
+	defer func() {
+		shardIndex := getShardIndex(sessionID)
+		sessionShards[shardIndex].mu.Lock()
+		delete(sessionShards[shardIndex].sessions, sessionID)
+		sessionShards[shardIndex].mu.Unlock()
+	}()
+
+	var codes []string
+	for _, session := range sessionData().byDay["Aug.9"] {
+		codes = append(codes, session.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, code := range codes {
+			// Conflicts are expected and irrelevant here; we only exercise concurrent writes
+			_ = AddSessionToSchedule(sessionID, code)
+		}
+	}()
+
+	mockTimeProvider := testutil.NewMockTimeProvider("11:00")
+	for range 50 {
+		_, err := GetNextSessionWithTime(sessionID, mockTimeProvider)
+		testutil.AssertNoError(t, err, "GetNextSessionWithTime should not fail during concurrent adds")
+	}
+	<-done
+
+	state := GetUserStateSnapshot(sessionID)
+	testutil.AssertEqual(t, true, len(state.Schedule) > 0, "Concurrent adds should have been recorded")
+}
+
+func TestGetUserStateSnapshotIsIndependent(t *testing.T) {
+	state := &UserState{
+		SessionID:    "test_snapshot_copy",
+		Day:          "Aug.9",
+		Schedule:     []Session{{Code: "SNAP001", Start: "09:00", End: "09:30"}},
+		LastEndTime:  "09:30",
+		Profile:      []string{"AI"},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	snapshot := GetUserStateSnapshot(state.SessionID)
+	snapshot.Schedule[0].Code = "CHANGED"
+	snapshot.Profile[0] = "CHANGED"
+
+	testutil.AssertEqual(t, "SNAP001", state.Schedule[0].Code, "Snapshot schedule should not alias stored state")
+	testutil.AssertEqual(t, "AI", state.Profile[0], "Snapshot profile should not alias stored state")
+	testutil.AssertEqual(t, (*UserState)(nil), GetUserStateSnapshot("nonexistent_session"), "Unknown session should return nil")
+}
+
+// Room grouped timeline tests
+
+func TestGenerateRoomTimelineView(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_room_timeline",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "R4", Title: "AU Afternoon", Start: "13:00", End: "13:30", Room: "AU"},
+			{Code: "R3", Title: "TR211 Late Morning", Start: "11:00", End: "11:30", Room: "TR211"},
+			{Code: "R1", Title: "AU Morning", Start: "09:00", End: "09:30", Room: "AU"},
+			{Code: "R2", Title: "TR211 Morning", Start: "09:40", End: "10:30", Room: "TR211"},
+		},
+	}
+
+	view := generateRoomTimelineView(state)
+
+	auHeader := strings.Index(view, "📍 AU（2 場）")
+	trHeader := strings.Index(view, "📍 TR211（2 場）")
+	testutil.AssertEqual(t, true, auHeader >= 0, "AU group should be present")
+	testutil.AssertEqual(t, true, trHeader > auHeader, "TR211 group should follow AU (first session later)")
+
+	positions := []int{
+		strings.Index(view, "AU Morning"),
+		strings.Index(view, "AU Afternoon"),
+		strings.Index(view, "TR211 Morning"),
+		strings.Index(view, "TR211 Late Morning"),
+	}
+	for i := 1; i < len(positions); i++ {
+		testutil.AssertEqual(t, true, positions[i-1] >= 0 && positions[i-1] < positions[i],
+			fmt.Sprintf("Session %d should appear before session %d", i-1, i))
+	}
+
+	// Sessions must sit under their own room header
+	testutil.AssertEqual(t, true, positions[1] < trHeader, "AU sessions should be listed before the TR211 header")
+	testutil.AssertEqual(t, true, positions[2] > trHeader, "TR211 sessions should be listed under the TR211 header")
+	testutil.AssertEqual(t, true, strings.Contains(view, "分佈在 2 個場地"), "Statistics should count rooms")
+}
+
+func TestGenerateRoomTimelineViewEmpty(t *testing.T) {
+	state := &UserState{SessionID: "test_room_timeline_empty", Day: "Aug.9"}
+	testutil.AssertEqual(t, "尚未選擇任何議程", generateRoomTimelineView(state), "Empty schedule message")
+}
+
+func TestBuildTimelineEntriesFlagsRoomChanges(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_moves_timeline",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "MV3", Title: "Third", Start: "11:00", End: "11:30", Room: "TR405"},
+			{Code: "MV1", Title: "First", Start: "09:00", End: "09:30", Room: "AU"},
+			{Code: "MV2", Title: "Second", Start: "10:00", End: "10:30", Room: "AU"},
+		},
+	}
+
+	entries := buildTimelineEntries(state)
+	testutil.AssertEqual(t, 3, len(entries), "Every session should have an entry")
+	testutil.AssertEqual(t, false, entries[0].RoomChange, "The first session has nothing to move from")
+	testutil.AssertEqual(t, false, entries[1].RoomChange, "Staying in AU is not a move")
+	testutil.AssertEqual(t, true, entries[2].RoomChange, "AU to TR405 should be flagged")
+	testutil.AssertEqual(t, "AU", entries[2].FromRoom, "Move should record where it starts")
+	testutil.AssertEqual(t, 4, entries[2].WalkMinutes, "Move should carry the walking time")
+
+	view := generateMovesTimelineView(state)
+	testutil.AssertEqual(t, true, strings.Contains(view, "從 AU 前往 TR405，步行約 4 分鐘"), "Room change should be highlighted")
+	testutil.AssertEqual(t, 1, strings.Count(view, "🚶"), "Only the room change should be highlighted")
+	testutil.AssertEqual(t, true, strings.Contains(view, "留在同一教室"), "Same-room transition should be noted briefly")
+	testutil.AssertEqual(t, true, strings.Contains(view, "換教室 1 次"), "Statistics should count moves")
+}
+
+// Conflict counter tests
+
+func TestConflictRejectionCounter(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "CNT-001", Title: "Base Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "CNT-002", Title: "Overlap A", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "CNT-003", Title: "Overlap B", Start: "10:15", End: "10:45", Room: "TR212", Day: "Aug.9"},
+			{Code: "CNT-004", Title: "Later Session", Start: "11:00", End: "11:30", Room: "TR213", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:    "test_conflict_counter",
+		Day:          "Aug.9",
+		Schedule:     []Session{},
+		LastEndTime:  "08:00",
+		Profile:      []string{},
+		CreatedAt:    time.Now(),
+		LastActivity: time.Now(),
+	}
+	storeTestUserState(t, state)
+
+	before := GetSessionStats()
+
+	testutil.AssertNoError(t, AddSessionToSchedule(state.SessionID, "CNT-001"), "First add should succeed")
+	testutil.AssertError(t, AddSessionToSchedule(state.SessionID, "CNT-002"), "Overlap A should conflict")
+	testutil.AssertError(t, AddSessionToSchedule(state.SessionID, "CNT-003"), "Overlap B should conflict")
+	testutil.AssertNoError(t, AddSessionToSchedule(state.SessionID, "CNT-004"), "Later add should succeed")
+
+	after := GetSessionStats()
+
+	testutil.AssertEqual(t, int64(2), after["conflict_rejections"].(int64)-before["conflict_rejections"].(int64),
+		"Conflict counter should count both rejections")
+	testutil.AssertEqual(t, int64(2), after["schedule_additions"].(int64)-before["schedule_additions"].(int64),
+		"Addition counter should count both successes")
+}
+
+func TestSessionStatsShardLockCounters(t *testing.T) {
+	state := &UserState{SessionID: "test_shard_lock_counters", Day: "Aug.9"}
+	storeTestUserState(t, state)
+	shardIndex := getShardIndex(state.SessionID)
+
+	before := GetSessionStats()
+	GetUserState(state.SessionID)
+	GetUserStateSnapshot(state.SessionID)
+	after := GetSessionStats()
+
+	acquired := after["shard_lock_acquisitions"].([]int64)[shardIndex] - before["shard_lock_acquisitions"].([]int64)[shardIndex]
+	testutil.AssertEqual(t, true, acquired >= 2, "Each access should count a lock acquisition on its shard")
+	testutil.AssertEqual(t, NumShards, len(after["shard_lock_contended"].([]int64)), "Contention should be reported per shard")
+
+	// Hold the shard lock so the next access has to wait
+	shard := sessionShards[shardIndex]
+	contendedBefore := shard.contended.Load()
+	shard.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		GetUserStateSnapshot(state.SessionID)
+		close(done)
+	}()
+	for shard.contended.Load() == contendedBefore {
+		time.Sleep(time.Millisecond)
+	}
+	shard.mu.Unlock()
+	<-done
+
+	contended := GetSessionStats()["shard_lock_contended"].([]int64)[shardIndex]
+	testutil.AssertEqual(t, true, contended > contendedBefore, "Waiting for a held lock should count as contended")
+}
+
+// Ending soon tests
+
+func TestSessionsEndingSoon(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "END-001", Title: "Ends Last In Window", Start: "10:00", End: "11:10", Room: "AU", Day: "Aug.9", Abstract: "long abstract"},
+			{Code: "END-002", Title: "Ends First In Window", Start: "10:30", End: "11:05", Room: "TR211", Day: "Aug.9"},
+			{Code: "END-003", Title: "Ends After Window", Start: "10:30", End: "11:30", Room: "TR212", Day: "Aug.9"},
+			{Code: "END-004", Title: "Already Ended", Start: "10:00", End: "11:00", Room: "TR213", Day: "Aug.9"},
+			{Code: "END-005", Title: "Not Started", Start: "11:05", End: "11:10", Room: "RB-101", Day: "Aug.9"},
+		},
+	})
+
+	result := SessionsEndingSoon("Aug.9", "11:00", 15)
+
+	testutil.AssertEqual(t, 2, len(result), "Only the two running sessions ending within 15 minutes should match")
+	testutil.AssertEqual(t, "END-002", result[0].Code, "Earliest ending session should come first")
+	testutil.AssertEqual(t, "END-001", result[1].Code, "Later ending session should come second")
+	testutil.AssertEqual(t, "", result[1].Abstract, "Results should be simplified")
+
+	testutil.AssertEqual(t, 3, len(SessionsEndingSoon("Aug.9", "11:00", 30)), "Wider window should include the 11:30 session")
+	testutil.AssertEqual(t, 0, len(SessionsEndingSoon("Aug.10", "11:00", 15)), "Day without data should be empty")
+}
+
+func TestLiveGrid(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "NOW-001", Title: "Running In TR212", Start: "10:30", End: "11:30", Room: "TR212", Day: "Aug.9"},
+			{Code: "NOW-002", Title: "Running In TR211", Start: "10:50", End: "11:20", Room: "TR211", Day: "Aug.9", Abstract: "long abstract"},
+			{Code: "NOW-003", Title: "Running In AU", Start: "10:00", End: "12:00", Room: "AU", Day: "Aug.9"},
+			{Code: "NOW-004", Title: "Already Ended", Start: "10:00", End: "11:00", Room: "RB-105", Day: "Aug.9"},
+			{Code: "NOW-005", Title: "Starts Soon", Start: "11:10", End: "11:40", Room: "RB-105", Day: "Aug.9"},
+			{Code: "NOW-006", Title: "Starts Later", Start: "11:30", End: "12:00", Room: "TR213", Day: "Aug.9"},
+		},
+	})
+
+	grid := LiveGrid("Aug.9", "11:00")
+
+	testutil.AssertEqual(t, 2, len(grid), "Only buildings with running sessions should be listed")
+	tr := grid[buildingDisplayName(BuildingTR)]
+	testutil.AssertEqual(t, 2, len(tr), "Both running TR rooms should be listed")
+	testutil.AssertEqual(t, "NOW-002", tr[0].Code, "Rooms should be sorted within a building")
+	testutil.AssertEqual(t, "NOW-001", tr[1].Code, "Rooms should be sorted within a building")
+	testutil.AssertEqual(t, "NOW-003", grid[buildingDisplayName(BuildingAU)][0].Code, "AU should show its running session")
+	testutil.AssertEqual(t, 0, len(LiveGrid("Aug.10", "11:00")), "Day without data should be empty")
+
+	soon := SessionsStartingSoon("Aug.9", "11:00", 15)
+	testutil.AssertEqual(t, 1, len(soon), "Only sessions starting within 15 minutes should match")
+	testutil.AssertEqual(t, "NOW-005", soon[0].Code, "Session starting at 11:10 should match")
+	testutil.AssertEqual(t, 2, len(SessionsStartingSoon("Aug.9", "11:00", 30)), "Wider window should include the 11:30 session")
+}
+
+// Route steps tests
+
+func TestBuildTripPlan(t *testing.T) {
+	t.Run("Cross-building move", func(t *testing.T) {
+		steps := BuildTripPlan("AU", "TR405")
+
+		testutil.AssertEqual(t, 3, len(steps), "Cross-building move should have leave, walk and arrive steps")
+		testutil.AssertEqual(t, "leave", steps[0].Action, "First step should leave the room")
+		testutil.AssertEqual(t, "AU", steps[0].Room, "First step should start from AU")
+		testutil.AssertEqual(t, "walk", steps[1].Action, "Second step should be walking")
+		testutil.AssertEqual(t, AUToTRWalkTime, steps[1].Minutes, "Walk step should carry the walking time")
+		testutil.AssertEqual(t, "arrive", steps[2].Action, "Last step should arrive")
+		testutil.AssertEqual(t, "TR405", steps[2].Room, "Last step should end at TR405")
+	})
+
+	t.Run("Same room", func(t *testing.T) {
+		steps := BuildTripPlan("TR211", "TR211")
+
+		testutil.AssertEqual(t, 1, len(steps), "Same room should have a single step")
+		testutil.AssertEqual(t, "stay", steps[0].Action, "Single step should be stay")
+		testutil.AssertEqual(t, 0, steps[0].Minutes, "Staying should take no time")
+	})
+
+	t.Run("No previous room", func(t *testing.T) {
+		steps := BuildTripPlan("", "RB-105")
+		testutil.AssertEqual(t, 1, len(steps), "Missing origin should have a single step")
+	})
+}
+
+func TestRouteStepsInNextSessionResponses(t *testing.T) {
+	nextSession := &Session{Code: "NEXT001", Title: "Next Session", Room: "TR405", Start: "11:00", End: "11:30"}
+
+	crossBuilding := &SessionStatus{
+		NextSession:  nextSession,
+		BreakMinutes: 10,
+		Route:        calculateRoute(&Session{Room: "AU"}, nextSession, 10),
+	}
+	sameRoom := &SessionStatus{
+		NextSession:  nextSession,
+		BreakMinutes: 10,
+		Route:        calculateRoute(&Session{Room: "TR405"}, nextSession, 10),
+	}
+
+	for name, build := range map[string]func(*SessionStatus) map[string]any{
+		"break":      buildBreakResponse,
+		"just_ended": buildJustEndedResponse,
+	} {
+		t.Run(name, func(t *testing.T) {
+			steps, ok := build(crossBuilding)["route_steps"].([]RouteStep)
+			testutil.AssertEqual(t, true, ok, "route_steps should be a []RouteStep")
+			testutil.AssertEqual(t, true, len(steps) > 1, "Cross-building route should have multiple steps")
+
+			steps = build(sameRoom)["route_steps"].([]RouteStep)
+			testutil.AssertEqual(t, 1, len(steps), "Same-room route should have one step")
+
+			_, hasRoute := build(crossBuilding)["route"]
+			testutil.AssertEqual(t, true, hasRoute, "Existing route object should be kept")
+		})
+	}
+}
+
+// Plan density tests
+
+func TestGenerateStarterPlan(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "START-KEY", Title: "Opening Keynote", Track: "Main", Start: "10:00", End: "10:40", Room: "AU", Day: "Aug.9"},
+			{Code: "START-R1", Title: "Rust 1", Track: "Rust", Start: "09:30", End: "10:00", Room: "TR211", Day: "Aug.9"},
+			{Code: "START-R2", Title: "Rust 2", Track: "Rust", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "START-R3", Title: "Rust 3", Track: "Rust", Start: "14:00", End: "14:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "START-G1", Title: "Go 1", Track: "Go", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+			{Code: "START-D1", Title: "Data 1", Track: "Data", Start: "14:00", End: "14:30", Room: "RB-105", Day: "Aug.9"},
+			{Code: "START-L1", Title: "Lunch Talk", Track: "Go", Start: "12:15", End: "12:45", Room: "AU", Day: "Aug.9"},
+		},
+	})
+
+	plan := GenerateStarterPlan("Aug.9")
+
+	testutil.AssertEqual(t, true, slices.ContainsFunc(plan, func(s Session) bool { return s.Code == "START-KEY" }), "Keynote should be included")
+	testutil.AssertEqual(t, false, slices.ContainsFunc(plan, func(s Session) bool { return s.Code == "START-L1" }), "Lunch should be kept free")
+	for i := 1; i < len(plan); i++ {
+		testutil.AssertEqual(t, true, transferFits(plan[i-1], plan[i], MobilityNormal),
+			fmt.Sprintf("%s to %s should be conflict-free and walkable", plan[i-1].Code, plan[i].Code))
+	}
+
+	tracks := make(map[string]bool)
+	for _, session := range plan {
+		tracks[session.Track] = true
+	}
+	testutil.AssertEqual(t, true, len(tracks) >= 3, "Starter plan should span multiple tracks")
+	// Rust 1 leaves no time to walk to the keynote; Rust outnumbers Go at 11:00; Data is unused at 14:00
+	testutil.AssertEqual(t, "START-KEY,START-R2,START-D1", recommendationCodes(plan), "Ties should prefer unused, then bigger tracks")
+}
+
+func TestSuggestFeasibleSubset(t *testing.T) {
+	// AU → TR405 is a 4 minute walk, so a 5 minute break is too short once the settle-in buffer counts
+	state := &UserState{
+		SessionID: "test_feasible_subset",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "FIX-001", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "FIX-002", Start: "10:35", End: "11:00", Room: "TR405"},
+			{Code: "FIX-003", Start: "11:00", End: "11:30", Room: "AU"},
+			{Code: "FIX-004", Start: "11:30", End: "12:00", Room: "AU"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	subset := SuggestFeasibleSubset(state.SessionID)
+	testutil.AssertEqual(t, "FIX-001,FIX-003,FIX-004", recommendationCodes(subset), "Dropping the single rushed session should fix every transfer")
+	testutil.AssertEqual(t, 4, len(GetUserStateSnapshot(state.SessionID).Schedule), "The schedule should not be changed")
+
+	feasible := &UserState{
+		SessionID: "test_feasible_subset_ok",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "OK-001", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "OK-002", Start: "10:40", End: "11:00", Room: "TR405"},
+		},
+	}
+	storeTestUserState(t, feasible)
+	testutil.AssertEqual(t, "OK-001,OK-002", recommendationCodes(SuggestFeasibleSubset(feasible.SessionID)), "Feasible schedules are kept whole")
+}
+
+func TestFindImpossibleTransfers(t *testing.T) {
+	// TR515 → AU is a 4 minute walk; a 3 minute break is within the walk but misses the settle-in buffer
+	state := &UserState{
+		SessionID: "test_impossible_transfers",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "IMP-001", Start: "10:00", End: "10:30", Room: "TR515"},
+			{Code: "IMP-002", Start: "10:30", End: "11:00", Room: "AU"},
+			{Code: "IMP-003", Start: "11:00", End: "11:30", Room: "AU"},
+			{Code: "IMP-004", Start: "11:34", End: "12:00", Room: "TR515"},
+			{Code: "IMP-005", Start: "12:01", End: "12:30", Room: "AU", Cancelled: true},
+		},
+	}
+	storeTestUserState(t, state)
+
+	transfers := FindImpossibleTransfers(state.SessionID)
+	testutil.AssertEqual(t, 1, len(transfers), "Only the zero-gap building change is impossible")
+	testutil.AssertEqual(t, "IMP-001", transfers[0].FromCode, "Transfer should start at the TR515 session")
+	testutil.AssertEqual(t, "IMP-002", transfers[0].ToCode, "Transfer should end at the AU session")
+	testutil.AssertEqual(t, 0, transfers[0].GapMinutes, "Back-to-back sessions leave no gap")
+	testutil.AssertEqual(t, TRToAUWalkTime, transfers[0].DeficitMinutes, "The whole walk is missing")
+
+	state.Mobility = MobilitySlow
+	storeTestUserState(t, state)
+	testutil.AssertEqual(t, 2, len(FindImpossibleTransfers(state.SessionID)), "Slower walking should make the 4 minute break impossible too")
+
+	testutil.AssertEqual(t, 0, len(FindImpossibleTransfers("test_impossible_missing")), "Unknown sessions have no transfers")
+}
+
+func TestAssessPlanDensity(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_plan_density",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "DEN-001", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "DEN-002", Start: "10:30", End: "11:00", Room: "TR211"},  // back-to-back cross-building: tight
+			{Code: "DEN-003", Start: "11:05", End: "11:30", Room: "RB-105"}, // 5 min break, 3 min walk: tight
+			{Code: "DEN-004", Start: "11:40", End: "12:00", Room: "AU"},     // 10 min break, 2 min walk: relaxed
+			{Code: "DEN-005", Start: "12:00", End: "12:30", Room: "TR212"},  // back-to-back cross-building: tight
+			{Code: "DEN-006", Start: "12:30", End: "13:00", Room: "TR212"},  // same room: never tight
+		},
+	}
+	storeTestUserState(t, state)
+
+	tight, total := AssessPlanDensity(state.SessionID)
+	testutil.AssertEqual(t, 3, tight, "Three cross-building transfers should be tight")
+	testutil.AssertEqual(t, 5, total, "Six sessions should have five transfers")
+	testutil.AssertEqual(t, true, planDensityWarning(tight, total) != "", "Mostly tight plan should warn")
+
+	tight, total = AssessPlanDensity("nonexistent_session")
+	testutil.AssertEqual(t, 0, tight, "Unknown session has no tight transfers")
+	testutil.AssertEqual(t, 0, total, "Unknown session has no transfers")
+}
+
+func TestPlanDensityWarning(t *testing.T) {
+	testutil.AssertEqual(t, "", planDensityWarning(0, 0), "Empty plan should not warn")
+	testutil.AssertEqual(t, "", planDensityWarning(2, 4), "Half tight should not warn")
+	testutil.AssertEqual(t, true, strings.Contains(planDensityWarning(3, 4), "行程相當緊湊"), "Mostly tight should warn")
+}
+
+// Room schedule detail tests
+
+func TestWithSessionDetails(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "DET-001", Title: "First", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Abstract: "First abstract", Difficulty: "入門"},
+			{Code: "DET-002", Title: "Second", Start: "10:30", End: "11:00", Room: "AU", Day: "Aug.9", Abstract: "Second abstract", Difficulty: "中階"},
+			{Code: "DET-003", Title: "Third", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9", Abstract: "Third abstract"},
+		},
+	})
+
+	simplified := FindRoomSessions("Aug.9", "AU")
+	for _, session := range simplified {
+		testutil.AssertEqual(t, "", session.Abstract, "Abstract should be absent without include_detail")
+	}
+
+	detailed, truncated := withSessionDetails(simplified, 10)
+	testutil.AssertEqual(t, false, truncated, "Three sessions should fit the cap")
+	testutil.AssertEqual(t, 3, len(detailed), "All sessions should be returned")
+	testutil.AssertEqual(t, "First abstract", detailed[0].Abstract, "Abstract should be present with include_detail")
+	testutil.AssertEqual(t, "中階", detailed[1].Difficulty, "Difficulty should be kept")
+
+	capped, truncated := withSessionDetails(simplified, 2)
+	testutil.AssertEqual(t, true, truncated, "Exceeding the cap should report truncation")
+	testutil.AssertEqual(t, 2, len(capped), "Result should be capped")
+	testutil.AssertEqual(t, "DET-002", capped[1].Code, "Capping should keep chronological order")
+
+	testutil.AssertEqual(t, "", simplified[0].Abstract, "Input sessions should not be modified")
+}
+
+// Recommendation ordering tests
+
+func tiedRecommendations() []Session {
+	var sessions []Session
+	for i := range 8 {
+		sessions = append(sessions, Session{Code: fmt.Sprintf("TIE-%03d", i), Start: "10:00", End: "10:30"})
+	}
+	sessions = append(sessions, Session{Code: "EARLY-001", Start: "09:30", End: "10:00"})
+	return sessions
+}
+
+func recommendationCodes(sessions []Session) string {
+	codes := make([]string, len(sessions))
+	for i, session := range sessions {
+		codes[i] = session.Code
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestOrderRecommendationsSameSeed(t *testing.T) {
+	first := tiedRecommendations()
+	orderRecommendations(first, 42, "", nil)
+
+	// Reverse the input so only the seed can explain an identical result
+	second := tiedRecommendations()
+	slices.Reverse(second)
+	orderRecommendations(second, 42, "", nil)
+
+	testutil.AssertEqual(t, recommendationCodes(first), recommendationCodes(second), "Same seed should yield the same order")
+	testutil.AssertEqual(t, "EARLY-001", first[0].Code, "Earlier sessions should still come first")
+}
+
+func TestOrderRecommendationsDifferentSeeds(t *testing.T) {
+	first := tiedRecommendations()
+	orderRecommendations(first, 1, "", nil)
+
+	second := tiedRecommendations()
+	orderRecommendations(second, 2, "", nil)
+
+	testutil.AssertEqual(t, true, recommendationCodes(first) != recommendationCodes(second), "Different seeds should shuffle ties differently")
+	testutil.AssertEqual(t, "EARLY-001", second[0].Code, "Shuffle should stay within equal-ranked groups")
+}
+
+func TestGetRecommendationsUsesSeed(t *testing.T) {
+	var day []Session
+	for i, room := range []string{"AU", "TR211", "TR212", "TR213", "TR214", "RB-101"} {
+		day = append(day, Session{Code: fmt.Sprintf("SEED-%03d", i), Start: "10:00", End: "10:30", Room: room, Day: "Aug.9"})
+	}
+	setTestSessions(t, map[string][]Session{"Aug.9": day})
+
+	originalSeed := recommendationSeed
+	recommendationSeed = func(string) uint64 { return 7 }
+	t.Cleanup(func() { recommendationSeed = originalSeed })
+
+	state := &UserState{SessionID: "test_recommendation_seed", Day: "Aug.9", LastEndTime: "09:00"}
+	storeTestUserState(t, state)
+
+	first, err := GetRecommendations(state.SessionID)
+	testutil.AssertNoError(t, err, "GetRecommendations should succeed")
+	second, err := GetRecommendations(state.SessionID)
+	testutil.AssertNoError(t, err, "GetRecommendations should succeed")
+
+	expected := getSimplifiedSessions(day)
+	orderRecommendations(expected, 7, "", nil)
+
+	testutil.AssertEqual(t, recommendationCodes(expected), recommendationCodes(first), "Recommendations should follow the overridden seed")
+	testutil.AssertEqual(t, recommendationCodes(first), recommendationCodes(second), "Repeated calls should be stable")
+}
+
+func TestOrderRecommendationsPrefersCurrentBuildingOnTies(t *testing.T) {
+	sessions := []Session{
+		{Code: "TIEB-TR", Start: "11:00", End: "11:30", Room: "TR211"},
+		{Code: "TIEB-AU", Start: "11:00", End: "11:30", Room: "AU"},
+		{Code: "TIEB-RB", Start: "11:00", End: "11:30", Room: "RB105"},
+	}
+
+	for seed := range uint64(20) {
+		ordered := slices.Clone(sessions)
+		orderRecommendations(ordered, seed, BuildingAU, nil)
+		testutil.AssertEqual(t, "TIEB-AU", ordered[0].Code, fmt.Sprintf("Seed %d: same-building session should win the tie", seed))
+	}
+
+	// Score and start time still come first
+	ordered := slices.Clone(sessions)
+	orderRecommendations(ordered, 1, BuildingAU, func(session Session) int {
+		if session.Code == "TIEB-TR" {
+			return ProfileMatchScore
+		}
+		return 0
+	})
+	testutil.AssertEqual(t, "TIEB-TR", ordered[0].Code, "A higher score should beat the building tie-break")
+}
+
+func TestGetRecommendationsRanksProfileMatchesFirst(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "RANK-DB", Track: "Database", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+			{Code: "RANK-AI", Track: "AI", Start: "11:30", End: "12:00", Room: "TR211", Day: "Aug.9"},
+			{Code: "RANK-TAG", Track: "Misc", Tags: []string{"🧠 AI"}, Start: "11:30", End: "12:00", Room: "TR212", Day: "Aug.9"},
+			{Code: "RANK-OPS", Track: "DevOps", Start: "11:15", End: "11:45", Room: "RB-105", Day: "Aug.9"},
+		},
+	})
+	state := &UserState{SessionID: "test_rank_profile", Day: "Aug.9", Profile: []string{"AI"}, LastEndTime: "10:30"}
+	storeTestUserState(t, state)
+
+	recommendations, err := GetRecommendations(state.SessionID)
+	testutil.AssertNoError(t, err, "GetRecommendations should succeed")
+	testutil.AssertEqual(t, "RANK-AI,RANK-TAG,RANK-DB,RANK-OPS", recommendationCodes(recommendations), "Track matches first, then tag matches, then the rest by start time")
+
+	recommended, scores := RecommendedCodes(recommendations, state)
+	testutil.AssertSliceEqual(t, []string{"RANK-AI", "RANK-TAG"}, recommended, "Only matching sessions should be marked")
+	testutil.AssertEqual(t, ProfileMatchScore, scores["RANK-AI"], "Track match score")
+	testutil.AssertEqual(t, ProfileTagScore, scores["RANK-TAG"], "Tag match score")
+	testutil.AssertEqual(t, 0, scores["RANK-DB"], "Unrelated sessions score zero")
+}
+
+func TestGetRecommendationsUsesLastScheduledBuilding(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LASTB-PREV", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "LASTB-AU", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+			{Code: "LASTB-TR", Start: "11:00", End: "11:30", Room: "TR212", Day: "Aug.9"},
+		},
+	})
+	state := &UserState{
+		SessionID:   "test_last_building_tie",
+		Day:         "Aug.9",
+		Schedule:    []Session{{Code: "LASTB-PREV", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"}},
+		LastEndTime: "10:30",
+	}
+	storeTestUserState(t, state)
+
+	recommendations, err := GetRecommendations(state.SessionID)
+	testutil.AssertNoError(t, err, "GetRecommendations should succeed")
+	testutil.AssertEqual(t, "LASTB-TR,LASTB-AU", recommendationCodes(recommendations), "Staying in TR should be listed first")
+
+	PreferSameBuildingOnTies = false
+	t.Cleanup(func() { PreferSameBuildingOnTies = true })
+	for seed := range uint64(20) {
+		originalSeed := recommendationSeed
+		recommendationSeed = func(string) uint64 { return seed }
+		recommendations, _ = GetRecommendations(state.SessionID)
+		recommendationSeed = originalSeed
+		if recommendations[0].Code == "LASTB-AU" {
+			return
+		}
+	}
+	t.Error("With the tie-break disabled, some seed should list AU first")
+}
+
+func TestGetRecommendationsWithReasonWhenEmpty(t *testing.T) {
+	scheduled := Session{Code: "EMPTY-001", Start: "10:00", End: "11:00", Room: "AU", Day: "Aug.9"}
+	lateNight := Session{Code: "EMPTY-004", Title: "Late Night Meetup", Start: "22:00", End: "01:00", Room: "TR211", Day: "Aug.9"}
+
+	tests := []struct {
+		name        string
+		day         []Session
+		schedule    []Session
+		lastEndTime string // Defaults to the latest end in schedule
+		expected    string
+	}{
+		{
+			name:     "nothing starts later",
+			day:      []Session{scheduled},
+			schedule: []Session{scheduled},
+			expected: EmptyReasonNoLaterSessions,
+		},
+		{
+			name: "sessions overlapping the schedule are not later sessions",
+			day: []Session{
+				scheduled,
+				{Code: "EMPTY-002", Start: "10:30", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			},
+			schedule: []Session{scheduled},
+			expected: EmptyReasonNoLaterSessions,
+		},
+		{
+			name: "sessions during a late-night session are not later sessions",
+			day: []Session{
+				lateNight,
+				{Code: "EMPTY-005", Start: "23:00", End: "23:30", Room: "AU", Day: "Aug.9"},
+			},
+			schedule: []Session{lateNight},
+			expected: EmptyReasonNoLaterSessions,
+		},
+		{
+			name: "later sessions all conflict",
+			day: []Session{
+				scheduled,
+				{Code: "EMPTY-006", Start: "10:30", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			},
+			schedule:    []Session{scheduled},
+			lastEndTime: "09:00",
+			expected:    EmptyReasonAllConflict,
+		},
+		{
+			name: "only social activities remain",
+			day: []Session{
+				scheduled,
+				{Code: "EMPTY-003", Title: "Hacking Corner", Start: "11:00", End: "17:00", Room: "Hallway outside TR309", Day: "Aug.9"},
+			},
+			schedule: []Session{scheduled},
+			expected: EmptyReasonOnlySocial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestSessions(t, map[string][]Session{"Aug.9": tt.day})
+			lastEndTime := tt.lastEndTime
+			if lastEndTime == "" {
+				lastEndTime = latestEndTime(tt.schedule)
+			}
+			state := &UserState{
+				SessionID:   "test_empty_reason",
+				Day:         "Aug.9",
+				Schedule:    tt.schedule,
+				LastEndTime: lastEndTime,
+			}
+			storeTestUserState(t, state)
+
+			recommendations, reason, err := GetRecommendationsWithReason(state.SessionID)
+			testutil.AssertNoError(t, err, "GetRecommendationsWithReason should succeed")
+			testutil.AssertEqual(t, 0, len(recommendations), "There should be no recommendations")
+			testutil.AssertEqual(t, tt.expected, reason, "Empty reason")
+		})
+	}
+}
+
+// Arrival advice tests
+
+func TestEarliestArrivalAdvice(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_arrival_advice",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "ARR-002", Title: "Later", Start: "11:00", End: "11:30", Room: "AU"},
+			{Code: "ARR-001", Title: "First", Start: "09:30", End: "10:00", Room: "TR211"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	session, buffer := EarliestArrivalAdvice(state.SessionID)
+	testutil.AssertNotNil(t, session, "Should return the first session")
+	testutil.AssertEqual(t, "ARR-001", session.Code, "Should pick the earliest session regardless of schedule order")
+	testutil.AssertEqual(t, ArrivalBaseBuffer+TREntryTime, buffer, "Buffer should add TR entry time to the base buffer")
+	testutil.AssertEqual(t, true, buffer >= 15 && buffer <= 30, "Buffer should be sensible")
+	testutil.AssertEqual(t, "ARR-002", state.Schedule[0].Code, "Stored schedule should not be reordered")
+}
+
+func TestEarliestArrivalAdviceEmptySchedule(t *testing.T) {
+	state := &UserState{SessionID: "test_arrival_advice_empty", Day: "Aug.9"}
+	storeTestUserState(t, state)
+
+	session, buffer := EarliestArrivalAdvice(state.SessionID)
+	testutil.AssertEqual(t, true, session == nil, "Empty schedule should return no session")
+	testutil.AssertEqual(t, 0, buffer, "Empty schedule should return no buffer")
+
+	session, _ = EarliestArrivalAdvice("nonexistent_session")
+	testutil.AssertEqual(t, true, session == nil, "Unknown session should return no session")
+}
+
+// Schedule statistics tests
+
+func TestGetScheduleStatistics(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_schedule_statistics",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "STAT-003", Start: "13:00", End: "13:30", Room: "AU", Track: "Main Track"},
+			{Code: "STAT-001", Start: "10:00", End: "10:30", Room: "AU", Track: "Main Track"},
+			{Code: "STAT-002", Start: "10:40", End: "11:20", Room: "TR211", Track: "Rust"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	stats, err := GetScheduleStatistics(state.SessionID)
+	testutil.AssertNoError(t, err, "GetScheduleStatistics should succeed")
+
+	testutil.AssertEqual(t, 3, stats["session_count"], "Session count")
+	testutil.AssertEqual(t, 2, stats["tracks_covered"], "Two distinct tracks")
+	testutil.AssertEqual(t, 2, stats["track_coverage"].(map[string]int)["Main Track"], "Main Track count")
+	testutil.AssertEqual(t, 100, stats["total_talk_minutes"], "30 + 40 + 30 minutes of talks")
+	testutil.AssertEqual(t, 100, stats["longest_gap_minutes"], "Gap from 11:20 to 13:00")
+	testutil.AssertEqual(t, AUToTRWalkTime+TRToAUWalkTime, stats["total_walking_minutes"], "AU -> TR -> AU walking time")
+
+	testutil.AssertNoError(t, SetMobility(state.SessionID, MobilitySlow), "SetMobility should succeed")
+	stats, err = GetScheduleStatistics(state.SessionID)
+	testutil.AssertNoError(t, err, "GetScheduleStatistics should succeed")
+	testutil.AssertEqual(t, scaleWalkingTime(AUToTRWalkTime, MobilitySlow)+scaleWalkingTime(TRToAUWalkTime, MobilitySlow), stats["total_walking_minutes"],
+		"Walking time should be scaled to the user's mobility")
+
+	_, err = GetScheduleStatistics("nonexistent_session")
+	testutil.AssertError(t, err, "Unknown session should return an error")
+}
+
+func TestIsPlanningStillUseful(t *testing.T) {
+	testutil.AssertEqual(t, true, isPlanningStillUseful("14:00"), "Mid-afternoon should allow more planning")
+	testutil.AssertEqual(t, true, isPlanningStillUseful("16:30"), "A 30-minute session still fits before 17:00")
+	testutil.AssertEqual(t, false, isPlanningStillUseful("16:31"), "Nothing fits after 16:30")
+	testutil.AssertEqual(t, false, isPlanningStillUseful("17:45"), "Late evening should not nudge")
+}
+
+// Outside-COSCUP day handling tests
+
+func TestGetQueryDay(t *testing.T) {
+	taipei := conferenceLocation()
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected string
+	}{
+		{"Day 1", time.Date(2025, 8, 9, 10, 0, 0, 0, taipei), DayAug9},
+		{"Day 2", time.Date(2025, 8, 10, 10, 0, 0, 0, taipei), DayAug10},
+		{"Before conference", time.Date(2025, 8, 8, 10, 0, 0, 0, taipei), DayAug9},
+		{"After conference", time.Date(2026, 1, 1, 10, 0, 0, 0, taipei), DayAug10},
+		{"Evening after day 2", time.Date(2025, 8, 11, 0, 30, 0, 0, taipei), DayAug10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := getQueryDay(tt.time)
+			testutil.AssertEqual(t, tt.expected, day, "getQueryDay result")
+			testutil.AssertEqual(t, true, IsValidDay(day), "Query day should always be valid")
+			testutil.AssertEqual(t, true, len(sessionData().byDay[convertDayFormat(day)]) > 0, "Query day should have sessions")
+		})
+	}
+}
+
+func TestGetQueryDayConfigurableOutsideDefaults(t *testing.T) {
+	original := OutsideCOSCUPDefaultDays
+	t.Cleanup(func() { OutsideCOSCUPDefaultDays = original })
+
+	taipei := conferenceLocation()
+	before := time.Date(2025, 8, 1, 10, 0, 0, 0, taipei)
+	after := time.Date(2025, 9, 1, 10, 0, 0, 0, taipei)
+
+	OutsideCOSCUPDefaultDays = map[string]string{ConferencePhaseBefore: DayAug10, ConferencePhaseAfter: DayAug9}
+	testutil.AssertEqual(t, DayAug10, getQueryDay(before), "Before-event default should be configurable")
+	testutil.AssertEqual(t, DayAug9, getQueryDay(after), "After-event default should be configurable")
+
+	OutsideCOSCUPDefaultDays = map[string]string{ConferencePhaseAfter: "Aug11"}
+	testutil.AssertEqual(t, DefaultOutsideCOSCUPDay, getQueryDay(before), "Missing entries should fall back to the default day")
+	testutil.AssertEqual(t, DefaultOutsideCOSCUPDay, getQueryDay(after), "Invalid entries should fall back to the default day")
+}
+
+func TestOutsideCOSCUPPeriodResponsePhases(t *testing.T) {
+	taipei := conferenceLocation()
+
+	t.Run("Before", func(t *testing.T) {
+		response := buildOutsideCOSCUPPeriodResponse(time.Date(2025, 8, 6, 22, 0, 0, 0, taipei))
+		testutil.AssertEqual(t, ConferencePhaseBefore, response["phase"], "Phase")
+		testutil.AssertEqual(t, 3, response["days_until_start"], "Countdown counts calendar days")
+		testutil.AssertEqual(t, true, strings.Contains(response["message"].(string), "還有 3 天"), "Message should include the countdown")
+	})
+
+	t.Run("During", func(t *testing.T) {
+		response := buildOutsideCOSCUPPeriodResponse(time.Date(2025, 8, 10, 23, 0, 0, 0, taipei))
+		testutil.AssertEqual(t, ConferencePhaseDuring, response["phase"], "Phase")
+		testutil.AssertEqual(t, nil, response["resources"], "No post-event resources during the event")
+		testutil.AssertEqual(t, true, strings.Contains(response["message"].(string), "get_schedule"), "Message should keep the generic guidance")
+	})
+
+	t.Run("After", func(t *testing.T) {
+		response := buildOutsideCOSCUPPeriodResponse(time.Date(2025, 8, 11, 0, 0, 0, 0, taipei))
+		testutil.AssertEqual(t, ConferencePhaseAfter, response["phase"], "Phase")
+		testutil.AssertEqual(t, len(PostEventResources), len(response["resources"].([]PostEventResource)), "Resources should be listed")
+		testutil.AssertEqual(t, true, strings.Contains(response["message"].(string), PostEventResources[0].URL), "Message should link the recordings")
+	})
+}
+
+// Balance score tests
+
+func TestScheduleBalanceScore(t *testing.T) {
+	scattered := &UserState{
+		SessionID: "test_balance_scattered",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "BAL-S1", Start: "10:00", End: "10:30", Room: "AU", Track: "Main Track"},
+			{Code: "BAL-S2", Start: "10:30", End: "11:00", Room: "TR211", Track: "Rust"},
+			{Code: "BAL-S3", Start: "11:00", End: "11:30", Room: "RB-105", Track: "Security"},
+			{Code: "BAL-S4", Start: "11:30", End: "12:00", Room: "TR409", Track: "Blockchain"},
+			{Code: "BAL-S5", Start: "14:00", End: "14:30", Room: "AU", Track: "Open Data"},
+		},
+	}
+	compact := &UserState{
+		SessionID: "test_balance_compact",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "BAL-C1", Start: "10:00", End: "10:30", Room: "TR211", Track: "Rust"},
+			{Code: "BAL-C2", Start: "10:40", End: "11:10", Room: "TR211", Track: "Rust"},
+			{Code: "BAL-C3", Start: "11:20", End: "11:50", Room: "TR211", Track: "Rust"},
+			{Code: "BAL-C4", Start: "12:00", End: "12:30", Room: "TR211", Track: "Rust"},
+		},
+	}
+	storeTestUserState(t, scattered)
+	storeTestUserState(t, compact)
+
+	scatteredScore, scatteredParts := ScheduleBalanceScore(scattered.SessionID)
+	compactScore, compactParts := ScheduleBalanceScore(compact.SessionID)
+
+	testutil.AssertEqual(t, 100, scatteredParts["variety"], "Every scattered session is from a different track")
+	testutil.AssertEqual(t, 25, compactParts["variety"], "Single-track plan has low variety")
+	testutil.AssertEqual(t, true, scatteredParts["walking"] < compactParts["walking"], "Multi-building plan should walk more")
+	testutil.AssertEqual(t, 100, compactParts["walking"], "Same-room plan needs no walking")
+	testutil.AssertEqual(t, 0, scatteredParts["breaks"], "Scattered plan has only tight transfers and a long gap")
+	testutil.AssertEqual(t, 100, compactParts["breaks"], "Compact plan has comfortable breaks")
+	testutil.AssertEqual(t, true, compactScore > scatteredScore, "Compact plan should score higher overall")
+
+	for _, score := range []int{scatteredScore, compactScore} {
+		testutil.AssertEqual(t, true, score >= 0 && score <= 100, "Score should be within 0-100")
+	}
+}
+
+func TestScheduleBalanceScoreEmpty(t *testing.T) {
+	state := &UserState{SessionID: "test_balance_empty", Day: "Aug.9"}
+	storeTestUserState(t, state)
+
+	score, components := ScheduleBalanceScore(state.SessionID)
+	testutil.AssertEqual(t, 0, score, "Empty schedule scores zero")
+	testutil.AssertEqual(t, true, components == nil, "Empty schedule has no breakdown")
+}
+
+// Locked sessions and optimizer tests
+
+func optimizerTestSessions() map[string][]Session {
+	return map[string][]Session{
+		"Aug.9": {
+			{Code: "OPT-A1", Start: "10:00", End: "10:30", Room: "AU", Track: "Main Track", Day: "Aug.9"},
+			{Code: "OPT-T1", Start: "10:00", End: "10:30", Room: "TR211", Track: "Rust", Day: "Aug.9"},
+			{Code: "OPT-A2", Start: "10:30", End: "11:00", Room: "AU", Track: "Main Track", Day: "Aug.9"},
+			{Code: "OPT-T2", Start: "10:30", End: "11:00", Room: "TR211", Track: "Rust", Day: "Aug.9"},
+			{Code: "OPT-A3", Start: "11:00", End: "11:30", Room: "AU", Track: "Main Track", Day: "Aug.9"},
+			{Code: "OPT-T3", Start: "11:00", End: "11:30", Room: "TR211", Track: "Rust", Day: "Aug.9"},
+		},
+	}
+}
+
+func TestOptimizeScheduleKeepsLockedSessions(t *testing.T) {
+	byDay := optimizerTestSessions()
+	setTestSessions(t, byDay)
+
+	// User likes Rust but must attend the Main Track session at 10:30
+	state := &UserState{
+		SessionID: "test_optimize_locked",
+		Day:       "Aug.9",
+		Schedule:  []Session{byDay["Aug.9"][0], byDay["Aug.9"][2]},
+		Profile:   []string{"Rust"},
+	}
+	storeTestUserState(t, state)
+
+	testutil.AssertNoError(t, LockSession(state.SessionID, "OPT-A2", true), "Locking a scheduled session should succeed")
+
+	plan, err := OptimizeSchedule(state.SessionID)
+	testutil.AssertNoError(t, err, "OptimizeSchedule should succeed")
+
+	codes := recommendationCodes(plan)
+	testutil.AssertEqual(t, "OPT-T1,OPT-A2,OPT-T3", codes, "Locked session stays while unlocked slots follow the profile")
+	testutil.AssertEqual(t, 2, len(state.Schedule), "Optimizing should not modify the stored schedule")
+
+	// Without the lock the optimizer is free to replace it
+	testutil.AssertNoError(t, LockSession(state.SessionID, "OPT-A2", false), "Unlocking should succeed")
+	plan, err = OptimizeSchedule(state.SessionID)
+	testutil.AssertNoError(t, err, "OptimizeSchedule should succeed")
+	testutil.AssertEqual(t, "OPT-T1,OPT-T2,OPT-T3", recommendationCodes(plan), "Unlocked session may be replaced")
+}
+
+func TestLockSessionRequiresScheduledSession(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_lock_unscheduled",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "LOCK-001", Start: "10:00", End: "10:30", Room: "AU"}},
+	}
+	storeTestUserState(t, state)
+
+	testutil.AssertError(t, LockSession(state.SessionID, "LOCK-999", true), "Locking an unscheduled session should fail")
+	testutil.AssertError(t, LockSession("nonexistent_session", "LOCK-001", true), "Unknown user session should fail")
+	testutil.AssertNoError(t, LockSession(state.SessionID, "LOCK-001", true), "Locking a scheduled session should succeed")
+	testutil.AssertEqual(t, true, state.Locked["LOCK-001"], "Session should be marked locked")
+
+	snapshot := GetUserStateSnapshot(state.SessionID)
+	snapshot.Locked["LOCK-002"] = true
+	testutil.AssertEqual(t, false, state.Locked["LOCK-002"], "Snapshot locks should not alias the stored state")
+}
+
+// Livestream tests
+
+func TestGetLivestreamedSessions(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LIVE-001", Title: "Streamed", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", StreamURL: "https://example.org/live/au"},
+			{Code: "LIVE-002", Title: "Not Streamed", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "LIVE-003", Title: "Streamed Later", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9", StreamURL: "https://example.org/live/au"},
+		},
+	})
+
+	sessions := GetLivestreamedSessions("Aug.9", "10:15")
+	testutil.AssertEqual(t, 1, len(sessions), "Only the running streamed session should be listed")
+	testutil.AssertEqual(t, "LIVE-001", sessions[0].Code, "Streamed session should be listed")
+	testutil.AssertEqual(t, "https://example.org/live/au", sessions[0].StreamURL, "Stream URL should be exposed")
+
+	testutil.AssertEqual(t, 0, len(GetLivestreamedSessions("Aug.9", "12:00")), "Nothing is streamed after sessions end")
+}
+
+// Backup suggestion tests
+
+func TestSuggestBackup(t *testing.T) {
+	byDay := map[string][]Session{
+		"Aug.9": {
+			{Code: "BAK-001", Start: "10:00", End: "10:30", Room: "TR211", Track: "Rust", Tags: []string{"rust", "systems"}, Day: "Aug.9"},
+			{Code: "BAK-002", Start: "11:00", End: "11:30", Room: "AU", Track: "Main Track", Tags: []string{"keynote"}, Day: "Aug.9"},
+			{Code: "BAK-ALT-FAR", Start: "10:00", End: "10:30", Room: "AU", Track: "Rust", Tags: []string{"rust", "systems"}, Day: "Aug.9"},
+			{Code: "BAK-ALT-NEAR", Start: "10:10", End: "10:40", Room: "TR212", Track: "Rust", Tags: []string{"rust", "systems"}, Day: "Aug.9"},
+			{Code: "BAK-ALT-UNRELATED", Start: "10:00", End: "10:30", Room: "TR213", Track: "Design", Tags: []string{"ux"}, Day: "Aug.9"},
+			{Code: "BAK-ALT-CLASH", Start: "10:15", End: "11:15", Room: "TR214", Track: "Main Track", Tags: []string{"keynote"}, Day: "Aug.9"},
+			{Code: "BAK-ALT-MAIN", Start: "11:00", End: "11:30", Room: "RB-105", Track: "Main Track", Tags: []string{"keynote"}, Day: "Aug.9"},
+		},
+	}
+	setTestSessions(t, byDay)
+
+	schedule := []Session{byDay["Aug.9"][0], byDay["Aug.9"][1]}
+
+	for _, scheduled := range schedule {
+		backup := SuggestBackup(scheduled, "Aug.9", schedule, MobilityNormal)
+		testutil.AssertNotNil(t, backup, "Scheduled session "+scheduled.Code+" should get a backup")
+		testutil.AssertEqual(t, true, hasTimeConflict(backup.Start, backup.End, scheduled.Start, scheduled.End),
+			"Backup should overlap the session it replaces")
+
+		for _, other := range schedule {
+			if other.Code != scheduled.Code {
+				testutil.AssertEqual(t, false, hasTimeConflict(backup.Start, backup.End, other.Start, other.End),
+					"Backup should not conflict with the rest of the schedule")
+			}
+		}
+	}
+
+	testutil.AssertEqual(t, "BAK-ALT-NEAR", SuggestBackup(schedule[0], "Aug.9", schedule, MobilityNormal).Code, "Similar tags in the nearest room should win")
+	testutil.AssertEqual(t, "BAK-ALT-MAIN", SuggestBackup(schedule[1], "Aug.9", schedule, MobilityNormal).Code, "Clashing candidates should be skipped")
+}
+
+func TestSuggestBackupNoneAvailable(t *testing.T) {
+	lonely := Session{Code: "BAK-LONELY", Start: "08:00", End: "08:30", Room: "AU", Day: "Aug.9"}
+	setTestSessions(t, map[string][]Session{"Aug.9": {lonely}})
+
+	testutil.AssertEqual(t, true, SuggestBackup(lonely, "Aug.9", []Session{lonely}, MobilityNormal) == nil, "No concurrent session means no backup")
+}
+
+// Long break fill-in tests
+
+func TestLongBreakSuggestsFillIns(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "FILL-NEXT", Title: "Planned", Start: "13:00", End: "13:30", Room: "AU", Day: "Aug.9"},
+			{Code: "FILL-001", Title: "Fits Early", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "FILL-002", Title: "Fits Later", Start: "11:30", End: "12:00", Room: "TR212", Day: "Aug.9"},
+			{Code: "FILL-003", Title: "Fits Last", Start: "12:00", End: "12:30", Room: "TR213", Day: "Aug.9"},
+			{Code: "FILL-004", Title: "Overlaps Next", Start: "12:40", End: "13:10", Room: "TR214", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID: "test_long_break",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "FILL-NEXT", Title: "Planned", Start: "13:00", End: "13:30", Room: "AU", Day: "Aug.9"}},
+	}
+
+	status := analyzeCurrentStatus(state, "10:45")
+	testutil.AssertEqual(t, "break", status.Status, "Should be in a break")
+	testutil.AssertEqual(t, MaxBreakFillInSuggestions, len(status.FillInOptions), "Long break should get capped fill-in suggestions")
+	testutil.AssertEqual(t, "FILL-001", status.FillInOptions[0].Code, "Earliest fitting session first")
+	testutil.AssertEqual(t, "FILL-002", status.FillInOptions[1].Code, "Then the next fitting session")
+
+	result := buildBreakResponse(status)
+	_, hasOptions := result["fill_in_options"]
+	testutil.AssertEqual(t, true, hasOptions, "Break response should include fill-in options")
+	testutil.AssertEqual(t, true, strings.Contains(result["message"].(string), "Fits Early"), "Break message should name a concrete option")
+}
+
+func TestNoonGapGetsLunchStatus(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LUNCH-AM", Title: "Morning", Start: "11:00", End: "11:50", Room: "AU", Day: "Aug.9"},
+			{Code: "LUNCH-PM", Title: "Afternoon", Start: "13:30", End: "14:00", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+	state := &UserState{
+		SessionID: "test_noon_gap",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "LUNCH-AM", Title: "Morning", Start: "11:00", End: "11:50", Room: "AU", Day: "Aug.9"},
+			{Code: "LUNCH-PM", Title: "Afternoon", Start: "13:30", End: "14:00", Room: "TR211", Day: "Aug.9"},
+		},
+	}
+
+	status := analyzeCurrentStatus(state, "12:05")
+	testutil.AssertEqual(t, "lunch_break", status.Status, "A long gap at noon should be a lunch break")
+
+	result := buildLunchResponse(status)
+	message := result["message"].(string)
+	testutil.AssertEqual(t, "lunch_break", result["status"], "Response status")
+	testutil.AssertEqual(t, true, strings.Contains(message, "午餐時間"), "Message should be about lunch")
+	testutil.AssertEqual(t, true, strings.Contains(message, GetVenueInfo().FoodAreas[0]), "Message should name a place to eat")
+	testutil.AssertEqual(t, false, strings.Contains(message, "立即前往") || strings.Contains(message, "現在就開始移動"), "Lunch should not rush the user")
+
+	for _, now := range []string{"11:55", "12:15", "13:05"} {
+		testutil.AssertEqual(t, "lunch_break", analyzeCurrentStatus(state, now).Status, "The whole noon gap should stay a lunch break at "+now)
+	}
+}
+
+func TestShortBreakHasNoFillIns(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "SHORT-NEXT", Title: "Planned", Start: "11:30", End: "12:00", Room: "AU", Day: "Aug.9"},
+			{Code: "SHORT-001", Title: "Would Fit", Start: "11:00", End: "11:20", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID: "test_short_break",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "SHORT-NEXT", Title: "Planned", Start: "11:30", End: "12:00", Room: "AU", Day: "Aug.9"}},
+	}
+
+	status := analyzeCurrentStatus(state, "10:45")
+	testutil.AssertEqual(t, "break", status.Status, "Should be in a break")
+	testutil.AssertEqual(t, 0, len(status.FillInOptions), "Short break should not get suggestions")
+
+	_, hasOptions := buildBreakResponse(status)["fill_in_options"]
+	testutil.AssertEqual(t, false, hasOptions, "Break response should not include fill-in options")
+}
+
+// Lunch break tests
+
+func TestIsLunchBreak(t *testing.T) {
+	tests := []struct {
+		start, end string
+		expected   bool
+	}{
+		{"12:00", "13:30", true},
+		{"11:30", "12:15", true},
+		{"13:00", "14:00", true},
+		{"10:00", "11:00", false},
+		{"13:30", "14:00", false},
+		{"11:00", "12:00", false},
+	}
+
+	for _, tt := range tests {
+		testutil.AssertEqual(t, tt.expected, isLunchBreak(tt.start, tt.end), tt.start+"-"+tt.end)
+	}
+}
+
+func TestTightConnectionFlag(t *testing.T) {
+	current := &Session{Code: "TIGHT-CUR", Title: "Current", Room: "TR209", Start: "10:00", End: "10:30"}
+	next := &Session{Code: "TIGHT-NEXT", Title: "Next", Room: "AU", Start: "10:35", End: "11:00"}
+	tight := calculateRoute(current, next, 5)
+	relaxed := calculateRoute(current, next, 20)
+
+	for name, build := range map[string]func(*SessionStatus) map[string]any{
+		"ongoing":    buildOngoingResponse,
+		"break":      buildBreakResponse,
+		"just_ended": buildJustEndedResponse,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := build(&SessionStatus{CurrentSession: current, NextSession: next, RemainingMinutes: 20, BreakMinutes: 5, Route: tight})
+			testutil.AssertEqual(t, true, data["tight_connection"], "An infeasible route should be flagged")
+
+			data = build(&SessionStatus{CurrentSession: current, NextSession: next, RemainingMinutes: 20, BreakMinutes: 20, Route: relaxed})
+			_, flagged := data["tight_connection"]
+			testutil.AssertEqual(t, false, flagged, "A feasible route should not be flagged")
+		})
+	}
+}
+
+func TestBreakResponseLunchMessage(t *testing.T) {
+	nextSession := &Session{Code: "LUNCH-NEXT", Title: "Afternoon", Start: "13:30", End: "14:00", Room: "AU"}
+
+	lunch := buildBreakResponse(&SessionStatus{Status: "break", NextSession: nextSession, BreakMinutes: 90, Route: calculateRoute(nil, nextSession, 90)})
+	testutil.AssertEqual(t, true, lunch["is_lunch"], "12:00-13:30 break should be a lunch break")
+	testutil.AssertEqual(t, true, strings.Contains(lunch["message"].(string), "午餐時間"), "Lunch break should get the lunch message")
+	testutil.AssertEqual(t, false, strings.Contains(lunch["message"].(string), "空檔時間"), "Lunch break should not use the generic message")
+
+	morning := &Session{Code: "MORNING-NEXT", Title: "Morning", Start: "10:30", End: "11:00", Room: "AU"}
+	regular := buildBreakResponse(&SessionStatus{Status: "break", NextSession: morning, BreakMinutes: 20, Route: calculateRoute(nil, morning, 20)})
+	testutil.AssertEqual(t, false, regular["is_lunch"], "Morning break should not be a lunch break")
+	testutil.AssertEqual(t, true, strings.Contains(regular["message"].(string), "空檔時間"), "Regular break should keep the generic message")
+}
+
+func TestTimelineLabelsLunchGap(t *testing.T) {
+	state := &UserState{
+		Day: "Aug.9",
+		Schedule: []Session{
+			{Code: "TL-001", Title: "Morning", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "TL-002", Title: "Late Morning", Start: "11:00", End: "12:00", Room: "AU"},
+			{Code: "TL-003", Title: "Afternoon", Start: "13:30", End: "14:00", Room: "AU"},
+		},
+	}
+
+	view := generateTimelineView(state)
+	testutil.AssertEqual(t, true, strings.Contains(view, "12:00-13:30 | 🍱 午餐時間"), "Gap over lunch should be labeled lunch")
+	testutil.AssertEqual(t, true, strings.Contains(view, "10:30-11:00 | 🆓 空檔時間"), "Morning gap should stay a free slot")
+}
+
+// Surprise mode tests
+
+func TestOrderBySurprisePrefersOffProfileTags(t *testing.T) {
+	state := &UserState{
+		Day:      "Aug.9",
+		Profile:  []string{"Rust"},
+		Schedule: []Session{{Code: "SUR-PICKED", Track: "Rust", Tags: []string{"rust", "systems"}}},
+	}
+
+	sessions := []Session{
+		{Code: "SUR-MATCH", Track: "Rust", Tags: []string{"rust", "systems"}},
+		{Code: "SUR-PARTIAL", Track: "Kernel", Tags: []string{"systems", "linux"}},
+		{Code: "SUR-NEW", Track: "Design", Tags: []string{"ux", "accessibility"}},
+		{Code: "SUR-UNTAGGED", Track: "Community"},
+	}
+
+	orderBySurprise(sessions, state)
+
+	testutil.AssertEqual(t, "SUR-NEW,SUR-PARTIAL,SUR-UNTAGGED,SUR-MATCH", recommendationCodes(sessions),
+		"Off-profile sessions should come first and profile matches last")
+}
+
+func TestOrderBySurpriseKeepsTieOrder(t *testing.T) {
+	state := &UserState{Day: "Aug.9"}
+	sessions := []Session{
+		{Code: "TIE-B", Tags: []string{"a"}},
+		{Code: "TIE-A", Tags: []string{"b"}},
+	}
+
+	orderBySurprise(sessions, state)
+	testutil.AssertEqual(t, "TIE-B,TIE-A", recommendationCodes(sessions), "Equal scores should keep the existing order")
+}
+
+// Next session countdown tests
+
+func TestMinutesUntil(t *testing.T) {
+	testutil.AssertEqual(t, 25, minutesUntil("10:05", "10:30"), "Countdown should be start minus now")
+	testutil.AssertEqual(t, 0, minutesUntil("10:30", "10:30"), "Countdown should be zero at start")
+	testutil.AssertEqual(t, 0, minutesUntil("10:45", "10:30"), "Countdown should be clamped once started")
+}
+
+func TestNextSessionCountdownInResponses(t *testing.T) {
+	state := &UserState{
+		Day: "Aug.9",
+		Schedule: []Session{
+			{Code: "CD-001", Title: "First", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "CD-002", Title: "Second", Start: "10:50", End: "11:20", Room: "TR211"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		currentTime string
+		status      string
+		expected    int
+	}{
+		{"Ongoing", "10:10", "ongoing", 40},
+		{"Just ended", "10:35", "just_ended", 15},
+		{"Break", "09:40", "break", 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := analyzeCurrentStatus(state, tt.currentTime)
+			testutil.AssertEqual(t, tt.status, status.Status, "Status should match")
+
+			var data map[string]any
+			switch status.Status {
+			case "ongoing":
+				data = buildOngoingResponse(status)
+			case "just_ended":
+				data = buildJustEndedResponse(status)
+			default:
+				data = buildBreakResponse(status)
+			}
+
+			testutil.AssertEqual(t, tt.expected, data["minutes_until_next_start"], "Countdown should match start minus now")
+		})
+	}
+}
+
+// Profile decay tests
+
+func TestScoreSessionRecencyWeighting(t *testing.T) {
+	base := time.Date(2025, 8, 9, 9, 0, 0, 0, conferenceLocation())
+	state := &UserState{
+		Day:     "Aug.9",
+		Profile: []string{"Old Track", "New Track"},
+		ProfileAddedAt: map[string]time.Time{
+			"Old Track": base,
+			"New Track": base.Add(2 * time.Hour),
+		},
+	}
+	oldSession := Session{Code: "DECAY-OLD", Track: "Old Track", Start: "13:00"}
+	newSession := Session{Code: "DECAY-NEW", Track: "New Track", Start: "13:00"}
+	offProfile := Session{Code: "DECAY-OFF", Track: "Other", Start: "13:00"}
+
+	t.Run("Flat weight by default", func(t *testing.T) {
+		testutil.AssertEqual(t, ProfileMatchScore, scoreSession(oldSession, state), "Old track should get the full score")
+		testutil.AssertEqual(t, ProfileMatchScore, scoreSession(newSession, state), "New track should get the full score")
+		testutil.AssertEqual(t, 0, scoreSession(offProfile, state), "Off-profile session should score zero")
+	})
+
+	t.Run("Recency weighting", func(t *testing.T) {
+		SetProfileDecayHalfLife(time.Hour)
+		t.Cleanup(func() { SetProfileDecayHalfLife(0) })
+
+		testutil.AssertEqual(t, ProfileMatchScore, scoreSession(newSession, state), "Most recent track should get the full score")
+		testutil.AssertEqual(t, ProfileMatchScore/4, scoreSession(oldSession, state), "Track picked two half-lives earlier should score a quarter")
+
+		sessions := []Session{oldSession, offProfile, newSession}
+		orderRecommendations(sessions, 1, "", func(session Session) int { return scoreSession(session, state) })
+		testutil.AssertEqual(t, "DECAY-NEW,DECAY-OLD,DECAY-OFF", recommendationCodes(sessions), "Recent track should outrank the old one")
+	})
+
+	t.Run("Non-positive half-life disables decay", func(t *testing.T) {
+		SetProfileDecayHalfLife(-time.Hour)
+		testutil.AssertEqual(t, ProfileMatchScore, scoreSession(oldSession, state), "Old track should get the full score again")
+	})
+}
+
+func TestScoreSessionBoostsFollowedSpeaker(t *testing.T) {
+	state := &UserState{
+		Day:     "Aug.9",
+		Profile: []string{"Rust"},
+		Schedule: []Session{
+			{Code: "FOLLOW-001", Track: "Rust", Speakers: []string{"Alice"}, Start: "10:00", End: "10:30"},
+		},
+	}
+	followedTalk := Session{Code: "FOLLOW-002", Track: "Rust", Speakers: []string{"Bob", "Alice"}, Start: "13:00"}
+	unrelated := Session{Code: "FOLLOW-003", Track: "Rust", Speakers: []string{"Carol"}, Start: "13:00"}
+
+	testutil.AssertEqual(t, true, FollowedSpeakers(state)["Alice"], "Scheduled speaker should be followed")
+	testutil.AssertEqual(t, ProfileMatchScore+SpeakerFollowScore, scoreSession(followedTalk, state), "Followed speaker should add a bonus")
+	testutil.AssertEqual(t, ProfileMatchScore, scoreSession(unrelated, state), "Unrelated speaker should get only the track score")
+
+	for seed := uint64(0); seed < 5; seed++ {
+		sessions := []Session{unrelated, followedTalk}
+		orderRecommendations(sessions, seed, "", func(session Session) int { return scoreSession(session, state) })
+		testutil.AssertEqual(t, "FOLLOW-002,FOLLOW-003", recommendationCodes(sessions), "Followed speaker's talk should rank first")
+	}
+}
+
+func TestAddToProfileRecordsPickTime(t *testing.T) {
+	state := &UserState{Day: "Aug.9"}
+
+	addToProfile(state, "Rust")
+	firstPick := state.ProfileAddedAt["Rust"]
+	testutil.AssertEqual(t, false, firstPick.IsZero(), "Pick time should be recorded")
+
+	time.Sleep(time.Millisecond)
+	addToProfile(state, "Rust")
+	testutil.AssertEqual(t, 1, len(state.Profile), "Track should not be duplicated")
+	testutil.AssertEqual(t, true, state.ProfileAddedAt["Rust"].After(firstPick), "Re-picking a track should refresh its time")
+}
+
+// Rooms by building tests
+
+func TestGetRoomsByBuilding(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "RBB-001", Start: "10:00", End: "10:30", Room: "TR212", Day: "Aug.9"},
+			{Code: "RBB-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "RBB-003", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "RBB-004", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "RBB-005", Start: "10:00", End: "10:30", Room: "RB-105", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "RBB-006", Start: "10:00", End: "10:30", Room: "TR409", Day: "Aug.10"},
+		},
+	})
+
+	rooms := GetRoomsByBuilding("Aug.9")
+
+	testutil.AssertEqual(t, 3, len(rooms), "Three buildings have sessions")
+	testutil.AssertEqual(t, "AU", strings.Join(rooms[BuildingAU], ","), "AU rooms")
+	testutil.AssertEqual(t, "RB-105", strings.Join(rooms[BuildingRB], ","), "RB rooms")
+	testutil.AssertEqual(t, "TR211,TR212", strings.Join(rooms[BuildingTR], ","), "TR rooms should be sorted and deduplicated")
+
+	testutil.AssertEqual(t, 0, len(GetRoomsByBuilding("Aug.11")), "Unknown day has no rooms")
+}
+
+func TestGetAllRoomsWithBuildings(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "ALLR-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "ALLR-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "ALLR-003", Start: "10:00", End: "10:30", Room: "RB-105", Day: "Aug.10"},
+			{Code: "ALLR-004", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.10"},
+		},
+	})
+
+	rooms := GetAllRoomsWithBuildings()
+
+	testutil.AssertEqual(t, 3, len(rooms), "Rooms from both days should be included once")
+	testutil.AssertEqual(t, "視聽館", rooms["AU"], "AU building name")
+	testutil.AssertEqual(t, "研揚大樓", rooms["TR211"], "TR building name")
+	testutil.AssertEqual(t, "綜合研究大樓", rooms["RB-105"], "Aug.10 room should use RB building name")
+}
+
+func TestOtherDayRoomHint(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "HINT-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "HINT-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.10"},
+			{Code: "HINT-003", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.10"},
+		},
+	})
+
+	testutil.AssertEqual(t, "TR211 has no sessions on Aug9 but 2 on Aug10", otherDayRoomHint("TR211", "Aug.9"), "Hint should point to the other day")
+	testutil.AssertEqual(t, "AU has no sessions on Aug10 but 1 on Aug9", otherDayRoomHint("AU", "Aug.10"), "Hint should work in both directions")
+	testutil.AssertEqual(t, "", otherDayRoomHint("TR999", "Aug.9"), "Room unused on both days has no hint")
+}
+
+func TestGetVenueInfo(t *testing.T) {
+	info := GetVenueInfo()
+
+	testutil.AssertEqual(t, "https://coscup.org/2025/venue/", info.MapURL, "Map URL should be kept")
+	testutil.AssertEqual(t, 3, len(info.Buildings), "Venue should describe three buildings")
+	for _, code := range []string{BuildingAU, BuildingRB, BuildingTR} {
+		index := slices.IndexFunc(info.Buildings, func(b VenueBuilding) bool { return b.Code == code })
+		testutil.AssertEqual(t, true, index >= 0, code+" should be described")
+		testutil.AssertEqual(t, true, len(info.Buildings[index].Facilities) > 0, code+" should list facilities")
+		testutil.AssertEqual(t, true, len(info.Buildings[index].Floors) > 0, code+" should list floors")
+	}
+
+	for _, building := range info.Buildings {
+		testutil.AssertEqual(t, buildingDisplayName(building.Code), building.Name, building.Code+" should be named from the routing registry")
+	}
+
+	info.Buildings[0].Facilities[0] = "mutated"
+	testutil.AssertEqual(t, true, GetVenueInfo().Buildings[0].Facilities[0] != "mutated", "Callers should get a copy")
+}
+
+func TestReloadVenueInfo(t *testing.T) {
+	original := currentVenueInfo.Load()
+	t.Cleanup(func() { currentVenueInfo.Store(original) })
+
+	ReloadVenueInfo(VenueInfo{
+		MapURL:    "https://example.org/venue",
+		Buildings: []VenueBuilding{{Code: "OT", Name: "Other", Floors: []int{1}, Facilities: []string{"Hall"}}},
+	})
+
+	info := GetVenueInfo()
+	testutil.AssertEqual(t, "https://example.org/venue", info.MapURL, "Reloaded map URL should be served")
+	testutil.AssertEqual(t, 1, len(info.Buildings), "Reloaded buildings should replace the defaults")
+
+	// Handlers may read the venue while it is being reloaded (run with -race)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				_ = GetVenueInfo().MapURL
+			}
+		}()
+	}
+	for range 20 {
+		ReloadVenueInfo(*original)
+	}
+	wg.Wait()
+}
+
+// All compatible sessions tests
+
+func TestFindAllCompatibleSessions(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "COMP-PAST", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9"},
+			{Code: "COMP-TR-1", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "COMP-AU-1", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "COMP-TR-2", Start: "10:30", End: "11:00", Room: "TR211", Day: "Aug.9"},
+			{Code: "COMP-AU-2", Start: "10:30", End: "11:00", Room: "AU", Day: "Aug.9"},
+			{Code: "COMP-CLASH", Start: "11:00", End: "11:30", Room: "TR212", Day: "Aug.9"},
+			{Code: "COMP-RB-1", Start: "11:30", End: "12:00", Room: "RB-105", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID:   "test_all_compatible",
+		Day:         "Aug.9",
+		Schedule:    []Session{{Code: "COMP-MINE", Start: "11:00", End: "11:30", Room: "TR213", Day: "Aug.9"}},
+		LastEndTime: "10:00",
+	}
+	storeTestUserState(t, state)
+
+	compatible := FindAllCompatibleSessions(state.SessionID)
+	testutil.AssertEqual(t, "COMP-AU-1,COMP-TR-1,COMP-AU-2,COMP-TR-2,COMP-RB-1", recommendationCodes(compatible),
+		"Should list every compatible session sorted by time then room")
+
+	perRoom := FindNextAvailableInEachRoom(state.Day, state.LastEndTime, state.Schedule)
+	testutil.AssertEqual(t, true, len(compatible) > len(perRoom), "Should return more than one session per room")
+
+	testutil.AssertEqual(t, 0, len(FindAllCompatibleSessions("nonexistent_session")), "Unknown session returns nothing")
+}
+
+// Post-reload conflict tests
+
+func TestResolvePostReloadConflicts(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	first := Session{Code: "MOVE-A", Title: "Session A", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	second := Session{Code: "MOVE-B", Title: "Session B", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"}
+	later := Session{Code: "MOVE-C", Title: "Session C", Start: "13:00", End: "13:30", Room: "TR212", Day: "Aug.9"}
+
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {first}, "TR211": {second}, "TR212": {later}},
+	})
+
+	state := &UserState{
+		SessionID:   "test_post_reload_conflicts",
+		Day:         "Aug.9",
+		Schedule:    []Session{first, second, later},
+		LastEndTime: "13:30",
+	}
+	storeTestUserState(t, state)
+	testutil.AssertEqual(t, 0, len(ResolvePostReloadConflicts(state.SessionID)), "No conflicts before the reload")
+
+	// The agenda moves session B onto session A's slot
+	moved := second
+	moved.Start, moved.End = "10:15", "10:45"
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {first}, "TR211": {moved}, "TR212": {later}},
+	})
+
+	choices := ResolvePostReloadConflicts(state.SessionID)
+	testutil.AssertEqual(t, 1, len(choices), "Reload should introduce one conflict")
+	testutil.AssertEqual(t, "MOVE-A,MOVE-B", recommendationCodes(choices[0].Options), "Conflict should list both competing sessions")
+	testutil.AssertEqual(t, "10:00", choices[0].Start, "Conflict should start with the earliest session")
+	testutil.AssertEqual(t, "10:45", choices[0].End, "Conflict should end with the latest session")
+
+	removed, err := KeepConflictingSession(state.SessionID, "MOVE-B")
+	testutil.AssertNoError(t, err, "Keeping a scheduled session should succeed")
+	testutil.AssertEqual(t, "MOVE-A", recommendationCodes(removed), "The other competing session should be removed")
+	testutil.AssertEqual(t, 0, len(ResolvePostReloadConflicts(state.SessionID)), "Conflict should be resolved")
+	testutil.AssertEqual(t, "13:30", GetUserStateSnapshot(state.SessionID).LastEndTime, "Last end time should be unchanged")
+
+	_, err = KeepConflictingSession(state.SessionID, "MOVE-A")
+	testutil.AssertError(t, err, "Keeping a session not in the schedule should fail")
+}
+
+// Unsampled tracks tests
+
+func TestUnsampledTracks(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TRACK-AI-1", Start: "10:00", End: "10:30", Room: "AU", Track: "AI", Day: "Aug.9"},
+			{Code: "TRACK-WEB-1", Start: "10:00", End: "10:30", Room: "TR211", Track: "Web", Day: "Aug.9"},
+			{Code: "TRACK-WEB-2", Start: "11:00", End: "11:30", Room: "TR211", Track: "Web", Day: "Aug.9"},
+			{Code: "TRACK-DB-1", Start: "11:00", End: "11:30", Room: "TR212", Track: "Database", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "TRACK-OTHER-DAY", Start: "10:00", End: "10:30", Room: "AU", Track: "Rust", Day: "Aug.10"},
+		},
+	})
+
+	state := &UserState{
+		SessionID: "test_unsampled_tracks",
+		Day:       "Aug.9",
+		Profile:   []string{"AI"},
+	}
+	storeTestUserState(t, state)
+
+	unsampled := UnsampledTracks(state.SessionID)
+	testutil.AssertEqual(t, 2, len(unsampled), "Should list the two tracks not in the profile")
+
+	_, hasAI := unsampled["AI"]
+	testutil.AssertEqual(t, false, hasAI, "Tracks already in the profile should be excluded")
+	_, hasRust := unsampled["Rust"]
+	testutil.AssertEqual(t, false, hasRust, "Tracks from the other day should be excluded")
+	testutil.AssertEqual(t, "TRACK-WEB-1", unsampled["Web"].Code, "Teaser should be the track's representative session")
+	testutil.AssertEqual(t, "TRACK-DB-1", unsampled["Database"].Code, "Every unsampled track should have a teaser")
+
+	testutil.AssertEqual(t, 0, len(UnsampledTracks("nonexistent_session")), "Unknown session returns nothing")
+}
+
+// Companion mode tests
+
+func TestFindFreeSlots(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_free_slots",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "FREE-B", Start: "11:00", End: "12:00"},
+			{Code: "FREE-A", Start: "09:00", End: "10:00"},
+			{Code: "FREE-C", Start: "14:00", End: "15:00", Cancelled: true},
+		},
+	}
+	storeTestUserState(t, state)
+
+	slots := FindFreeSlots(state.SessionID)
+	testutil.AssertEqual(t, 2, len(slots), "Should find the gap between sessions and the afternoon")
+	testutil.AssertEqual(t, [2]string{"10:00", "11:00"}, slots[0], "First slot should be the morning gap")
+	testutil.AssertEqual(t, [2]string{"12:00", "17:00"}, slots[1], "Cancelled sessions should not occupy time")
+}
+
+func TestGetFreeSlots(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_get_free_slots",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "GFREE-B", Start: "10:00", End: "11:30"},
+			{Code: "GFREE-C", Start: "11:45", End: "13:00"},
+			{Code: "GFREE-D", Start: "14:00", End: "16:00"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	slots := GetFreeSlots(state.SessionID)
+	testutil.AssertEqual(t, 3, len(slots), "The 15-minute gap should be left out")
+	describe := func(slot map[string]string) string {
+		return slot["start"] + "-" + slot["end"] + " " + slot["duration"]
+	}
+	testutil.AssertEqual(t, "09:00-10:00 60", describe(slots[0]), "Gap before the first session")
+	testutil.AssertEqual(t, "13:00-14:00 60", describe(slots[1]), "Gap between sessions")
+	testutil.AssertEqual(t, "16:00-17:00 60", describe(slots[2]), "Gap until conference end")
+
+	testutil.AssertEqual(t, 0, len(GetFreeSlots("nonexistent_session")), "Unknown session has no slots")
+}
+
+func TestFindFreeSlotOfLength(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_free_block",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "BLOCK-A", Start: "09:00", End: "10:00"},
+			{Code: "BLOCK-B", Start: "10:30", End: "11:00"},
+			{Code: "BLOCK-C", Start: "12:00", End: "17:00"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	start, end, ok := FindFreeSlotOfLength(state.SessionID, 45)
+	testutil.AssertEqual(t, true, ok, "The 11:00-12:00 gap fits 45 minutes")
+	testutil.AssertEqual(t, "11:00", start, "Earliest qualifying gap start")
+	testutil.AssertEqual(t, "12:00", end, "Earliest qualifying gap end")
+
+	start, _, ok = FindFreeSlotOfLength(state.SessionID, 30)
+	testutil.AssertEqual(t, true, ok, "A 30-minute block should be found")
+	testutil.AssertEqual(t, "10:00", start, "Shorter requests should get the earlier gap")
+
+	_, _, ok = FindFreeSlotOfLength(state.SessionID, 90)
+	testutil.AssertEqual(t, false, ok, "No gap is 90 minutes long")
+}
+
+func TestSuggestLunchTime(t *testing.T) {
+	t.Run("Midday gap", func(t *testing.T) {
+		state := &UserState{
+			SessionID: "test_lunch_gap",
+			Day:       "Aug.9",
+			Schedule: []Session{
+				{Code: "LUNCH-A", Start: "09:00", End: "11:30"},
+				{Code: "LUNCH-B", Start: "11:50", End: "12:20"},
+				{Code: "LUNCH-C", Start: "13:10", End: "17:00"},
+			},
+		}
+		storeTestUserState(t, state)
+
+		start, end, ok := SuggestLunchTime(state.SessionID)
+		testutil.AssertEqual(t, true, ok, "A midday gap should be suggested")
+		testutil.AssertEqual(t, "12:20", start, "Longest midday gap start")
+		testutil.AssertEqual(t, "13:10", end, "Longest midday gap end")
+	})
+
+	t.Run("Too packed", func(t *testing.T) {
+		state := &UserState{
+			SessionID: "test_lunch_packed",
+			Day:       "Aug.9",
+			Schedule: []Session{
+				{Code: "PACK-A", Start: "09:00", End: "12:10"},
+				{Code: "PACK-B", Start: "12:30", End: "17:00"},
+			},
+		}
+		storeTestUserState(t, state)
+
+		_, _, ok := SuggestLunchTime(state.SessionID)
+		testutil.AssertEqual(t, false, ok, "A 20-minute gap is too short for lunch")
+	})
+
+	t.Run("Empty schedule", func(t *testing.T) {
+		storeTestUserState(t, &UserState{SessionID: "test_lunch_empty", Day: "Aug.9"})
+
+		start, end, ok := SuggestLunchTime("test_lunch_empty")
+		testutil.AssertEqual(t, true, ok, "An empty day has time for lunch")
+		testutil.AssertEqual(t, "12:00", start, "Suggestion should start with the lunch window, not the conference")
+		testutil.AssertEqual(t, "13:30", end, "Suggestion should end with the lunch window")
+	})
+
+	t.Run("Long gap clamped to the lunch window", func(t *testing.T) {
+		state := &UserState{
+			SessionID: "test_lunch_long_gap",
+			Day:       "Aug.9",
+			Schedule: []Session{
+				{Code: "WIDE-A", Start: "09:00", End: "11:00"},
+				{Code: "WIDE-B", Start: "12:40", End: "12:45"},
+				{Code: "WIDE-C", Start: "15:00", End: "17:00"},
+			},
+		}
+		storeTestUserState(t, state)
+
+		start, end, ok := SuggestLunchTime(state.SessionID)
+		testutil.AssertEqual(t, true, ok, "The gaps around a short session still leave room for lunch")
+		testutil.AssertEqual(t, "12:45", start, "The slot with the most lunch-window time should win")
+		testutil.AssertEqual(t, "13:30", end, "The suggestion should stop at the end of the lunch window")
+	})
+}
+
+func TestFindCommonFreeSlots(t *testing.T) {
+	alice := &UserState{
+		SessionID: "test_companion_a",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "A1", Start: "09:00", End: "12:00"},
+			{Code: "A2", Start: "13:30", End: "17:00"},
+		},
+	}
+	bob := &UserState{
+		SessionID: "test_companion_b",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "B1", Start: "09:00", End: "11:30"},
+			{Code: "B2", Start: "12:30", End: "17:00"},
+		},
+	}
+	other := &UserState{SessionID: "test_companion_other_day", Day: "Aug.10"}
+	storeTestUserState(t, alice)
+	storeTestUserState(t, bob)
+	storeTestUserState(t, other)
+
+	common := FindCommonFreeSlots(alice.SessionID, bob.SessionID)
+	testutil.AssertEqual(t, 1, len(common), "Should share one lunchtime gap")
+	testutil.AssertEqual(t, [2]string{"12:00", "12:30"}, common[0], "Shared gap should be the overlap of both breaks")
+	testutil.AssertEqual(t, true, isLunchBreak(common[0][0], common[0][1]), "Shared gap falls in the lunch window")
+
+	testutil.AssertEqual(t, 0, len(FindCommonFreeSlots(alice.SessionID, other.SessionID)), "Different days have no overlap")
+	testutil.AssertEqual(t, 0, len(FindCommonFreeSlots(alice.SessionID, "nonexistent_session")), "Unknown session has no overlap")
+}
+
+// Verbosity tests
+
+func TestGetNextSessionVerbosity(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_next_session_verbosity",
+		Day:       "Aug.10",
+		Schedule: []Session{
+			{Code: "VERB-1", Title: "First Session", Start: "09:00", End: "09:30", Room: "AU"},
+			{Code: "VERB-2", Title: "Second Session", Start: "10:00", End: "10:30", Room: "TR405"},
+		},
+		LastEndTime: "10:30",
+	}
+	storeTestUserState(t, state)
+
+	routeDesc := generateRouteDescription("AU", "TR405")
+
+	terse, err := GetNextSessionWithVerbosity(state.SessionID, testutil.NewMockTimeProvider("09:35"), VerbosityTerse)
+	testutil.AssertNoError(t, err, "Terse status should succeed")
+	_, hasRoute := terse["route"]
+	testutil.AssertEqual(t, false, hasRoute, "Terse output should omit the route")
+	testutil.AssertEqual(t, false, strings.Contains(terse["message"].(string), routeDesc), "Terse message should omit the route description")
+	testutil.AssertEqual(t, true, strings.Contains(terse["message"].(string), "TR405"), "Terse message should still name the next room")
+
+	normal, err := GetNextSessionWithTime(state.SessionID, testutil.NewMockTimeProvider("09:35"))
+	testutil.AssertNoError(t, err, "Normal status should succeed")
+	testutil.AssertEqual(t, true, strings.Contains(normal["message"].(string), routeDesc), "Normal message should include the route description")
+
+	rich, err := GetNextSessionWithVerbosity(state.SessionID, testutil.NewMockTimeProvider("09:35"), VerbosityRich)
+	testutil.AssertNoError(t, err, "Rich status should succeed")
+	_, hasRoute = rich["route"]
+	testutil.AssertEqual(t, true, hasRoute, "Rich output should include the route")
+	testutil.AssertEqual(t, true, strings.Contains(rich["message"].(string), routeDesc), "Rich message should include the route description")
+	testutil.AssertEqual(t, true, strings.Contains(rich["message"].(string), "詳細路線"), "Rich message should add step-by-step directions")
+}
+
+func TestParseVerbosity(t *testing.T) {
+	testutil.AssertEqual(t, VerbosityTerse, parseVerbosity("terse"), "terse should parse")
+	testutil.AssertEqual(t, VerbosityRich, parseVerbosity("rich"), "rich should parse")
+	testutil.AssertEqual(t, VerbosityNormal, parseVerbosity(""), "empty should default to normal")
+	testutil.AssertEqual(t, VerbosityNormal, parseVerbosity("loud"), "unknown values should default to normal")
+}
+
+// Floor query tests
+
+func TestRoomFloor(t *testing.T) {
+	tests := []struct {
+		room     string
+		floor    int
+		hasFloor bool
+	}{
+		{"TR509", 5, true},
+		{"TR209", 2, true},
+		{"RB-105", 1, true},
+		{"TR310-2", 3, true},
+		{"AU", 0, false},
+		{"Hallway outside TR309", 0, false},
+	}
+
+	for _, tt := range tests {
+		floor, ok := roomFloor(tt.room)
+		testutil.AssertEqual(t, tt.hasFloor, ok, "roomFloor should detect a floor for "+tt.room)
+		testutil.AssertEqual(t, tt.floor, floor, "roomFloor should extract the floor of "+tt.room)
+	}
+}
+
+func TestFindSessionsByFloor(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "FLOOR-515", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"},
+			{Code: "FLOOR-509", Start: "10:00", End: "10:30", Room: "TR509", Day: "Aug.9"},
+			{Code: "FLOOR-509-EARLY", Start: "09:00", End: "09:30", Room: "TR509", Day: "Aug.9"},
+			{Code: "FLOOR-209", Start: "10:00", End: "10:30", Room: "TR209", Day: "Aug.9"},
+			{Code: "FLOOR-AU", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+	})
+
+	sessions := FindSessionsByFloor("Aug.9", BuildingTR, 5, "")
+	testutil.AssertEqual(t, "FLOOR-509-EARLY,FLOOR-509,FLOOR-515", recommendationCodes(sessions), "Should list floor 5 sessions by time then room")
+
+	sessions = FindSessionsByFloor("Aug.9", BuildingTR, 5, "09:45")
+	testutil.AssertEqual(t, "FLOOR-509,FLOOR-515", recommendationCodes(sessions), "Ended sessions should be excluded")
+
+	testutil.AssertEqual(t, 0, len(FindSessionsByFloor("Aug.9", BuildingAU, 1, "")), "Rooms without a floor never match")
+}
+
+// Repeated talk tests
+
+func TestScheduleSessionPicksFreeRepeatInstance(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "BUSY", Title: "Busy Talk", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "REPEAT-1", Title: "Repeated Workshop", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "REPEAT-2", Title: "Repeated Workshop", Start: "14:00", End: "14:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "REPEAT-3", Title: "Repeated Workshop", Start: "16:00", End: "16:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "CLASH", Title: "Unique Talk", Start: "10:00", End: "10:30", Room: "TR212", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{SessionID: "test_repeat_instance", Day: "Aug.9", LastEndTime: "08:00"}
+	storeTestUserState(t, state)
+	testutil.AssertNoError(t, AddSessionToSchedule(state.SessionID, "BUSY"), "First add should succeed")
+
+	added, err := ScheduleSession(state.SessionID, "REPEAT-1")
+	testutil.AssertNoError(t, err, "A conflicting repeated talk should be added in another timeslot")
+	testutil.AssertEqual(t, "REPEAT-2", added.Code, "The closest free instance should be chosen")
+	testutil.AssertEqual(t, "BUSY,REPEAT-2", recommendationCodes(GetUserStateSnapshot(state.SessionID).Schedule), "Free instance should be in the schedule")
+
+	testutil.AssertError(t, AddSessionToSchedule(state.SessionID, "CLASH"), "Conflicting talk without repeats should still be rejected")
+}
+
+func TestScheduleSessionConcurrentConflictingChoices(t *testing.T) {
+	var sessions []Session
+	for i := range 8 {
+		sessions = append(sessions, Session{Code: fmt.Sprintf("RACE-%d", i), Title: fmt.Sprintf("Talk %d", i), Start: "10:00", End: "10:30", Room: fmt.Sprintf("TR%d", 211+i), Day: "Aug.9"})
+	}
+	setTestSessions(t, map[string][]Session{"Aug.9": sessions})
+	storeTestUserState(t, &UserState{SessionID: "test_concurrent_choices", Day: "Aug.9", LastEndTime: "09:00"})
+
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ScheduleSession("test_concurrent_choices", session.Code)
+		}()
+	}
+	wg.Wait()
+
+	testutil.AssertEqual(t, 1, len(GetUserStateSnapshot("test_concurrent_choices").Schedule), "Only one of the overlapping choices may be added")
+}
+
+// Missed sessions tests
+
+func TestMissedBetween(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_missed_sessions",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "MISS-LATER", Start: "11:00", End: "11:30"},
+			{Code: "MISS-BEFORE", Start: "09:00", End: "09:30"},
+			{Code: "MISS-1", Start: "10:00", End: "10:30"},
+			{Code: "MISS-ONGOING", Start: "11:30", End: "12:30"},
+			{Code: "MISS-CANCELLED", Start: "10:30", End: "11:00", Cancelled: true},
+		},
+	}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(COSCUPYear, COSCUPMonth, COSCUPDay1, hour, minute, 0, 0, conferenceLocation())
+	}
+
+	missed := missedBetween(state, at(9, 45), at(11, 40))
+	testutil.AssertEqual(t, "MISS-1,MISS-LATER", recommendationCodes(missed), "Sessions ended between last activity and now should be reported")
+
+	testutil.AssertEqual(t, 0, len(missedBetween(state, at(11, 40), at(11, 45))), "Nothing ended in a short absence")
+
+	state.Day = "Aug.10"
+	testutil.AssertEqual(t, 0, len(missedBetween(state, at(9, 45), at(11, 40))), "Sessions on the next day have not ended yet")
+}
+
+func TestMissedSinceUsesStoredSchedule(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_missed_since",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "MISS-PAST", Start: "09:00", End: "09:30"}},
+	}
+	storeTestUserState(t, state)
+
+	// The conference is in the past, so a last activity before it makes every session missed
+	since := time.Date(COSCUPYear, COSCUPMonth, COSCUPDay1, 8, 0, 0, 0, conferenceLocation())
+	testutil.AssertEqual(t, "MISS-PAST", recommendationCodes(MissedSince(state.SessionID, since)), "Ended session should be missed")
+	testutil.AssertEqual(t, 0, len(MissedSince("nonexistent_session", since)), "Unknown session returns nothing")
+}
+
+// Schedule token tests
+
+func TestImportScheduleICSRoundTrip(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "ICS-001", Title: "Rust, Go; and \\ more", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "ICS-002", Title: "Databases", Start: "11:00", End: "11:40", Room: "TR211", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "ICS-003", Title: "Day Two", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.10"},
+		},
+	})
+	schedule := []Session{sessionData().byDay["Aug.9"][0], sessionData().byDay["Aug.9"][1]}
+
+	exported := ExportScheduleICS("Aug.9", schedule)
+	testutil.AssertEqual(t, true, strings.Contains(exported, "DTSTART;TZID=Asia/Taipei:20250809T100000"), "Start should be in conference time")
+	testutil.AssertEqual(t, true, strings.Contains(exported, `SUMMARY:Rust\, Go\; and \\ more`), "Text should be escaped")
+	testutil.AssertEqual(t, true, strings.Contains(exported, "BEGIN:VTIMEZONE\r\nTZID:Asia/Taipei\r\n"), "The TZID used by events should be defined")
+	testutil.AssertEqual(t, true, strings.Contains(exported, "TZOFFSETFROM:+0800\r\nTZOFFSETTO:+0800\r\n"), "Taipei is UTC+8 all year")
+	testutil.AssertEqual(t, true, strings.Index(exported, "END:VTIMEZONE") < strings.Index(exported, "BEGIN:VEVENT"), "The timezone should precede the events")
+
+	codes, err := ImportScheduleICS("Aug.9", strings.NewReader(exported))
+	testutil.AssertNoError(t, err, "Exported calendar should import")
+	testutil.AssertSliceEqual(t, []string{"ICS-001", "ICS-002"}, codes, "Round trip should recover the original codes")
+
+	// Calendar apps may drop custom properties and convert times to UTC; time and room still match
+	external := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART:20250809T030000Z\r\nSUMMARY:Databases\r\nLOCATION:TR211\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART:20250809T040000Z\r\nSUMMARY:Lunch with friends\r\nLOCATION:Cafe\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;TZID=Asia/Taipei:20250810T100000\r\nSUMMARY:Day Two\r\nLOCATION:AU\r\nX-COSCUP-CODE:ICS-003\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	codes, err = ImportScheduleICS("Aug.9", strings.NewReader(external))
+	testutil.AssertNoError(t, err, "External calendar should import")
+	testutil.AssertSliceEqual(t, []string{"ICS-002"}, codes, "Unmatched events and other days should be skipped")
+
+	_, err = ImportScheduleICS("Aug.9", strings.NewReader("not a calendar"))
+	testutil.AssertEqual(t, ErrInvalidICS, err, "Non-calendar input should be rejected")
+}
+
+func TestPreviewScheduleToken(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	kept := Session{Code: "TOKEN-KEEP", Title: "Kept Talk", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	dropped := Session{Code: "TOKEN-DROP", Title: "Dropped Talk", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"}
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}, "TR211": {dropped}},
+	})
+
+	token := EncodeScheduleToken("Aug.9", []Session{kept, dropped})
+
+	preview, err := PreviewScheduleToken(token)
+	testutil.AssertNoError(t, err, "Valid token should preview")
+	testutil.AssertEqual(t, "TOKEN-KEEP,TOKEN-DROP", recommendationCodes(preview), "Preview should resolve every code")
+	testutil.AssertEqual(t, "Kept Talk", preview[0].Title, "Preview should contain full session details")
+	testutil.AssertEqual(t, 0, countCancelledSessions(preview), "No codes should be flagged while all exist")
+
+	// The official schedule drops one session after the token was shared
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {kept}},
+	})
+
+	preview, err = PreviewScheduleToken(token)
+	testutil.AssertNoError(t, err, "Token with a stale code should still preview")
+	testutil.AssertEqual(t, false, preview[0].Cancelled, "Existing session should not be flagged")
+	testutil.AssertEqual(t, true, preview[1].Cancelled, "Removed session should be flagged cancelled")
+
+	_, err = PreviewScheduleToken("not-a-token")
+	testutil.AssertError(t, err, "Malformed token should fail")
+}
+
+// Auto-finish tests
+
+func TestAutoFinishOnlyWhenNoRealOptionsAndDeclined(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.10": {
+			{Code: "AUTO-DONE", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.10"},
+			{Code: "AUTO-SOCIAL", Title: "Hacking Corner", Start: "16:00", End: "17:00", Room: "TR211", Day: "Aug.10"},
+			{Code: "AUTO-REAL", Title: "Late Talk", Start: "15:00", End: "15:30", Room: "TR212", Day: "Aug.10"},
+		},
+	})
+
+	newState := func(id string, autoFinish bool, schedule []Session) *UserState {
+		state := &UserState{SessionID: id, Day: "Aug.10", Schedule: schedule, LastEndTime: "09:30", AutoFinish: autoFinish}
+		storeTestUserState(t, state)
+		return state
+	}
+	done := Session{Code: "AUTO-DONE", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.10"}
+	realTalk := Session{Code: "AUTO-REAL", Start: "15:00", End: "15:30", Room: "TR212", Day: "Aug.10"}
+
+	// Only the social activity is left after the late talk is scheduled
+	optedIn := newState("test_auto_finish_opted_in", true, []Session{done, realTalk})
+	optedIn.LastEndTime = "15:30"
+	testutil.AssertEqual(t, false, AutoFinishIfComplete(optedIn.SessionID), "Should not auto-finish before more planning was offered")
+
+	status, err := GetNextSessionWithTime(optedIn.SessionID, testutil.NewMockTimeProvider("15:45"))
+	testutil.AssertNoError(t, err, "Status should succeed")
+	testutil.AssertEqual(t, "planning_available", status["status"], "First check offers more planning")
+
+	status, err = GetNextSessionWithTime(optedIn.SessionID, testutil.NewMockTimeProvider("16:10"))
+	testutil.AssertNoError(t, err, "Status should succeed")
+	testutil.AssertEqual(t, "schedule_complete", status["status"], "Declined offer with no real options should auto-finish")
+	testutil.AssertEqual(t, true, GetUserStateSnapshot(optedIn.SessionID).IsCompleted, "Planning should be marked finished")
+
+	// A real talk is still available
+	withOptions := newState("test_auto_finish_real_options", true, []Session{done})
+	withOptions.OfferedAt = 1
+	testutil.AssertEqual(t, false, AutoFinishIfComplete(withOptions.SessionID), "Real options left should prevent auto-finish")
+
+	// Not opted in
+	optedOut := newState("test_auto_finish_opted_out", false, []Session{done, realTalk})
+	optedOut.LastEndTime = "15:30"
+	optedOut.OfferedAt = 2
+	testutil.AssertEqual(t, false, AutoFinishIfComplete(optedOut.SessionID), "Auto-finish is opt-in")
+}
+
+func TestScheduleCompleteReason(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "WHY-001", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9"},
+			{Code: "WHY-002", Start: "16:30", End: "17:00", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+	done := Session{Code: "WHY-001", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9"}
+
+	tests := []struct {
+		name      string
+		completed bool
+		timeStr   string
+		day       string
+		expected  string
+	}{
+		{"User finished planning", true, "10:00", DayAug9, CompleteReasonFinishedPlanning},
+		{"Last planned session ended, too late to add more", false, "16:45", DayAug9, CompleteReasonNoMoreSessions},
+		{"Day's program is over", true, "17:10", DayAug9, CompleteReasonConferenceOver},
+		{"Planned day is already past", true, "10:00", DayAug10, CompleteReasonConferenceOver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &UserState{
+				SessionID:   "test_complete_reason",
+				Day:         "Aug.9",
+				Schedule:    []Session{done},
+				LastEndTime: "09:30",
+				IsCompleted: tt.completed,
+			}
+			storeTestUserState(t, state)
+
+			status, err := GetNextSessionWithTime(state.SessionID, testutil.NewMockTimeProviderWithDay(tt.timeStr, tt.day))
+			testutil.AssertNoError(t, err, "Status should succeed")
+			testutil.AssertEqual(t, "schedule_complete", status["status"], "Status")
+			testutil.AssertEqual(t, tt.expected, status["reason"], "Reason")
+		})
+	}
+}
+
+func TestTimelineAnnotatesGapWalkingTime(t *testing.T) {
+	state := &UserState{
+		Day: "Aug.9",
+		Schedule: []Session{
+			{Code: "WALK-001", Title: "Keynote", Start: "09:00", End: "09:30", Room: "AU"},
+			{Code: "WALK-002", Title: "Across Campus", Start: "10:00", End: "10:30", Room: "TR405"},
+			{Code: "WALK-003", Title: "Same Room", Start: "10:45", End: "11:15", Room: "TR405"},
+			{Code: "WALK-004", Title: "Same Building", Start: "11:30", End: "12:00", Room: "TR211"},
+		},
+	}
+
+	view := generateTimelineView(state)
+	testutil.AssertEqual(t, true, strings.Contains(view, "09:30-10:00 | 🆓 空檔時間 (30分鐘，步行約 4 分鐘，實際空閒約 26 分鐘)"),
+		"Cross-building gap should show walking and net free time")
+	testutil.AssertEqual(t, true, strings.Contains(view, "10:30-10:45 | 🆓 空檔時間 (15分鐘，步行約 0 分鐘，實際空閒約 15 分鐘)"),
+		"Gap in the same room should show no walking")
+	testutil.AssertEqual(t, true, strings.Contains(view, "11:15-11:30 | 🆓 空檔時間 (15分鐘，步行約 0 分鐘，實際空閒約 15 分鐘)"),
+		"Gap within the same building should show no walking")
+}
+
+// Ongoing interest tests
+
+func TestOngoingMatchingInterests(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LIVE-PLAN", Title: "Planned", Track: "Go", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9", Tags: []string{"cloud"}},
+			{Code: "LIVE-TRACK", Title: "Go Live", Track: "Go", Start: "10:00", End: "10:40", Room: "TR211", Day: "Aug.9"},
+			{Code: "LIVE-TAG", Title: "Cloud Live", Track: "Ops", Start: "10:10", End: "10:50", Room: "RB-105", Day: "Aug.9", Tags: []string{"cloud"}},
+			{Code: "LIVE-OFF", Title: "Unrelated", Track: "Art", Start: "10:00", End: "10:40", Room: "TR212", Day: "Aug.9"},
+			{Code: "LIVE-LATER", Title: "Go Later", Track: "Go", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "LIVE-ENDED", Title: "Go Ended", Track: "Go", Start: "09:30", End: "10:15", Room: "TR213", Day: "Aug.9"},
+		},
+	})
+
+	state := &UserState{
+		SessionID: "test_ongoing_interests",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "LIVE-PLAN", Track: "Go", Start: "09:00", End: "09:30", Room: "AU", Tags: []string{"cloud"}}},
+		Profile:   []string{"Go"},
+	}
+	storeTestUserState(t, state)
+
+	ongoing := OngoingMatchingInterests(state.SessionID, "Aug.9", "10:20")
+	testutil.AssertEqual(t, "LIVE-TRACK,LIVE-TAG", recommendationCodes(ongoing), "Only running profile matches should be returned, track matches first")
+
+	testutil.AssertEqual(t, 0, len(OngoingMatchingInterests(state.SessionID, "Aug.9", "12:00")), "Nothing should match after the sessions end")
+	testutil.AssertEqual(t, 0, len(OngoingMatchingInterests("nonexistent_session", "Aug.9", "10:20")), "Unknown session has no matches")
+}
+
+// Crowding tests
+
+func TestBuildingConcurrencyAt(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "CROWD-TR1", Start: "10:00", End: "10:30", Room: "TR509", Day: "Aug.9"},
+			{Code: "CROWD-TR2", Start: "10:00", End: "10:30", Room: "TR510", Day: "Aug.9"},
+			{Code: "CROWD-TR3", Start: "10:00", End: "10:30", Room: "TR511", Day: "Aug.9"},
+			{Code: "CROWD-TR4", Start: "10:00", End: "10:30", Room: "TR512", Day: "Aug.9"},
+			{Code: "CROWD-TR5", Start: "10:00", End: "10:30", Room: "TR513", Day: "Aug.9"},
+			{Code: "CROWD-TR6", Start: "10:00", End: "10:30", Room: "TR514", Day: "Aug.9"},
+			{Code: "CROWD-TR7", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"},
+			{Code: "CROWD-TR8", Start: "10:00", End: "10:30", Room: "TR516", Day: "Aug.9"},
+			{Code: "CROWD-AU", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "CROWD-RB1", Start: "10:10", End: "10:40", Room: "RB101", Day: "Aug.9"},
+			{Code: "CROWD-RB2", Start: "11:00", End: "11:30", Room: "RB102", Day: "Aug.9"},
+		},
+	})
+
+	peak := BuildingConcurrencyAt("Aug.9", "10:15")
+	testutil.AssertEqual(t, 8, peak[BuildingTR], "All TR sessions run at the peak")
+	testutil.AssertEqual(t, 1, peak[BuildingAU], "AU has one session")
+	testutil.AssertEqual(t, 1, peak[BuildingRB], "Only the started RB session counts")
+	testutil.AssertEqual(t, "TR", strings.Join(busiestBuildings(peak), ","), "TR should be the busiest building")
+
+	later := BuildingConcurrencyAt("Aug.9", "11:10")
+	testutil.AssertEqual(t, "RB", strings.Join(busiestBuildings(later), ","), "Only RB runs later")
+	testutil.AssertEqual(t, 0, len(busiestBuildings(BuildingConcurrencyAt("Aug.9", "18:00"))), "Nothing runs in the evening")
+}
+
+// Plan review tests
+
+func TestReviewSchedule(t *testing.T) {
+	review := func(id string, schedule []Session) []string {
+		storeTestUserState(t, &UserState{SessionID: id, Day: "Aug.9", Schedule: schedule})
+		return ReviewSchedule(id)
+	}
+	hasWarning := func(warnings []string, substr string) bool {
+		return slices.ContainsFunc(warnings, func(w string) bool { return strings.Contains(w, substr) })
+	}
+
+	t.Run("Tight transfer", func(t *testing.T) {
+		warnings := review("test_review_tight", []Session{
+			{Code: "REV-001", Track: "A", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "REV-002", Track: "B", Start: "10:30", End: "11:00", Room: "TR405"},
+		})
+		testutil.AssertEqual(t, 1, len(warnings), "Only the transfer should be flagged")
+		testutil.AssertEqual(t, true, hasWarning(warnings, "很可能會遲到"), "Back-to-back cross-building transfer should be flagged")
+	})
+
+	t.Run("No lunch", func(t *testing.T) {
+		warnings := review("test_review_lunch", []Session{
+			{Code: "REV-011", Track: "A", Start: "11:50", End: "12:30", Room: "AU"},
+			{Code: "REV-012", Track: "B", Start: "12:30", End: "13:10", Room: "AU"},
+			{Code: "REV-013", Track: "C", Start: "13:10", End: "13:40", Room: "AU"},
+		})
+		testutil.AssertEqual(t, 1, len(warnings), "Only lunch should be flagged")
+		testutil.AssertEqual(t, true, hasWarning(warnings, "午餐"), "Packed lunch window should be flagged")
+	})
+
+	t.Run("Long gap", func(t *testing.T) {
+		warnings := review("test_review_gap", []Session{
+			{Code: "REV-021", Track: "A", Start: "14:00", End: "14:30", Room: "AU"},
+			{Code: "REV-022", Track: "B", Start: "16:00", End: "16:30", Room: "AU"},
+		})
+		testutil.AssertEqual(t, 1, len(warnings), "Only the gap should be flagged")
+		testutil.AssertEqual(t, true, hasWarning(warnings, "90 分鐘的空檔"), "Long gap should be flagged with its length")
+	})
+
+	t.Run("Lunch gap is not offered for filling", func(t *testing.T) {
+		warnings := review("test_review_lunch_gap", []Session{
+			{Code: "REV-041", Track: "A", Start: "10:00", End: "12:00", Room: "AU"},
+			{Code: "REV-042", Track: "B", Start: "13:30", End: "14:00", Room: "AU"},
+		})
+		testutil.AssertEqual(t, 0, len(warnings), "The 12:00-13:30 break is lunch, not a gap to fill")
+	})
+
+	t.Run("Single track", func(t *testing.T) {
+		warnings := review("test_review_variety", []Session{
+			{Code: "REV-031", Track: "Go", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "REV-032", Track: "Go", Start: "10:40", End: "11:10", Room: "AU"},
+			{Code: "REV-033", Track: "Go", Start: "14:00", End: "14:30", Room: "AU"},
+		})
+		testutil.AssertEqual(t, false, hasWarning(warnings, "很可能會遲到"), "Same-room transfers are fine")
+		testutil.AssertEqual(t, true, hasWarning(warnings, "同一個議程軌"), "Single-track plan should be flagged")
+	})
+
+	t.Run("Clean plan", func(t *testing.T) {
+		warnings := review("test_review_clean", []Session{
+			{Code: "REV-041", Track: "A", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "REV-042", Track: "B", Start: "10:40", End: "11:10", Room: "TR405"},
+		})
+		testutil.AssertEqual(t, 0, len(warnings), "A relaxed varied plan should have no warnings")
+		testutil.AssertEqual(t, 0, len(ReviewSchedule("nonexistent_session")), "Unknown session has no warnings")
+	})
+}
+
+// Remaining conference time tests
+
+func TestRemainingConferenceTime(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_remaining_time",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "REM-001", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "REM-002", Start: "14:30", End: "15:00", Room: "AU"},
+		},
+		LastEndTime: "15:00",
+	}
+	storeTestUserState(t, state)
+
+	at := func(day, clock string) time.Time {
+		return dayMidnight(day).Add(time.Duration(timeToMinutes(clock)) * time.Minute)
+	}
+
+	testutil.AssertEqual(t, 120, RemainingConferenceTime(state.SessionID, at("Aug.9", "10:00")), "Plan ending at 15:00 leaves the afternoon until 17:00")
+	testutil.AssertEqual(t, 60, RemainingConferenceTime(state.SessionID, at("Aug.9", "16:00")), "Time already past should not count")
+	testutil.AssertEqual(t, 0, RemainingConferenceTime(state.SessionID, at("Aug.10", "10:00")), "Nothing remains after the planned day")
+	testutil.AssertEqual(t, 0, RemainingConferenceTime("nonexistent_session", at("Aug.9", "10:00")), "Unknown session has no remaining time")
+
+	empty := &UserState{SessionID: "test_remaining_time_empty", Day: "Aug.10"}
+	storeTestUserState(t, empty)
+	testutil.AssertEqual(t, 480, RemainingConferenceTime(empty.SessionID, at("Aug.9", "20:00")), "Empty plan leaves the whole day")
+}
+
+func TestSummarizeRemainingDayMidDay(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_summarize_day",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "SUM-DONE", Title: "Done", Start: "09:30", End: "10:00", Room: "RB-105"},
+			{Code: "SUM-NOW", Title: "Now", Start: "11:00", End: "11:30", Room: "AU"},
+			{Code: "SUM-NEXT", Title: "Next", Start: "11:35", End: "12:00", Room: "TR211"},
+			{Code: "SUM-GONE", Title: "Gone", Start: "13:00", End: "13:30", Room: "RB-105", Cancelled: true},
+			{Code: "SUM-LATER", Title: "Later", Start: "14:00", End: "14:30", Room: "TR212"},
+			{Code: "SUM-LAST", Title: "Last", Start: "15:00", End: "15:30", Room: "AU"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	now := dayMidnight("Aug.9").Add(11*time.Hour + 20*time.Minute)
+	summary := SummarizeRemainingDay(state.SessionID, now)
+
+	testutil.AssertEqual(t, "ongoing", summary["status"], "Status should match get_next_session")
+	testutil.AssertEqual(t, "SUM-NOW", summary["current_session"].(Session).Code, "Current session")
+	testutil.AssertEqual(t, 3, summary["remaining_sessions"], "Cancelled sessions should not count")
+	testutil.AssertEqual(t, "15:30", summary["plan_end"], "Plan end")
+	testutil.AssertEqual(t, "17:00", summary["conference_end"], "Conference end")
+
+	next := summary["next_sessions"].([]UpcomingSession)
+	testutil.AssertEqual(t, MaxSummaryNextSessions, len(next), "Only the next sessions get routes")
+	testutil.AssertEqual(t, "SUM-NEXT", next[0].Session.Code, "First upcoming session")
+	testutil.AssertEqual(t, "AU", next[0].Route.FromRoom, "First route starts at the current room")
+	testutil.AssertEqual(t, false, next[0].Route.EnoughTime, "A 5-minute gap is too short for AU to TR")
+	testutil.AssertEqual(t, "SUM-LATER", next[1].Session.Code, "Second upcoming session")
+	testutil.AssertEqual(t, "TR211", next[1].Route.FromRoom, "Second route continues from the first")
+
+	expectedWalk := AUToTRWalkTime + TRInternalWalkTime + TRToAUWalkTime
+	testutil.AssertEqual(t, expectedWalk, summary["remaining_walking_minutes"], "Walking should cover every remaining transfer")
+
+	testutil.AssertEqual(t, true, SummarizeRemainingDay("nonexistent_session", now) == nil, "Unknown session has no summary")
+}
+
+func TestSummarizeRemainingDayStatusSkipsCancelled(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_summarize_cancelled",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "SUMC-GONE", Title: "Gone", Start: "11:00", End: "12:00", Room: "AU", Cancelled: true},
+			{Code: "SUMC-NEXT", Title: "Next", Start: "13:00", End: "13:30", Room: "AU"},
+		},
+	}
+	storeTestUserState(t, state)
+
+	summary := SummarizeRemainingDay(state.SessionID, dayMidnight("Aug.9").Add(11*time.Hour+30*time.Minute))
+	testutil.AssertEqual(t, "break", summary["status"], "A cancelled session should not count as ongoing")
+}
+
+// Session cleanup tests
+
+func TestCleanupOldSessionsKeepsCompletedPlansLonger(t *testing.T) {
+	inactiveSince := time.Now().Add(-(SessionCleanupHours + 6) * time.Hour)
+	draft := &UserState{SessionID: "test_cleanup_draft", Day: "Aug.9", LastActivity: inactiveSince}
+	finished := &UserState{SessionID: "test_cleanup_finished", Day: "Aug.9", IsCompleted: true, LastActivity: inactiveSince}
+	storeTestUserState(t, draft)
+	storeTestUserState(t, finished)
+
+	CleanupOldSessions()
+
+	testutil.AssertEqual(t, true, GetUserStateSnapshot(draft.SessionID) == nil, "Incomplete session past the short TTL should be cleaned")
+	testutil.AssertEqual(t, true, GetUserStateSnapshot(finished.SessionID) != nil, "Completed session should survive the short TTL")
+}
+
+func TestSetSessionTTLs(t *testing.T) {
+	originalIncomplete, originalCompleted := incompleteSessionTTL, completedSessionTTL
+	t.Cleanup(func() { incompleteSessionTTL, completedSessionTTL = originalIncomplete, originalCompleted })
+
+	SetSessionTTLs(time.Hour, 0)
+	testutil.AssertEqual(t, time.Hour, incompleteSessionTTL, "Incomplete TTL should be configurable")
+	testutil.AssertEqual(t, originalCompleted, completedSessionTTL, "Zero should keep the completed TTL")
+
+	finished := &UserState{SessionID: "test_ttl_finished", Day: "Aug.9", IsCompleted: true, LastActivity: time.Now().Add(-3 * time.Hour)}
+	storeTestUserState(t, finished)
+	SetSessionTTLs(0, 2*time.Hour)
+	CleanupOldSessions()
+	testutil.AssertEqual(t, true, GetUserStateSnapshot(finished.SessionID) == nil, "Completed session past its configured TTL should be cleaned")
+}
+
+// Off-campus break tests
+
+func TestFindBreakForExcursion(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_excursion",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "EXC-001", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "EXC-002", Start: "10:45", End: "11:15", Room: "AU"},    // 15 min break: too short
+			{Code: "EXC-003", Start: "12:30", End: "13:00", Room: "TR405"}, // 75 min break, 2+5 min to and from the gate
+		},
+	}
+	storeTestUserState(t, state)
+
+	start, end, ok := FindBreakForExcursion(state.SessionID, 40)
+	testutil.AssertEqual(t, true, ok, "The long break should fit a 40 minute round trip")
+	testutil.AssertEqual(t, "11:15", start, "Break should start when the previous session ends")
+	testutil.AssertEqual(t, "12:30", end, "Break should end when the next session starts")
+
+	_, _, ok = FindBreakForExcursion(state.SessionID, 70)
+	testutil.AssertEqual(t, false, ok, "Walking to and from the gate should not fit in the remaining 5 minutes")
+
+	_, _, ok = FindBreakForExcursion("nonexistent_session", 10)
+	testutil.AssertEqual(t, false, ok, "Unknown session has no breaks")
+}
+
+// Search tests
+
+func TestSearchSessions(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "SRCH-K8S", Title: "Running Kubernetes at Home", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9", Tags: []string{TagSystem}, Abstract: "long"},
+			{Code: "SRCH-ABS", Title: "Cluster Tales", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Abstract: "We migrated to kubernetes", Tags: []string{TagSystem}},
+			{Code: "SRCH-SPK", Title: "Rust Basics", Speakers: []string{"Alice Chen"}, Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9", Tags: []string{TagLanguages}},
+		},
+		"Aug.10": {
+			{Code: "SRCH-D2", Title: "Kubernetes Security", Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.10", Tags: []string{TagSecurity}},
+		},
+	})
+
+	testutil.AssertEqual(t, "SRCH-ABS,SRCH-K8S,SRCH-D2", recommendationCodes(SearchSessions("", "KUBERNETES", nil)),
+		"Keyword should match titles and abstracts case-insensitively across both days, Aug.9 first")
+	testutil.AssertEqual(t, "SRCH-ABS,SRCH-K8S", recommendationCodes(SearchSessions("Aug.9", "kubernetes", nil)), "Day should restrict the search")
+	testutil.AssertEqual(t, "SRCH-SPK", recommendationCodes(SearchSessions("", "alice", nil)), "Speakers should be searched")
+	testutil.AssertEqual(t, "SRCH-D2", recommendationCodes(SearchSessions("", "kubernetes", []string{TagSecurity})), "Tags should narrow the keyword results")
+	testutil.AssertEqual(t, "SRCH-ABS,SRCH-K8S", recommendationCodes(SearchSessions("", "", []string{TagSystem})), "Tags alone should be enough")
+	testutil.AssertEqual(t, "", recommendationCodes(SearchSessions("", "", []string{"system"})), "Tags should match exactly")
+	testutil.AssertEqual(t, "", SearchSessions("", "kubernetes", nil)[1].Abstract, "Results should be simplified")
+}
+
+func TestFindSessionsBySpeaker(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "SPK-PANEL", Title: "Panel", Speakers: []string{"李四", "張三"}, Start: "14:00", End: "15:00", Room: "AU", Day: "Aug.9"},
+			{Code: "SPK-SOLO", Title: "Solo", Speakers: []string{"張三"}, Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "SPK-OTHER", Title: "Other", Speakers: []string{"王五"}, Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "SPK-DAY2", Title: "Day Two", Speakers: []string{"Alice Chang"}, Start: "09:00", End: "09:30", Room: "AU", Day: "Aug.10"},
+			{Code: "SPK-DAY2B", Title: "Day Two B", Speakers: []string{"張三豐"}, Start: "08:30", End: "09:00", Room: "AU", Day: "Aug.10"},
+		},
+	})
+
+	sessions := FindSessionsBySpeaker("張")
+	testutil.AssertEqual(t, "SPK-SOLO,SPK-PANEL,SPK-DAY2B", recommendationCodes(sessions), "Partial names should match across both days, Aug.9 first")
+	testutil.AssertEqual(t, "Aug.10", sessions[2].Day, "Results should carry their day")
+
+	testutil.AssertEqual(t, "SPK-DAY2", recommendationCodes(FindSessionsBySpeaker("alice")), "Matching should be case-insensitive")
+	testutil.AssertEqual(t, 0, len(FindSessionsBySpeaker("  ")), "Blank names should match nothing")
+}
+
+func TestAllSessionsPagedReassemblesDay(t *testing.T) {
+	var day []Session
+	for i := range 7 {
+		// Pairs share a start time so room order decides
+		day = append(day, Session{Code: fmt.Sprintf("PAGE-%d", i), Start: fmt.Sprintf("%02d:00", 10+i/2), End: fmt.Sprintf("%02d:30", 10+i/2), Room: []string{"TR211", "AU"}[i%2], Day: "Aug.9"})
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(day), func(i, j int) { day[i], day[j] = day[j], day[i] })
+	setTestSessions(t, map[string][]Session{"Aug.9": day})
+
+	full, total := AllSessionsPaged("Aug.9", 0, 100)
+	testutil.AssertEqual(t, 7, total, "Total should count the whole day")
+
+	var reassembled []Session
+	for offset := 0; offset < total; offset += 3 {
+		page, pageTotal := AllSessionsPaged("Aug.9", offset, 3)
+		testutil.AssertEqual(t, total, pageTotal, "Every page should report the same total")
+		reassembled = append(reassembled, page...)
+	}
+	testutil.AssertEqual(t, recommendationCodes(full), recommendationCodes(reassembled), "Pages should reassemble the day in order")
+	testutil.AssertEqual(t, "PAGE-1,PAGE-0,PAGE-3,PAGE-2,PAGE-5,PAGE-4,PAGE-6", recommendationCodes(full), "Sessions should sort by start time, then room")
+
+	again, _ := AllSessionsPaged("Aug.9", 0, 100)
+	testutil.AssertEqual(t, recommendationCodes(full), recommendationCodes(again), "Order should be stable between calls")
+
+	beyond, _ := AllSessionsPaged("Aug.9", 10, 3)
+	testutil.AssertEqual(t, 0, len(beyond), "Offsets past the end should give an empty page")
+}
+
+func TestFavoritesConflictingWithSchedule(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "FAV-PLANNED", Title: "Planned", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "FAV-CLASH", Title: "Clashing Favorite", Start: "10:15", End: "10:45", Room: "TR211", Day: "Aug.9"},
+			{Code: "FAV-FREE", Title: "Free Favorite", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"},
+			{Code: "FAV-GONE", Title: "Cancelled Slot", Start: "14:00", End: "14:30", Room: "AU", Day: "Aug.9"},
+			{Code: "FAV-OVER-GONE", Title: "Over Cancelled", Start: "14:00", End: "14:30", Room: "TR211", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "FAV-DAY2", Title: "Other Day", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.10"},
+		},
+	})
+	storeTestUserState(t, &UserState{
+		SessionID: "test_favorite_conflicts",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "FAV-PLANNED", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "FAV-GONE", Start: "14:00", End: "14:30", Room: "AU", Day: "Aug.9", Cancelled: true},
+		},
+	})
+
+	err := AddFavorites("test_favorite_conflicts", []string{"FAV-CLASH", "FAV-FREE", "FAV-DAY2", "FAV-OVER-GONE", "FAV-PLANNED", "FAV-CLASH"})
+	testutil.AssertNoError(t, err, "Known codes should be starred")
+	testutil.AssertEqual(t, 5, len(GetUserStateSnapshot("test_favorite_conflicts").Favorites), "Duplicates should be starred once")
+
+	conflicts := FavoritesConflictingWithSchedule("test_favorite_conflicts")
+	testutil.AssertEqual(t, "FAV-CLASH", recommendationCodes(conflicts), "Only the favorite overlapping a committed session should be reported")
+
+	err = AddFavorites("test_favorite_conflicts", []string{"FAV-FREE", "NOSUCHCODE"})
+	testutil.AssertEqual(t, true, errors.Is(err, ErrSessionNotFound), "Unknown codes should be rejected with ErrSessionNotFound")
+	testutil.AssertEqual(t, 5, len(GetUserStateSnapshot("test_favorite_conflicts").Favorites), "A rejected batch should change nothing")
+}
+
+// Conference date config tests
+
+func TestLoadCOSCUPConfigFromEnvShiftsConferenceWindow(t *testing.T) {
+	t.Setenv("COSCUP_YEAR", "2026")
+	t.Setenv("COSCUP_DAY1", "1")
+	t.Setenv("COSCUP_DAY2", "2")
+
+	config, err := LoadCOSCUPConfigFromEnv()
+	testutil.AssertNoError(t, err, "Valid dates should load")
+	testutil.AssertEqual(t, COSCUPConfig{Year: 2026, Month: time.August, Day1: 1, Day2: 2}, config, "Unset month should keep its default")
+
+	SetCOSCUPConfig(config)
+	testutil.SetConferenceDates(config.Year, config.Month, config.Day1, config.Day2)
+	t.Cleanup(func() {
+		SetCOSCUPConfig(DefaultCOSCUPConfig())
+		testutil.SetConferenceDates(COSCUPYear, COSCUPMonth, COSCUPDay1, COSCUPDay2)
+	})
+
+	testutil.AssertEqual(t, DayAug9, getCOSCUPDay(testutil.NewMockTimeProvider("10:00").Now()), "First configured day should map to Aug9")
+	testutil.AssertEqual(t, DayAug10, getCOSCUPDay(testutil.NewMockTimeProviderWithDay("10:00", "Aug10").Now()), "Second configured day should map to Aug10")
+	testutil.AssertEqual(t, false, isInCOSCUPPeriod(time.Date(2025, 8, 9, 10, 0, 0, 0, conferenceLocation())), "The 2025 dates should no longer be active")
+	testutil.AssertEqual(t, "2026-08-02", dayMidnight(DayFormatAug10).Format("2006-01-02"), "Session times should use the configured dates")
+	testutil.AssertEqual(t, ConferencePhaseAfter, conferencePhase(time.Date(2026, 8, 3, 0, 0, 0, 0, conferenceLocation())), "Phases should follow the configured dates")
+}
+
+func TestLoadCOSCUPConfigFromEnvRejectsInvalidDates(t *testing.T) {
+	config, err := LoadCOSCUPConfigFromEnv()
+	testutil.AssertNoError(t, err, "No overrides should load the defaults")
+	testutil.AssertEqual(t, DefaultCOSCUPConfig(), config, "Defaults should be COSCUP 2025")
+
+	for name, env := range map[string]map[string]string{
+		"not a number":   {"COSCUP_YEAR": "next"},
+		"impossible day": {"COSCUP_MONTH": "2", "COSCUP_DAY1": "30", "COSCUP_DAY2": "31"},
+		"days reversed":  {"COSCUP_DAY1": "10", "COSCUP_DAY2": "9"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+			_, err := LoadCOSCUPConfigFromEnv()
+			testutil.AssertEqual(t, true, errors.Is(err, ErrInvalidCOSCUPConfig), "Invalid dates should be rejected")
+		})
+	}
+}
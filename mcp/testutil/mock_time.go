@@ -2,6 +2,23 @@ package testutil
 
 import "time"
 
+// taipeiLocation mirrors the conference timezone so mocked wall-clock times match session times
+var taipeiLocation = time.FixedZone("Asia/Taipei", 8*60*60)
+
+// Conference dates the mock providers use for "Aug9" and "Aug10", COSCUP 2025 unless changed with SetConferenceDates
+var (
+	conferenceYear  = 2025
+	conferenceMonth = time.August
+	conferenceDay1  = 9
+	conferenceDay2  = 10
+)
+
+// SetConferenceDates makes the mock providers follow a different conference calendar,
+// e.g. to match a COSCUPConfig loaded from the environment
+func SetConferenceDates(year int, month time.Month, day1, day2 int) {
+	conferenceYear, conferenceMonth, conferenceDay1, conferenceDay2 = year, month, day1, day2
+}
+
 // MockTimeProvider implements TimeProvider for testing
 type MockTimeProvider struct {
 	fixedTime time.Time
@@ -12,17 +29,17 @@ func (m *MockTimeProvider) Now() time.Time {
 	return m.fixedTime
 }
 
-// NewMockTimeProvider creates a new mock time provider with Aug9 10:23
+// NewMockTimeProvider creates a new mock time provider on the first conference day (Aug9)
 func NewMockTimeProvider(timeStr string) *MockTimeProvider {
-	// Parse time string like "10:23" and create a Aug9 time
+	// Parse time string like "10:23" and create a first-day time
 	parsedTime, err := time.Parse("15:04", timeStr)
 	if err != nil {
 		// Default to 10:23 if parsing fails
 		parsedTime, _ = time.Parse("15:04", "10:23")
 	}
 
-	// Set to COSCUP 2025 Aug 9 with the specified time
-	fixedTime := time.Date(2025, 8, 9, parsedTime.Hour(), parsedTime.Minute(), 0, 0, time.UTC)
+	// Set to the first conference day with the specified Taipei wall-clock time
+	fixedTime := time.Date(conferenceYear, conferenceMonth, conferenceDay1, parsedTime.Hour(), parsedTime.Minute(), 0, 0, taipeiLocation)
 	return &MockTimeProvider{fixedTime: fixedTime}
 }
 
@@ -36,12 +53,12 @@ func NewMockTimeProviderWithDay(timeStr, day string) *MockTimeProvider {
 	var fixedTime time.Time
 	switch day {
 	case "Aug9":
-		fixedTime = time.Date(2025, 8, 9, parsedTime.Hour(), parsedTime.Minute(), 0, 0, time.UTC)
+		fixedTime = time.Date(conferenceYear, conferenceMonth, conferenceDay1, parsedTime.Hour(), parsedTime.Minute(), 0, 0, taipeiLocation)
 	case "Aug10":
-		fixedTime = time.Date(2025, 8, 10, parsedTime.Hour(), parsedTime.Minute(), 0, 0, time.UTC)
+		fixedTime = time.Date(conferenceYear, conferenceMonth, conferenceDay2, parsedTime.Hour(), parsedTime.Minute(), 0, 0, taipeiLocation)
 	default:
-		// Outside COSCUP period - use 2025/8/8 as example
-		fixedTime = time.Date(2025, 8, 8, parsedTime.Hour(), parsedTime.Minute(), 0, 0, time.UTC)
+		// Outside COSCUP period - use the day before the conference as example
+		fixedTime = time.Date(conferenceYear, conferenceMonth, conferenceDay1-1, parsedTime.Hour(), parsedTime.Minute(), 0, 0, taipeiLocation)
 	}
 
 	return &MockTimeProvider{fixedTime: fixedTime}
@@ -1,8 +1,14 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -14,19 +20,93 @@ const sessionIdWarning = `CRITICAL: Response includes sessionId that you MUST pr
 
 `
 
+// marshalResponse encodes a response as compact JSON for the tool boundary
+// HTML escaping is off so session URLs keep their '&' instead of turning into \u0026
+func marshalResponse(response Response) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(response); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// newToolResult formats a response as two text content items: the response encoded as JSON
+// for clients that parse structured data, followed by the message as a short human summary
+func newToolResult(response Response) *mcp.CallToolResult {
+	encoded, err := marshalResponse(response)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: failed to encode response: %s", err.Error()))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(encoded),
+			mcp.NewTextContent(response.Message),
+		},
+	}
+}
+
+// withVerbosity adds the shared verbosity argument to a tool
+func withVerbosity() mcp.ToolOption {
+	return mcp.WithString("verbosity",
+		mcp.Description("Response detail level: 'terse' for small screens (short message, no route or timeline details), 'normal' (default) or 'rich' (step-by-step directions and full session details)"),
+		mcp.Enum(string(VerbosityTerse), string(VerbosityNormal), string(VerbosityRich)),
+	)
+}
+
 // CreateMCPTools creates and returns all MCP tools using new helper functions
 func CreateMCPTools() map[string]mcp.Tool {
 	return map[string]mcp.Tool{
-		"start_planning":     createStartPlanningTool(),
-		"choose_session":     createChooseSessionTool(),
-		"get_options":        createGetOptionsTool(),
-		"get_schedule":       createGetScheduleTool(),
-		"get_next_session":   createGetNextSessionTool(),
-		"get_session_detail": createGetSessionDetailTool(),
-		"finish_planning":    createFinishPlanningTool(),
-		"get_room_schedule":  createGetRoomScheduleTool(),
-		"get_venue_map":      createGetVenueMapTool(),
-		"help":               createHelpTool(),
+		"start_planning":           createStartPlanningTool(),
+		"choose_session":           createChooseSessionTool(),
+		"get_options":              createGetOptionsTool(),
+		"get_schedule":             createGetScheduleTool(),
+		"get_next_session":         createGetNextSessionTool(),
+		"get_session_detail":       createGetSessionDetailTool(),
+		"finish_planning":          createFinishPlanningTool(),
+		"get_room_schedule":        createGetRoomScheduleTool(),
+		"get_venue_map":            createGetVenueMapTool(),
+		"help":                     createHelpTool(),
+		"get_walking_distance":     createGetWalkingDistanceTool(),
+		"get_ending_soon":          createGetEndingSoonTool(),
+		"get_arrival_advice":       createGetArrivalAdviceTool(),
+		"get_track_catalog":        createGetTrackCatalogTool(),
+		"get_balance_score":        createGetBalanceScoreTool(),
+		"lock_session":             createLockSessionTool(),
+		"optimize_schedule":        createOptimizeScheduleTool(),
+		"get_livestreamed_now":     createGetLivestreamedNowTool(),
+		"get_all_compatible":       createGetAllCompatibleTool(),
+		"resolve_schedule":         createResolveScheduleTool(),
+		"get_unsampled_tracks":     createGetUnsampledTracksTool(),
+		"compare_with_friend":      createCompareWithFriendTool(),
+		"get_floor_sessions":       createGetFloorSessionsTool(),
+		"get_missed_sessions":      createGetMissedSessionsTool(),
+		"preview_token":            createPreviewTokenTool(),
+		"get_all_rooms":            createGetAllRoomsTool(),
+		"suggest_lunch":            createSuggestLunchTool(),
+		"get_sessions_by_all_tags": createGetSessionsByAllTagsTool(),
+		"set_mobility":             createSetMobilityTool(),
+		"find_free_block":          createFindFreeBlockTool(),
+		"get_next_anywhere":        createGetNextAnywhereTool(),
+		"fix_transfers":            createFixTransfersTool(),
+		"import_ics":               createImportICSTool(),
+		"get_starter_plan":         createGetStarterPlanTool(),
+		"get_ongoing_for_me":       createGetOngoingForMeTool(),
+		"get_crowding":             createGetCrowdingTool(),
+		"review_plan":              createReviewPlanTool(),
+		"get_remaining_time":       createGetRemainingTimeTool(),
+		"get_sessions_by_tags":     createGetSessionsByTagsTool(),
+		"off_campus_break":         createOffCampusBreakTool(),
+		"search_sessions":          createSearchSessionsTool(),
+		"get_speaker_sessions":     createGetSpeakerSessionsTool(),
+		"get_all_sessions":         createGetAllSessionsTool(),
+		"check_favorites":          createCheckFavoritesTool(),
+		"summarize_remaining_day":  createSummarizeRemainingDayTool(),
+		"get_free_slots":           createGetFreeSlotsTool(),
+		"get_now":                  createGetNowTool(),
+		"recreate_session":         createRecreateSessionTool(),
 	}
 }
 
@@ -39,6 +119,12 @@ func createStartPlanningTool() mcp.Tool {
 			mcp.Description("The day to plan schedule for. Must be 'Aug9' or 'Aug10'"),
 			mcp.Enum(DayAug9, DayAug10),
 		),
+		mcp.WithString("auto_finish",
+			mcp.Description("Set to 'true' only if the user asks to have planning finished automatically once no real sessions are left and they decline to plan more"),
+		),
+		mcp.WithNumber("grace_minutes",
+			mcp.Description(fmt.Sprintf("When planning today mid-conference, sessions that started at most this many minutes ago are still offered (default %d)", DefaultStartGraceMinutes)),
+		),
 	)
 }
 
@@ -49,30 +135,49 @@ func handleStartPlanning(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	}
 
 	// Generate a secure session ID
-	dayCode := map[string]string{DayAug9: "09", DayAug10: "10"}[day]
-	sessionID := GenerateSessionIDWithCollisionCheck(dayCode)
+	sessionID := GenerateSessionIDWithCollisionCheck(sessionIDDayCodes[day])
 
 	// Convert day format and create new user state
-	internalDay := convertDayFormat(day)
+	internalDay, err := parseDayFormat(day)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	}
 	CreateUserState(sessionID, internalDay)
 
-	// Get first sessions of the day
-	firstSessions := GetFirstSession(internalDay)
+	autoFinish := request.GetString("auto_finish", "") == "true"
+	if autoFinish {
+		_ = SetAutoFinish(sessionID, true)
+	}
+
+	// Get first sessions of the day, or what is starting now when planning today
+	currentTime := ""
+	timeProvider := &RealTimeProvider{}
+	if now := timeProvider.Now(); getCOSCUPDay(now) == day {
+		currentTime = formatTimeForSession(now)
+	}
+	graceMinutes := request.GetInt("grace_minutes", DefaultStartGraceMinutes)
+	firstSessions := GetStartOptions(internalDay, currentTime, graceMinutes)
 	if len(firstSessions) == 0 {
-		return mcp.NewToolResultError(fmt.Sprintf("Error: no session data found for %s", internalDay)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Error: no session data found for %s", toUserDayFormat(internalDay))), nil
 	}
 
 	data := map[string]any{
-		"day":     internalDay,
+		"day":     toUserDayFormat(internalDay),
 		"options": firstSessions,
 	}
+	if autoFinish {
+		data["auto_finish"] = true
+	}
+	if currentTime != "" {
+		data["current_time"] = currentTime
+	}
 
 	message := fmt.Sprintf("Started planning schedule for %s, session ID: %s. Please show these %d sessions grouped by topic tags. For each session, show basic info (code, title, time, room, speaker, difficulty). Remind users they can ask for details about any session by providing the session code.",
-		internalDay, sessionID, len(firstSessions))
+		toUserDayFormat(internalDay), sessionID, len(firstSessions))
 
 	response := buildStandardResponse(sessionID, data, message)
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 // 2. Choose Session Tool - using new API
@@ -100,15 +205,13 @@ func handleChooseSession(ctx context.Context, request mcp.CallToolRequest) (*mcp
 		return mcp.NewToolResultError(ErrSessionCodeRequired.Error()), nil
 	}
 
-	// Add session to user's schedule
-	if err = AddSessionToSchedule(sessionID, sessionCode); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	// Add session to user's schedule; a repeated talk may be added in another timeslot
+	selectedSession, err := ScheduleSession(sessionID, sessionCode)
+	if errors.Is(err, ErrSessionNotFound) {
+		return mcp.NewToolResultError(unknownPlanningSessionError(sessionID)), nil
 	}
-
-	// Get selected session details
-	selectedSession := FindSessionByCode(sessionCode)
-	if selectedSession == nil {
-		return mcp.NewToolResultError("Error: cannot find details of selected session"), nil
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
 	}
 
 	// Get next recommendations
@@ -125,28 +228,66 @@ func handleChooseSession(ctx context.Context, request mcp.CallToolRequest) (*mcp
 			nextMessage = "No more sessions available to choose from at this time."
 		}
 	} else {
-		nextMessage = fmt.Sprintf("Selection recorded! You have %d available sessions to choose from. COUNT VERIFICATION: You must display exactly %d sessions - verify this count. Do NOT use ellipsis (...) or 'and X more sessions' or any abbreviation. Group sessions by their tags but show EVERY SINGLE session with code, title, time, room, speaker, and URL. Show URLs as clickable links. Users can request detailed information for any session by providing its code.", len(recommendations), len(recommendations))
+		nextMessage = fmt.Sprintf("Selection recorded! You have %d available sessions to choose from. COUNT VERIFICATION: You must display exactly %d sessions - verify this count. Do NOT use ellipsis (...) or 'and X more sessions' or any abbreviation. Group sessions by their tags but show EVERY SINGLE session with code, title, time, room, speaker, language, and URL. Mark each session's language clearly (e.g. [漢語] or [英語]) and warn the user before they pick a talk in a language they may not understand. Show URLs as clickable links. Options are ranked by how well they match the user's interests; highlight the sessions listed in recommended as top picks. Users can request detailed information for any session by providing its code.", len(recommendations), len(recommendations))
 	}
 
 	data := map[string]any{
 		"selected_session": selectedSession,
 		"next_options":     recommendations,
+		"languages":        countSessionLanguages(recommendations),
 		"is_complete":      IsScheduleComplete(sessionID),
 	}
+	if state := GetUserStateSnapshot(sessionID); state != nil {
+		data["recommended"], data["scores"] = RecommendedCodes(recommendations, state)
+	}
+
+	// The add is never blocked, but moves that cannot be walked in time are flagged
+	if transfers := FindImpossibleTransfers(sessionID); len(transfers) > 0 {
+		data["impossible_transfers"] = transfers
+		nextMessage = strings.TrimSpace(impossibleTransfersWarning(transfers)) + " " + nextMessage
+	}
+
+	if selectedSession.Code != sessionCode {
+		data["requested_code"] = sessionCode
+		nextMessage = fmt.Sprintf("The requested session %s conflicts with the schedule, so the same talk in another timeslot was added instead: %s %s-%s in %s. Tell the user about this switch first. ",
+			sessionCode, selectedSession.Code, selectedSession.Start, selectedSession.End, selectedSession.Room) + nextMessage
+	}
 
 	response := buildStandardResponse(sessionID, data, nextMessage)
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
+}
+
+// unknownPlanningSessionError explains a sessionId with no planning state: a well-formed ID may have expired,
+// anything else was never issued by start_planning
+func unknownPlanningSessionError(sessionID string) string {
+	if _, err := ParseDayFromSessionID(sessionID); err != nil {
+		return fmt.Sprintf("Error: %s is not a planning session ID. No plan has been started yet - call start_planning first to get a sessionId, then choose sessions with it.", sessionID)
+	}
+	return fmt.Sprintf("Error: planning session %s was not found. If the user has not started planning yet, call start_planning first; if they planned earlier and the session expired, use recreate_session to start over for the same day.", sessionID)
 }
 
 // 3. Get Options Tool - using new API
 func createGetOptionsTool() mcp.Tool {
 	return mcp.NewTool(
 		"get_options",
-		mcp.WithDescription(sessionIdWarning+"**CONTINUATION PLANNING TOOL** - Use when user wants to continue/resume schedule planning and select additional sessions.\n\nPRIMARY USE CASES:\n- User wants to continue planning after partial schedule: '繼續選擇議程', 'continue selecting', 'keep planning', '我想要繼續選擇'\n- User finished other activities and wants to resume planning\n- User asks for more session options: '更多選項', 'what else can I choose', '還有什麼可以選'\n- User wants to extend current schedule: 'what's next to add', '下一個時段', '接下來可以選什麼'\n\nThis tool finds sessions that start AFTER user's current schedule end time. Show sessions grouped by topic tags. Include basic info for technical sessions, simplified info for social/long sessions. Remind users they can ask for session details by providing the session code. Display all sessions returned. Use user's preferred language."),
+		mcp.WithDescription(sessionIdWarning+"**CONTINUATION PLANNING TOOL** - Use when user wants to continue/resume schedule planning and select additional sessions.\n\nPRIMARY USE CASES:\n- User wants to continue planning after partial schedule: '繼續選擇議程', 'continue selecting', 'keep planning', '我想要繼續選擇'\n- User finished other activities and wants to resume planning\n- User asks for more session options: '更多選項', 'what else can I choose', '還有什麼可以選'\n- User wants to extend current schedule: 'what's next to add', '下一個時段', '接下來可以選什麼'\n\nThis tool finds sessions that start AFTER user's current schedule end time. Show sessions grouped by topic tags. Include basic info for technical sessions, simplified info for social/long sessions. Remind users they can ask for session details by providing the session code. Display all sessions returned. Use user's preferred language.\n\nSet sameBuildingOnly='true' when user wants to avoid moving between buildings: '不想換大樓', 'stay in this building', '同一棟就好'.\n\nSet surprise='true' when user wants to step outside their usual interests: 'surprise me', '給我一點驚喜', '推薦我平常不會選的'."),
 		mcp.WithString("sessionId",
 			mcp.Description("User's session ID"),
 		),
+		mcp.WithString("sameBuildingOnly",
+			mcp.Description("Set to 'true' to only show sessions in the building of user's last scheduled session"),
+		),
+		mcp.WithString("surprise",
+			mcp.Description("Set to 'true' to order options by how different they are from the user's previous picks"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of options to skip, for paging through a long list. Optional - defaults to 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of options to return. Optional - all options are returned when omitted; set it (e.g. 8) when many rooms have a next session and the list would be hard to read"),
+		),
+		withVerbosity(),
 	)
 }
 
@@ -156,42 +297,139 @@ func handleGetOptions(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
 	}
 
-	state := GetUserState(sessionID)
+	state := GetUserStateSnapshot(sessionID)
 	if state == nil {
 		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
 	}
 
-	recommendations, err := GetRecommendations(sessionID)
+	sameBuildingOnly := request.GetString("sameBuildingOnly", "") == "true"
+	surprise := request.GetString("surprise", "") == "true"
+	verbosity := parseVerbosity(request.GetString("verbosity", ""))
+
+	offset := request.GetInt("offset", 0)
+	limit := request.GetInt("limit", 0)
+	if offset < 0 || limit < 0 {
+		return mcp.NewToolResultError("Error: offset and limit must not be negative"), nil
+	}
+
+	var recommendations []Session
+	var building, emptyReason string
+	if sameBuildingOnly {
+		recommendations, building, err = GetRecommendationsInSameBuilding(sessionID)
+	} else {
+		recommendations, emptyReason, err = GetRecommendationsWithReason(sessionID)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
 	}
+	// The same-building filter falls back to all rooms, so it is only empty when the full list is
+	if len(recommendations) == 0 && sameBuildingOnly {
+		_, emptyReason, _ = GetRecommendationsWithReason(sessionID)
+	}
+
+	if surprise {
+		if snapshot := GetUserStateSnapshot(sessionID); snapshot != nil {
+			orderBySurprise(recommendations, snapshot)
+		}
+	}
+
+	// Page after ordering, which is deterministic per user, so consecutive pages never overlap
+	totalOptions := len(recommendations)
+	if offset > 0 && offset >= totalOptions && totalOptions > 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: offset %d is past the last option (%d options in total)", offset, totalOptions)), nil
+	}
+	recommendations = pageSessions(recommendations, offset, limit)
+	hasMore := offset+len(recommendations) < totalOptions
 
 	var message string
 	if len(recommendations) == 0 {
-		message = "No sessions currently available to choose from. May have completed today's planning or no more suitable timeslots available."
+		switch emptyReason {
+		case EmptyReasonNoLaterSessions:
+			message = "No sessions currently available to choose from. No sessions start after the user's last scheduled session, so today's planning is effectively complete."
+		case EmptyReasonAllConflict:
+			message = "No sessions currently available to choose from. Later sessions exist, but every one of them conflicts with the user's current schedule. Suggest removing or swapping a scheduled session to free up time."
+		case EmptyReasonOnlySocial:
+			message = "No sessions currently available to choose from. Only long social activities (e.g. Hacking Corner) remain, and they are not recommended as regular options. The user can still drop by freely."
+		default:
+			message = "No sessions currently available to choose from. May have completed today's planning or no more suitable timeslots available."
+		}
 	} else {
-		message = fmt.Sprintf("Found %d available sessions for your next timeslot. COUNT VERIFICATION: You must display exactly %d sessions - verify this count. Do NOT use ellipsis (...) or 'and X more sessions' or any abbreviation. Group sessions by their tags but show EVERY SINGLE session with code, title, time, room, speaker, and URL. Show URLs as clickable links. Based on the user's previous selections, try to highlight sessions that might interest them. Users can request detailed information for any session by providing its code.", len(recommendations), len(recommendations))
+		message = fmt.Sprintf("Found %d available sessions for your next timeslot. COUNT VERIFICATION: You must display exactly %d sessions - verify this count. Do NOT use ellipsis (...) or 'and X more sessions' or any abbreviation. Group sessions by their tags but show EVERY SINGLE session with code, title, time, room, speaker, language, and URL. Mark each session's language clearly (e.g. [漢語] or [英語]) and warn the user before they pick a talk in a language they may not understand. Show URLs as clickable links. Options are ranked by how well they match the user's interests; highlight the sessions listed in recommended as top picks. Users can request detailed information for any session by providing its code.", len(recommendations), len(recommendations))
+		if sameBuildingOnly {
+			if building != "" {
+				message += fmt.Sprintf(" All options are in building %s, so the user does not need to change buildings.", building)
+			} else {
+				message += " No options were found in the user's current building, so options from all buildings are shown. Tell the user they will need to move to another building."
+			}
+		}
+		if surprise {
+			message += " SURPRISE MODE: options are ordered from least to most similar to the user's previous picks. Instead of highlighting familiar topics, encourage the user to try the first few unexpected sessions."
+		}
+		if verbosity == VerbosityTerse {
+			message = fmt.Sprintf("Found %d available sessions. Keep it short: list only code, title, time and room for each.", len(recommendations))
+		}
+		if hasMore {
+			message += fmt.Sprintf(" PAGINATION: these are options %d-%d of %d. After showing them, tell the user there are %d more and call get_options again with offset=%d if they want to see them.",
+				offset+1, offset+len(recommendations), totalOptions, totalOptions-offset-len(recommendations), offset+len(recommendations))
+		}
+	}
+
+	// Rich mode returns the full session details, including abstracts
+	if verbosity == VerbosityRich {
+		for i, option := range recommendations {
+			if full := FindSessionByCode(option.Code); full != nil {
+				recommendations[i] = *full
+			}
+		}
 	}
 
+	recommended, scores := RecommendedCodes(recommendations, state)
 	data := map[string]any{
 		"options":                recommendations,
+		"languages":              countSessionLanguages(recommendations),
+		"recommended":            recommended,
+		"scores":                 scores,
 		"last_end_time":          state.LastEndTime,
 		"current_schedule_count": len(state.Schedule),
+		"total_options":          totalOptions,
+		"has_more":               hasMore,
+	}
+	if hasMore {
+		data["next_offset"] = offset + len(recommendations)
+	}
+	if sameBuildingOnly {
+		data["same_building_only"] = true
+		data["building"] = building
+	}
+	if surprise {
+		data["surprise"] = true
 	}
+	if emptyReason != "" {
+		data["reason"] = emptyReason
+	}
+	data["verbosity"] = string(verbosity)
 
 	response := buildStandardResponse(sessionID, data, message)
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 // 4. Get Schedule Tool - using new API
 func createGetScheduleTool() mcp.Tool {
 	return mcp.NewTool(
 		"get_schedule",
-		mcp.WithDescription(sessionIdWarning+"Get user's complete planned schedule timeline for a specific day. Use this tool when user wants to view their current planned agenda, check their complete schedule, or review their selected sessions in chronological order. Returns a well-formatted timeline view with session details, time gaps, and schedule statistics."),
+		mcp.WithDescription(sessionIdWarning+"Get user's complete planned schedule timeline for a specific day. Use this tool when user wants to view their current planned agenda, check their complete schedule, or review their selected sessions in chronological order. Returns a well-formatted timeline view with session details, time gaps, and schedule statistics. Use sortBy='room' when user thinks in terms of venues, e.g. '依場地排列', 'group by room', '我在每個教室要聽哪些'. Use sortBy='moves' when user wants to know when they have to change rooms, e.g. '我什麼時候要換教室', 'when do I need to move'."),
 		mcp.WithString("sessionId",
 			mcp.Description("User's session ID"),
 		),
+		mcp.WithString("sortBy",
+			mcp.Description("Timeline grouping: 'time' (default, chronological), 'room' (grouped by room, chronological within each room) or 'moves' (chronological, highlighting every room change and its walking time)"),
+			mcp.Enum("time", "room", "moves"),
+		),
+		mcp.WithString("include_ics",
+			mcp.Description("Set to 'true' to also return the schedule as an iCalendar (.ics) file for calendar apps"),
+		),
+		withVerbosity(),
 	)
 }
 
@@ -213,12 +451,14 @@ The tool automatically analyzes current status:
 - 🎯 Ongoing session: Shows remaining time, previews next session
 - ⏰ Break time: Provides movement suggestions and time planning
 - ✅ Just ended: Immediate next venue location and optimal route
+- 🍱 Lunch break: A long midday gap, with places to eat and optional sessions to catch; don't rush the user
 
 Respond like a helpful assistant, proactively providing travel time, route guidance, and schedule planning advice.
 If user hasn't planned their schedule yet, guide them to use start_planning to begin.`),
 		mcp.WithString("sessionId",
 			mcp.Description("User's session ID"),
 		),
+		withVerbosity(),
 	)
 }
 
@@ -230,6 +470,9 @@ func createGetSessionDetailTool() mcp.Tool {
 		mcp.WithString("sessionCode",
 			mcp.Description("The session code to get details for"),
 		),
+		mcp.WithString("split_outline",
+			mcp.Description("Set to 'true' to also return the abstract split into a prose summary and a bulleted outline, when the abstract contains a list"),
+		),
 	)
 }
 
@@ -261,6 +504,9 @@ func createGetRoomScheduleTool() mcp.Tool {
 		mcp.WithString("current_only",
 			mcp.Description("Set to 'true' to return only the currently running session"),
 		),
+		mcp.WithString("include_detail",
+			mcp.Description(fmt.Sprintf("Set to 'true' to include full session details (abstracts) in the returned sessions. Responses get much larger, so at most %d sessions are returned in this mode; only use it when the user wants to know what the talks are about", MaxDetailedRoomSessions)),
+		),
 	)
 }
 
@@ -268,7 +514,10 @@ func createGetRoomScheduleTool() mcp.Tool {
 func createGetVenueMapTool() mcp.Tool {
 	return mcp.NewTool(
 		"get_venue_map",
-		mcp.WithDescription("Get venue map and navigation information. Use this tool when user asks about directions, venue locations, how to get around campus, or needs visual map guidance. Returns official COSCUP venue map URL with building layouts and navigation details."),
+		mcp.WithDescription("Get venue map and navigation information. Use this tool when user asks about directions, venue locations, how to get around campus, which rooms are in a building, or needs visual map guidance. Returns official COSCUP venue map URL with building layouts, navigation details and the rooms with sessions in each building."),
+		mcp.WithString("day",
+			mcp.Description("Day whose rooms to list ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
 	)
 }
 
@@ -276,39 +525,210 @@ func createGetVenueMapTool() mcp.Tool {
 func createHelpTool() mcp.Tool {
 	return mcp.NewTool(
 		"help",
-		mcp.WithDescription("Get user-friendly help about COSCUP planning operations and usage examples. Use this tool when user asks for help, wants to know what they can do, or needs usage guidance. Provides practical examples and operation categories rather than technical tool lists."),
+		mcp.WithDescription("Get user-friendly help about COSCUP planning operations and usage examples. Use this tool when user asks for help, wants to know what they can do, or needs usage guidance. Provides practical examples and operation categories rather than technical tool lists. Set language='en' for users writing in English."),
+		mcp.WithString("language",
+			mcp.Description("Language of the guide: 'zh' (default, Traditional Chinese) or 'en' (English)"),
+			mcp.Enum(HelpLanguageZH, HelpLanguageEN),
+		),
+	)
+}
+
+// 11. Get Walking Distance Tool
+func createGetWalkingDistanceTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_walking_distance",
+		mcp.WithDescription(sessionIdWarning+"Estimate the total walking distance (in meters) for user's planned schedule, summed across every room change in chronological order. Use when user asks 'how much will I walk today', '今天要走多遠', or wants a fun stat about their day. Present the distance in a light, motivating tone and mention it's a rough estimate."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetWalkingDistance(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	totalMeters := EstimateWalkingDistance(sessionID)
+
+	data := map[string]any{
+		"day":            toUserDayFormat(state.Day),
+		"schedule_count": len(state.Schedule),
+		"total_meters":   totalMeters,
+	}
+
+	message := fmt.Sprintf("用戶今天的行程預估共需步行約 %d 公尺（%d 個議程之間的移動總和，僅為粗略估計）。請以用戶偏好語言、輕鬆鼓勵的語氣分享這個數字。",
+		totalMeters, len(state.Schedule))
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 12. Recreate Session Tool
+func createRecreateSessionTool() mcp.Tool {
+	return mcp.NewTool(
+		"recreate_session",
+		mcp.WithDescription("Recover from an expired or lost session ID. Use this tool when another tool reports the user's session cannot be found (e.g. after a server restart or long inactivity). The planning day is recovered from the old session ID and a fresh session is started for that day. Tell the user clearly that their previous selections were lost, give them the NEW sessionId, and help them re-select sessions starting from the returned options."),
+		mcp.WithString("sessionId",
+			mcp.Description("The expired session ID the user previously had"),
+		),
 	)
 }
 
+func handleRecreateSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	oldSessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	// Nothing to recover if the session is still alive
+	if state := GetUserStateSnapshot(oldSessionID); state != nil {
+		data := map[string]any{
+			"day":            toUserDayFormat(state.Day),
+			"schedule_count": len(state.Schedule),
+			"recreated":      false,
+		}
+		message := fmt.Sprintf("Session %s is still active with %d selected sessions. No need to recreate it - keep using this sessionId.", oldSessionID, len(state.Schedule))
+		return newToolResult(buildStandardResponse(oldSessionID, data, message)), nil
+	}
+
+	day, err := ParseDayFromSessionID(oldSessionID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s is not a valid COSCUP session ID. Please use start_planning to start a new plan.", oldSessionID)), nil
+	}
+
+	internalDay, err := parseDayFormat(day)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	}
+	sessionID := GenerateSessionIDWithCollisionCheck(sessionIDDayCodes[day])
+	CreateUserState(sessionID, internalDay)
+
+	firstSessions := GetFirstSession(internalDay)
+
+	data := map[string]any{
+		"day":              toUserDayFormat(internalDay),
+		"previous_session": oldSessionID,
+		"recreated":        true,
+		"options":          firstSessions,
+	}
+
+	message := fmt.Sprintf("The previous session %s has expired and its selections were lost. A new session %s was started for %s. Apologize briefly, give the user the NEW sessionId, and help them re-select sessions starting from these %d options.",
+		oldSessionID, sessionID, toUserDayFormat(internalDay), len(firstSessions))
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
 func handleGetSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	sessionID, err := request.RequireString("sessionId")
 	if err != nil {
 		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
 	}
 
-	state := GetUserState(sessionID)
+	state := GetUserStateSnapshot(sessionID)
 	if state == nil {
 		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
 	}
 
+	// Nothing selected yet: guide the user instead of reporting empty statistics
+	if len(state.Schedule) == 0 {
+		data := map[string]any{
+			"day":            toUserDayFormat(state.Day),
+			"schedule":       state.Schedule,
+			"schedule_count": 0,
+			"is_empty":       true,
+		}
+		message := fmt.Sprintf("您在 %s 還沒有選擇任何議程。可以使用 get_options 查看目前可選的議程開始安排；如果想規劃另一天，請使用 start_planning。請以用戶偏好語言友善地引導用戶開始選課，不要提及統計數字。", toUserDayFormat(state.Day))
+		return newToolResult(buildStandardResponse(sessionID, data, message)), nil
+	}
+
+	sortBy := request.GetString("sortBy", "time")
+	verbosity := parseVerbosity(request.GetString("verbosity", ""))
+
 	// Generate timeline format
-	timeline := generateTimelineView(state)
+	var timeline string
+	switch sortBy {
+	case "room":
+		timeline = generateRoomTimelineView(state)
+	case "moves":
+		timeline = generateMovesTimelineView(state)
+	default:
+		sortBy = "time"
+		timeline = generateTimelineView(state)
+	}
 
 	data := map[string]any{
-		"day":            state.Day,
+		"sort_by":        sortBy,
+		"day":            toUserDayFormat(state.Day),
 		"schedule":       state.Schedule,
 		"schedule_count": len(state.Schedule),
 		"last_end_time":  state.LastEndTime,
 		"is_complete":    IsScheduleComplete(sessionID),
 		"timeline_view":  timeline,
 	}
+	if sortBy == "moves" {
+		data["timeline_entries"] = buildTimelineEntries(state)
+	}
 
 	message := fmt.Sprintf("完整議程時間軸已生成。用戶已選擇 %d 個 session，最後結束時間 %s。請以用戶偏好語言呈現時間軸格式的議程安排。",
 		len(state.Schedule), state.LastEndTime)
 
+	// Shareable token a friend can check with preview_token
+	data["share_token"] = EncodeScheduleToken(state.Day, state.Schedule)
+
+	if request.GetString("include_ics", "") == "true" {
+		data["ics"] = ExportScheduleICS(state.Day, state.Schedule)
+		message += " The schedule is also attached as an iCalendar file in 'ics'; offer it for the user to save as coscup.ics and import into their calendar app."
+	}
+
+	// Pre-computed alternatives keyed by session code, so users have a plan B during the event
+	backups := make(map[string]Session)
+	for _, session := range state.Schedule {
+		if backup := SuggestBackup(session, state.Day, state.Schedule, state.Mobility); backup != nil {
+			backups[session.Code] = *backup
+		}
+	}
+	data["backups"] = backups
+
+	tightTransfers, totalTransfers := AssessPlanDensity(sessionID)
+	data["tight_transfers"] = tightTransfers
+	data["total_transfers"] = totalTransfers
+	message += planDensityWarning(tightTransfers, totalTransfers)
+
+	if transfers := FindImpossibleTransfers(sessionID); len(transfers) > 0 {
+		data["impossible_transfers"] = transfers
+		message += impossibleTransfersWarning(transfers)
+	}
+
+	if cancelled := countCancelledSessions(state.Schedule); cancelled > 0 {
+		data["cancelled_count"] = cancelled
+		message += fmt.Sprintf(" 注意：其中 %d 個議程已從官方議程表移除（標記為已取消），請提醒用戶並協助尋找替代議程。", cancelled)
+	}
+
+	switch verbosity {
+	case VerbosityTerse:
+		delete(data, "timeline_view")
+		delete(data, "backups")
+		message = fmt.Sprintf("共 %d 場議程，%s 結束。請只列出每場的時間、教室與標題。", len(state.Schedule), state.LastEndTime)
+	case VerbosityRich:
+		if statistics, err := GetScheduleStatistics(sessionID); err == nil {
+			data["statistics"] = statistics
+		}
+	}
+	data["verbosity"] = string(verbosity)
+
 	response := buildStandardResponse(sessionID, data, message)
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 func handleGetNextSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -317,8 +737,10 @@ func handleGetNextSession(ctx context.Context, request mcp.CallToolRequest) (*mc
 		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
 	}
 
+	verbosity := parseVerbosity(request.GetString("verbosity", ""))
+
 	// Get next session information
-	nextInfo, err := GetNextSession(sessionID)
+	nextInfo, err := GetNextSessionWithVerbosity(sessionID, &RealTimeProvider{}, verbosity)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
 	}
@@ -332,35 +754,31 @@ func handleGetNextSession(ctx context.Context, request mcp.CallToolRequest) (*mc
 		Message: nextInfo["message"].(string),
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 func handleGetVenueMap(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
 
+	venue := GetVenueInfo()
 	data := map[string]any{
-		"venue_map_url": "https://coscup.org/2025/venue/",
-		"map_features": []string{
-			"Interactive campus map",
-			"Building locations and layouts",
-			"Room numbers and capacity",
-			"Parking areas and entrances",
-			"Accessible routes and facilities",
-			"Food courts and rest areas",
-		},
-		"buildings": map[string]string{
-			"AU": "視聽館 (Audio-Visual Hall)",
-			"RB": "綜合研究大樓 (Research Building)",
-			"TR": "研揚大樓 (TR Building)",
-		},
-		"navigation_tips": []string{
-			"Use building codes (AU, RB, TR) to identify locations",
-			"Check room numbers - first digits indicate floor",
-			"Follow directional signs throughout campus",
-			"Ask volunteers wearing COSCUP shirts for assistance",
-		},
+		"venue_map_url":     venue.MapURL,
+		"map_features":      venue.MapFeatures,
+		"buildings":         VenueBuildingLabels(venue.Buildings),
+		"building_details":  venue.Buildings,
+		"navigation_tips":   venue.NavigationTips,
+		"day":               toUserDayFormat(internalDay),
+		"rooms_by_building": GetRoomsByBuilding(internalDay),
 	}
 
-	message := "Official COSCUP 2025 venue map available at https://coscup.org/2025/venue/ - provides interactive campus layout, building details, and navigation guidance. Show this URL to the user and explain they can view detailed maps, room locations, and accessibility information."
+	message := fmt.Sprintf("Official COSCUP 2025 venue map available at %s - provides interactive campus layout, building details, and navigation guidance. Show this URL to the user and explain they can view detailed maps, room locations, and accessibility information. buildings maps building codes to names and building_details lists each building's floors and facilities. rooms_by_building lists the rooms with sessions on %s in each building; use it to answer which rooms are in a building.", venue.MapURL, toUserDayFormat(internalDay))
 
 	response := Response{
 		Success: true,
@@ -368,12 +786,18 @@ func handleGetVenueMap(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		Message: message,
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
-func handleHelp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// Help guide languages
+const (
+	HelpLanguageZH = "zh"
+	HelpLanguageEN = "en"
+)
 
-	helpContent := `🎯 COSCUP 議程規劃助手使用指南
+// helpGuides holds the help guide per language, with %s where the usage examples are inserted
+var helpGuides = map[string]string{
+	HelpLanguageZH: `🎯 COSCUP 議程規劃助手使用指南
 
 我可以幫您安排 COSCUP 2025 的議程，支援以下操作：
 
@@ -402,28 +826,108 @@ func handleHelp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
    • 建築資訊：查看建築物位置和設施資訊
    • 路線指引：尋找路線和無障礙通道
 
-💡 使用範例：
-   "幫我安排 Aug.9 COSCUP 的行程" 或 "幫我安排 Aug.10 COSCUP 的行程"
-   "我想參加 AI 相關的議程"
-   "現在下一場在哪裡？"
-   "查看我今天的完整行程"
-   "JPADKC 這個議程的詳細內容是什麼？"
-   "TR211 下一場是什麼？"
-   "RB-105 現在在講什麼？"
-   "我要怎麼去 RB 大樓？"
-   "場地地圖在哪裡？"
-   "我覺得規劃夠了，結束規劃"
-
-✨ 特色功能：
+%s✨ 特色功能：
    • 時間管理：自動檢查時間衝突，確保行程合理
    • 路線計算：計算場館間移動時間和路線描述
    • 即時狀態：知道現在該做什麼、去哪裡
    • 中英文支援：可以用中文或英文與我互動
    • 豐富標籤：包含 🧠 AI、🔒 Security、🗣️ Languages 等 25+ 分類
 
-隨時說 "help" 或 "幫助" 都可以再次查看此說明！`
+隨時說 "help" 或 "幫助" 都可以再次查看此說明！`,
+	HelpLanguageEN: `🎯 COSCUP Planning Assistant Guide
+
+I can help you plan your COSCUP 2025 sessions. Here is what I can do:
+
+📅 Plan your day
+   • Start planning: tell me which day you want to plan (Aug 9 or Aug 10)
+   • Pick sessions: choose the talks you like from the recommendations
+   • Next timeslot: I avoid time conflicts and suggest the next available sessions
+
+🗓️ Manage your schedule
+   • View schedule: see your full timeline
+   • Session details: get the full information of a session
+   • Finish planning: wrap up whenever you're happy with your schedule
+
+🧭 On the day
+   • Live guidance: ask "what's next" or "where should I go"
+   • Moving around: get walking routes between buildings
+   • Time planning: see how much time is left and how long the walk takes
+
+🏢 Room schedules
+   • Specific room: see what's scheduled in a room
+   • Current / next: find out what's on in a room now or next
+   • Full day: view a room's whole timetable
+
+🗺️ Venue map and directions
+   • Official map: get the official venue map link
+   • Buildings: see where buildings are and which rooms they have
+   • Routes: find your way around, including accessible paths
+
+%s✨ Highlights:
+   • Time management: conflicts are checked automatically so your plan always works
+   • Routing: walking times and route descriptions between buildings
+   • Live status: know what to do and where to go right now
+   • Bilingual: talk to me in English or Chinese
+   • Rich tags: 25+ categories such as 🧠 AI, 🔒 Security and 🗣️ Languages
+
+Say "help" any time to see this guide again!`,
+}
+
+// helpExampleHeadings titles the usage examples section per language
+var helpExampleHeadings = map[string]string{
+	HelpLanguageZH: "💡 使用範例：",
+	HelpLanguageEN: "💡 Examples:",
+}
+
+// helpExamples lists the example requests shown in the help guide per language
+// Edit these to change the examples without touching the guide text
+var helpExamples = map[string][]string{
+	HelpLanguageZH: {
+		"幫我安排 Aug.9 COSCUP 的行程",
+		"幫我安排 Aug.10 COSCUP 的行程",
+		"我想參加 AI 相關的議程",
+		"現在下一場在哪裡？",
+		"查看我今天的完整行程",
+		"JPADKC 這個議程的詳細內容是什麼？",
+		"TR211 下一場是什麼？",
+		"RB-105 現在在講什麼？",
+		"我要怎麼去 RB 大樓？",
+		"場地地圖在哪裡？",
+		"我覺得規劃夠了，結束規劃",
+	},
+	HelpLanguageEN: {
+		"Plan my COSCUP day on Aug 9",
+		"I'm interested in AI sessions",
+		"Where is my next session?",
+		"Show my full schedule for today",
+		"What is session JPADKC about?",
+		"What's next in TR211?",
+		"What's on in RB-105 right now?",
+		"How do I get to the RB building?",
+		"Where is the venue map?",
+		"I'm done planning",
+	},
+}
+
+// buildHelpContent assembles the help guide and its usage examples for a language
+func buildHelpContent(language string) string {
+	examples := helpExampleHeadings[language] + "\n"
+	for _, example := range helpExamples[language] {
+		examples += fmt.Sprintf("   \"%s\"\n", example)
+	}
+	return fmt.Sprintf(helpGuides[language], examples+"\n")
+}
+
+func handleHelp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+
+	language := request.GetString("language", HelpLanguageZH)
+	if _, exists := helpGuides[language]; !exists {
+		language = HelpLanguageZH
+	}
+	helpContent := buildHelpContent(language)
 
 	data := map[string]any{
+		"language":     language,
 		"help_content": helpContent,
 		"available_tools": []string{
 			"start_planning",
@@ -436,10 +940,51 @@ func handleHelp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 			"get_room_schedule",
 			"get_venue_map",
 			"help",
+			"get_walking_distance",
+			"recreate_session",
+			"get_ending_soon",
+			"get_arrival_advice",
+			"get_track_catalog",
+			"get_balance_score",
+			"lock_session",
+			"optimize_schedule",
+			"get_livestreamed_now",
+			"get_all_compatible",
+			"resolve_schedule",
+			"get_unsampled_tracks",
+			"compare_with_friend",
+			"get_floor_sessions",
+			"get_missed_sessions",
+			"preview_token",
+			"get_all_rooms",
+			"suggest_lunch",
+			"get_sessions_by_all_tags",
+			"set_mobility",
+			"find_free_block",
+			"get_next_anywhere",
+			"fix_transfers",
+			"import_ics",
+			"get_starter_plan",
+			"get_ongoing_for_me",
+			"get_crowding",
+			"review_plan",
+			"get_remaining_time",
+			"get_sessions_by_tags",
+			"off_campus_break",
+			"search_sessions",
+			"get_speaker_sessions",
+			"get_all_sessions",
+			"check_favorites",
+			"summarize_remaining_day",
+			"get_free_slots",
+			"get_now",
 		},
 	}
 
 	message := "COSCUP 議程規劃助手使用指南已提供。請以用戶偏好語言友善地介紹如何使用這個規劃助手，重點說明可以進行的操作和實用範例。"
+	if language == HelpLanguageEN {
+		message = "The COSCUP planning assistant guide is provided in English. Introduce it to the user in a friendly way, focusing on what they can do and the practical examples."
+	}
 
 	// For help, we don't need a specific sessionID
 	response := Response{
@@ -448,7 +993,7 @@ func handleHelp(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		Message: message,
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 func handleGetSessionDetail(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -469,6 +1014,21 @@ func handleGetSessionDetail(ctx context.Context, request mcp.CallToolRequest) (*
 
 	message := fmt.Sprintf("議程 %s 的完整詳細資訊已提供。這包含完整的摘要內容、難度等級、授課語言等所有資訊。請以用戶偏好語言呈現完整的議程詳情。", sessionCode)
 
+	if session.StreamURL != "" {
+		data["stream_url"] = session.StreamURL
+		message += fmt.Sprintf(" 這場議程有線上直播：%s", session.StreamURL)
+	}
+
+	if request.GetString("split_outline", "") == "true" {
+		summary, outline, ok := splitAbstractOutline(session.Abstract)
+		data["summary"] = summary
+		data["has_outline"] = ok
+		if ok {
+			data["outline"] = outline
+			message += " 摘要已拆分為概述（summary）與大綱（outline），請先簡述概述，再以條列方式呈現大綱。"
+		}
+	}
+
 	// For session detail, we don't have a specific sessionID, so pass empty string
 	response := Response{
 		Success: true,
@@ -476,7 +1036,7 @@ func handleGetSessionDetail(ctx context.Context, request mcp.CallToolRequest) (*
 		Message: message,
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 func handleFinishPlanning(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -486,7 +1046,7 @@ func handleFinishPlanning(ctx context.Context, request mcp.CallToolRequest) (*mc
 	}
 
 	// Check if session exists
-	state := GetUserState(sessionID)
+	state := GetUserStateSnapshot(sessionID)
 	if state == nil {
 		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
 	}
@@ -497,7 +1057,7 @@ func handleFinishPlanning(ctx context.Context, request mcp.CallToolRequest) (*mc
 	}
 
 	data := map[string]any{
-		"day":            state.Day,
+		"day":            toUserDayFormat(state.Day),
 		"schedule":       state.Schedule,
 		"schedule_count": len(state.Schedule),
 		"last_end_time":  state.LastEndTime,
@@ -505,11 +1065,22 @@ func handleFinishPlanning(ctx context.Context, request mcp.CallToolRequest) (*mc
 	}
 
 	message := fmt.Sprintf("🎉 規劃完成！您已成功規劃了 %s 的議程，共選擇 %d 個 session，最後結束時間 %s。您的 COSCUP 2025 行程已確定完成。可以開始期待精彩的議程內容！",
-		state.Day, len(state.Schedule), state.LastEndTime)
+		toUserDayFormat(state.Day), len(state.Schedule), state.LastEndTime)
+
+	if stats, err := GetScheduleStatistics(sessionID); err == nil && len(state.Schedule) > 0 {
+		data["statistics"] = stats
+		message += fmt.Sprintf("\n\n📊 今日行程小結：涵蓋 %d 個議程軌，聽講共 %d 分鐘，最長空檔 %d 分鐘，預估步行約 %d 分鐘。請以用戶偏好語言、歡慶的語氣分享這份小結。",
+			stats["tracks_covered"], stats["total_talk_minutes"], stats["longest_gap_minutes"], stats["total_walking_minutes"])
+	}
+
+	tightTransfers, totalTransfers := AssessPlanDensity(sessionID)
+	data["tight_transfers"] = tightTransfers
+	data["total_transfers"] = totalTransfers
+	message += planDensityWarning(tightTransfers, totalTransfers)
 
 	response := buildStandardResponse(sessionID, data, message)
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
 }
 
 func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -521,13 +1092,7 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 	// Use provided day or default to current COSCUP day
 	day := request.GetString("day", "")
 	if day == "" {
-		timeProvider := &RealTimeProvider{}
-		now := timeProvider.Now()
-		day = getCOSCUPDay(now)
-		// If not during COSCUP, default to Aug9 for historical data queries
-		if day == StatusOutsideCOSCUP {
-			day = DayAug9
-		}
+		day = defaultQueryDay()
 	}
 	if !IsValidDay(day) {
 		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
@@ -535,6 +1100,7 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 
 	nextOnly := request.GetString("next_only", "") == "true"
 	currentOnly := request.GetString("current_only", "") == "true"
+	includeDetail := request.GetString("include_detail", "") == "true"
 
 	// Convert day format
 	internalDay := convertDayFormat(day)
@@ -547,7 +1113,11 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 	// Get room sessions
 	roomSessions := FindRoomSessions(internalDay, room)
 	if len(roomSessions) == 0 {
-		return mcp.NewToolResultError(fmt.Sprintf("Error: no sessions found for room %s on %s", room, internalDay)), nil
+		// The room may still be busy on the other day; say so instead of implying it's unused
+		if hint := otherDayRoomHint(room, internalDay); hint != "" {
+			return mcp.NewToolResultError(fmt.Sprintf("Error: no sessions found for room %s on %s. Hint: %s - query that day instead.", room, toUserDayFormat(internalDay), hint)), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Error: no sessions found for room %s on %s", room, toUserDayFormat(internalDay))), nil
 	}
 
 	var mode string
@@ -576,11 +1146,20 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 
 	data := map[string]any{
 		"room":           room,
-		"day":            internalDay,
+		"day":            toUserDayFormat(internalDay),
 		"current_time":   currentTime,
+		"timestamp":      now.Format(time.RFC3339),
 		"mode":           mode,
 		"sessions":       sessions,
 		"total_sessions": len(roomSessions),
+		"include_detail": includeDetail,
+	}
+
+	// Swap in full sessions with abstracts when requested
+	if includeDetail {
+		detailed, truncated := withSessionDetails(sessions, MaxDetailedRoomSessions)
+		data["sessions"] = detailed
+		data["detail_truncated"] = truncated
 	}
 
 	// Add current and next session info when available
@@ -592,15 +1171,11 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 	}
 
 	var message string
-	
-	// Convert to Taipei timezone (UTC+8)
-	taipeiLoc := time.FixedZone("GMT+8", 8*60*60)
-	taipeiTime := now.In(taipeiLoc)
-	
-	// Check if current date is during COSCUP (2025/8/9-10)
-	isDuringCOSCUP := (taipeiTime.Year() == COSCUPYear && taipeiTime.Month() == COSCUPMonth && 
-		(taipeiTime.Day() == COSCUPDay1 || taipeiTime.Day() == COSCUPDay2))
-	
+
+	// Check if current date is during COSCUP (2025/8/9-10) in Taipei time
+	taipeiTime := now.In(conferenceLocation())
+	isDuringCOSCUP := isInCOSCUPPeriod(now)
+
 	switch mode {
 	case "next_only":
 		if nextSession != nil {
@@ -615,7 +1190,7 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 				room, currentSession.Start, currentSession.End, currentSession.Title)
 		} else {
 			if !isDuringCOSCUP {
-				message = fmt.Sprintf("房間 %s 現在沒有議程進行中。\n\n⏰ 目前時間：%s (台北時區)\n❌ 目前非 COSCUP 2025 主辦時間\n📅 COSCUP 2025 舉辦日期：8月9日-10日\n💡 此查詢顯示的是 %s 的歷史議程資料", 
+				message = fmt.Sprintf("房間 %s 現在沒有議程進行中。\n\n⏰ 目前時間：%s (台北時區)\n❌ 目前非 COSCUP 2025 主辦時間\n📅 COSCUP 2025 舉辦日期：8月9日-10日\n💡 此查詢顯示的是 %s 的歷史議程資料",
 					room, taipeiTime.Format("2006年1月2日 15:04"), internalDay)
 			} else {
 				message = fmt.Sprintf("房間 %s 現在沒有議程進行中", room)
@@ -623,7 +1198,11 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 		}
 	default:
 		message = fmt.Sprintf("房間 %s 在 %s 共有 %d 場議程。已按時間順序排列，請以用戶偏好語言呈現完整的房間議程時間表。",
-			room, internalDay, len(roomSessions))
+			room, toUserDayFormat(internalDay), len(roomSessions))
+	}
+
+	if truncated, _ := data["detail_truncated"].(bool); truncated {
+		message += fmt.Sprintf(" 詳細內容僅包含前 %d 場議程，其餘議程可用 get_session_detail 查詢。", MaxDetailedRoomSessions)
 	}
 
 	// For room schedule, we don't have a specific sessionID, so pass empty string to buildStandardResponse
@@ -633,21 +1212,1989 @@ func handleGetRoomSchedule(ctx context.Context, request mcp.CallToolRequest) (*m
 		Message: message,
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("%+v", response)), nil
+	return newToolResult(response), nil
+}
+
+// defaultQueryDay returns the current COSCUP day, or a phase-dependent default outside the conference, see getQueryDay
+func defaultQueryDay() string {
+	timeProvider := &RealTimeProvider{}
+	return getQueryDay(timeProvider.Now())
+}
+
+// 13. Get Ending Soon Tool
+func createGetEndingSoonTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_ending_soon",
+		mcp.WithDescription("List sessions that are currently running and will end within the next N minutes, sorted by end time. Use when user is wandering the venue and wants to catch the end of a talk, e.g. '有哪些議程快結束了', 'what talks are wrapping up soon'. Mention how many minutes are left for each session."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+		mcp.WithNumber("within",
+			mcp.Description(fmt.Sprintf("Look-ahead window in minutes. Optional - defaults to %d", DefaultEndingSoonMinutes)),
+		),
+	)
+}
+
+func handleGetEndingSoon(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+
+	within := request.GetInt("within", DefaultEndingSoonMinutes)
+	if within <= 0 {
+		return mcp.NewToolResultError("Error: within must be a positive number of minutes"), nil
+	}
+
+	internalDay := convertDayFormat(day)
+
+	timeProvider := &RealTimeProvider{}
+	now := timeProvider.Now()
+	currentTime := formatTimeForSession(now)
+
+	sessions := SessionsEndingSoon(internalDay, currentTime, within)
+
+	data := map[string]any{
+		"day":            toUserDayFormat(internalDay),
+		"current_time":   currentTime,
+		"within_minutes": within,
+		"sessions":       sessions,
+	}
+
+	var message string
+	if len(sessions) == 0 {
+		message = fmt.Sprintf("%s %s 之後 %d 分鐘內沒有即將結束的議程。", toUserDayFormat(internalDay), currentTime, within)
+	} else {
+		message = fmt.Sprintf("%s %s 之後 %d 分鐘內有 %d 場議程即將結束，已按結束時間排序。請以用戶偏好語言列出，並說明每場剩餘幾分鐘，方便用戶趕上尾聲。",
+			toUserDayFormat(internalDay), currentTime, within, len(sessions))
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 14. Get Arrival Advice Tool
+func createGetArrivalAdviceTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_arrival_advice",
+		mcp.WithDescription(sessionIdWarning+"Tell the user when they need to arrive on campus for their first planned session. Use when user asks 'when do I need to get to campus', '我幾點要到會場', or is planning their commute. Returns the first scheduled session, a recommended arrival buffer and arrival time. Remind the user the buffer is a rough estimate."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetArrivalAdvice(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	firstSession, leaveBuffer := EarliestArrivalAdvice(sessionID)
+	if firstSession == nil {
+		data := map[string]any{
+			"day":            toUserDayFormat(state.Day),
+			"schedule_count": 0,
+		}
+		message := "用戶尚未選擇任何議程，無法建議抵達時間。請引導用戶先使用 get_options 選擇議程。"
+		return newToolResult(buildStandardResponse(sessionID, data, message)), nil
+	}
+
+	arrivalTime := minutesToTime(timeToMinutes(firstSession.Start) - leaveBuffer)
+
+	data := map[string]any{
+		"day":            toUserDayFormat(state.Day),
+		"first_session":  *firstSession,
+		"buffer_minutes": leaveBuffer,
+		"arrival_time":   arrivalTime,
+	}
+
+	message := fmt.Sprintf("用戶第一場議程是 %s 在 %s 的「%s」。建議最晚 %s 抵達校園（預留 %d 分鐘入場與找路時間，僅為粗略估計）。請以用戶偏好語言說明。",
+		firstSession.Start, firstSession.Room, firstSession.Title, arrivalTime, leaveBuffer)
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 15. Get Track Catalog Tool
+func createGetTrackCatalogTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_track_catalog",
+		mcp.WithDescription("List the conference tracks (themes) with their session counts and a couple of representative sessions as a teaser. Use when user asks 'tell me about the tracks', '有哪些主題軌', or wants help picking a theme before planning. Summarize the tracks briefly and use the representative sessions to illustrate what each track is about."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to both days"),
+		),
+	)
+}
+
+func handleGetTrackCatalog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day != "" && !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+
+	internalDay := convertDayFormat(day)
+	tracks := GetTrackSummary(internalDay)
+
+	data := map[string]any{
+		"day":          toUserDayFormat(internalDay),
+		"tracks":       tracks,
+		"total_tracks": len(tracks),
+	}
+
+	scope := "兩天"
+	if internalDay != "" {
+		scope = toUserDayFormat(internalDay)
+	}
+	message := fmt.Sprintf("COSCUP 2025 %s 共有 %d 個議程軌，已按議程數量排序並附上代表議程。請以用戶偏好語言簡要介紹各議程軌，協助用戶挑選感興趣的主題。",
+		scope, len(tracks))
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 16. Get Balance Score Tool
+func createGetBalanceScoreTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_balance_score",
+		mcp.WithDescription(sessionIdWarning+"Rate the user's plan with a 0-100 balance score combining track variety, walking efficiency and break quality, with a breakdown per component. Use when user asks 'how good is my plan', '我的行程安排得好嗎', or wants feedback on their schedule. Present it playfully and point out the weakest component as a tradeoff they could improve."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetBalanceScore(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	score, components := ScheduleBalanceScore(sessionID)
+	if components == nil {
+		data := map[string]any{
+			"day":            toUserDayFormat(state.Day),
+			"schedule_count": 0,
+		}
+		message := "用戶尚未選擇任何議程，無法計算平衡分數。請引導用戶先使用 get_options 選擇議程。"
+		return newToolResult(buildStandardResponse(sessionID, data, message)), nil
+	}
+
+	data := map[string]any{
+		"day":            toUserDayFormat(state.Day),
+		"schedule_count": len(state.Schedule),
+		"score":          score,
+		"components":     components,
+	}
+
+	message := fmt.Sprintf("用戶行程平衡分數為 %d / 100（主題多樣性 %d、步行效率 %d、休息安排 %d）。請以用戶偏好語言、輕鬆有趣的方式說明，並指出分數最低的項目可以如何改善。",
+		score, components["variety"], components["walking"], components["breaks"])
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 17. Lock Session Tool
+func createLockSessionTool() mcp.Tool {
+	return mcp.NewTool(
+		"lock_session",
+		mcp.WithDescription(sessionIdWarning+"Lock a session in the user's schedule as must-attend so optimize_schedule plans around it and never replaces it. Use when user says a talk is a must, e.g. '這場一定要去', 'don't move this one'. Set unlock='true' to release the lock."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithString("sessionCode",
+			mcp.Description("Code of a session already in the user's schedule"),
+		),
+		mcp.WithString("unlock",
+			mcp.Description("Set to 'true' to unlock the session instead"),
+		),
+	)
+}
+
+func handleLockSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	sessionCode, err := request.RequireString("sessionCode")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionCodeRequired.Error()), nil
+	}
+
+	locked := request.GetString("unlock", "") != "true"
+
+	if err := LockSession(sessionID, sessionCode, locked); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	data := map[string]any{
+		"session_code": sessionCode,
+		"locked":       locked,
+		"locked_count": len(state.Locked),
+	}
+
+	message := fmt.Sprintf("議程 %s 已鎖定，optimize_schedule 會保留這場議程並以它為中心安排其他時段。", sessionCode)
+	if !locked {
+		message = fmt.Sprintf("議程 %s 已解除鎖定，optimize_schedule 之後可能會替換它。", sessionCode)
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 18. Optimize Schedule Tool
+func createOptimizeScheduleTool() mcp.Tool {
+	return mcp.NewTool(
+		"optimize_schedule",
+		mcp.WithDescription(sessionIdWarning+"Propose a full-day plan that keeps the user's locked sessions and fills every other slot with the earliest available session, preferring the user's interested tracks and staying in the same building. The current schedule is NOT changed - show the proposal and let the user pick sessions with choose_session. Suggest lock_session first if the user has must-attend talks."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleOptimizeSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	plan, err := OptimizeSchedule(sessionID)
+	if err != nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	data := map[string]any{
+		"day":          toUserDayFormat(state.Day),
+		"proposal":     plan,
+		"locked_count": len(state.Locked),
+	}
+
+	message := fmt.Sprintf("已根據 %d 個鎖定議程產生 %d 場議程的建議行程（尚未套用）。請以用戶偏好語言呈現建議，標示鎖定的議程，並詢問用戶是否要用 choose_session 加入其中的議程。",
+		len(state.Locked), len(plan))
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 19. Get Livestreamed Now Tool
+func createGetLivestreamedNowTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_livestreamed_now",
+		mcp.WithDescription("List sessions that are running right now and have an official livestream, with their stream URLs. Use when user asks 'what can I watch online now', '現在有哪些直播', or is deciding between attending in person and watching remotely."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetLivestreamedNow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+
+	internalDay := convertDayFormat(day)
+
+	timeProvider := &RealTimeProvider{}
+	currentTime := formatTimeForSession(timeProvider.Now())
+
+	sessions := GetLivestreamedSessions(internalDay, currentTime)
+
+	data := map[string]any{
+		"day":          toUserDayFormat(internalDay),
+		"current_time": currentTime,
+		"sessions":     sessions,
+	}
+
+	var message string
+	if len(sessions) == 0 {
+		message = fmt.Sprintf("%s %s 目前沒有正在直播的議程。", toUserDayFormat(internalDay), currentTime)
+	} else {
+		message = fmt.Sprintf("%s %s 共有 %d 場議程正在直播。請以用戶偏好語言列出議程與直播連結。", toUserDayFormat(internalDay), currentTime, len(sessions))
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 20. Get All Compatible Tool
+func createGetAllCompatibleTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_all_compatible",
+		mcp.WithDescription(sessionIdWarning+"Power-user browse mode: list EVERY remaining session of the day that fits the user's current schedule without time conflicts, not just the next one per room like get_options. Use when user wants to see everything they could still attend, e.g. '列出所有還能選的議程', 'show me all compatible sessions'. Results are sorted by time then room; group them by timeslot when presenting."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetAllCompatible(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	sessions := FindAllCompatibleSessions(sessionID)
+
+	data := map[string]any{
+		"day":           toUserDayFormat(state.Day),
+		"last_end_time": state.LastEndTime,
+		"sessions":      sessions,
+		"total":         len(sessions),
+	}
+
+	var message string
+	if len(sessions) == 0 {
+		message = "No remaining sessions fit the user's current schedule."
+	} else {
+		message = fmt.Sprintf("Found %d sessions after %s that fit the user's schedule. Group them by timeslot and show code, title, room and time for each. Users can add any of them with choose_session.",
+			len(sessions), state.LastEndTime)
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 21. Resolve Schedule Tool
+func createResolveScheduleTool() mcp.Tool {
+	return mcp.NewTool(
+		"resolve_schedule",
+		mcp.WithDescription(sessionIdWarning+"List sessions in the user's schedule that now overlap because the official agenda moved a session, and let the user decide which one to keep. Call without 'keep' to show the competing sessions for each conflict, then call again with keep=<session code> once the user has chosen; the other overlapping sessions are removed from the schedule."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithString("keep",
+			mcp.Description("Code of the conflicting session the user wants to keep"),
+		),
+	)
+}
+
+func handleResolveSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	if GetUserState(sessionID) == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	var removed []Session
+	keep := request.GetString("keep", "")
+	if keep != "" {
+		removed, err = KeepConflictingSession(sessionID, keep)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+		}
+	}
+
+	choices := ResolvePostReloadConflicts(sessionID)
+
+	data := map[string]any{
+		"conflicts":      choices,
+		"conflict_count": len(choices),
+	}
+
+	var message string
+	if keep != "" {
+		data["kept"] = keep
+		data["removed"] = removed
+		message = fmt.Sprintf("已保留議程 %s，並移除 %d 場與它時間重疊的議程。", keep, len(removed))
+	}
+
+	if len(choices) == 0 {
+		message += "目前行程中沒有時間衝突的議程。"
+	} else {
+		message += fmt.Sprintf("議程時間異動後，您的行程中有 %d 組時間重疊的議程，每組只能保留一場：", len(choices))
+		for _, choice := range choices {
+			message += fmt.Sprintf("\n\n⚠️ %s-%s", choice.Start, choice.End)
+			for _, option := range choice.Options {
+				message += fmt.Sprintf("\n- [%s] %s-%s 在 %s「%s」", option.Code, option.Start, option.End, option.Room, option.Title)
+			}
+		}
+		message += "\n\nPlease ask the user which session to keep in each group, then call resolve_schedule with keep=<session code>."
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 22. Get Unsampled Tracks Tool
+func createGetUnsampledTracksTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_unsampled_tracks",
+		mcp.WithDescription(sessionIdWarning+"List the tracks of the user's planning day that they haven't picked any session from yet, with one teaser session each. Use when user asks 'am I missing anything interesting?', '還有什麼主題我沒聽過', or wants more variety in their plan. Briefly pitch each track with its teaser session."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetUnsampledTracks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	unsampled := UnsampledTracks(sessionID)
+
+	data := map[string]any{
+		"day":                 toUserDayFormat(state.Day),
+		"unsampled_tracks":    unsampled,
+		"total_unsampled":     len(unsampled),
+		"sampled_track_count": len(state.Profile),
+	}
+
+	var message string
+	if len(unsampled) == 0 {
+		message = fmt.Sprintf("您已經涵蓋了 %s 所有的議程軌，涉獵非常廣泛！", toUserDayFormat(state.Day))
+	} else {
+		message = fmt.Sprintf("%s 還有 %d 個議程軌您尚未接觸，每個都附上一場代表議程。請以用戶偏好語言簡短介紹這些主題，鼓勵用戶嘗試不同領域，用戶可以用 choose_session 加入有興趣的議程。",
+			toUserDayFormat(state.Day), len(unsampled))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 23. Compare With Friend Tool
+func createCompareWithFriendTool() mcp.Tool {
+	return mcp.NewTool(
+		"compare_with_friend",
+		mcp.WithDescription(sessionIdWarning+"Companion mode: compare the user's schedule with a friend's and list the time windows when both are free, so they can meet up. Use when user says '我跟朋友什麼時候可以碰面', 'when can I meet my friend', and provides the friend's sessionId. Suggest the longer common windows (like lunch) as good meeting times."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithString("friendSessionId",
+			mcp.Description("The friend's session ID"),
+		),
+	)
+}
+
+func handleCompareWithFriend(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	friendSessionID, err := request.RequireString("friendSessionId")
+	if err != nil {
+		return mcp.NewToolResultError("Error: friendSessionId is required"), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	friendState := GetUserStateSnapshot(friendSessionID)
+	if friendState == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: cannot find friend's session %s", friendSessionID)), nil
+	}
+
+	commonSlots := FindCommonFreeSlots(sessionID, friendSessionID)
+
+	data := map[string]any{
+		"day":               toUserDayFormat(state.Day),
+		"friend_day":        toUserDayFormat(friendState.Day),
+		"common_free_slots": commonSlots,
+		"total_slots":       len(commonSlots),
+	}
+
+	var message string
+	switch {
+	case state.Day != friendState.Day:
+		message = fmt.Sprintf("您規劃的是 %s，朋友規劃的是 %s，兩人不在同一天，沒有共同空檔。", toUserDayFormat(state.Day), toUserDayFormat(friendState.Day))
+	case len(commonSlots) == 0:
+		message = "您和朋友的行程沒有共同的空檔時間，可以考慮一起參加同一場議程。"
+	default:
+		message = fmt.Sprintf("您和朋友在 %s 有 %d 段共同空檔：", toUserDayFormat(state.Day), len(commonSlots))
+		for _, slot := range commonSlots {
+			message += fmt.Sprintf("\n- %s-%s", slot[0], slot[1])
+			if isLunchBreak(slot[0], slot[1]) {
+				message += " 🍱 可以一起吃午餐"
+			}
+		}
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 24. Get Floor Sessions Tool
+func createGetFloorSessionsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_floor_sessions",
+		mcp.WithDescription("List the sessions on one floor of a building, for users navigating by floor, e.g. '5 樓現在有什麼', 'what's on the 5th floor of TR right now'. The floor comes from the room number (TR509 is on floor 5). By default only sessions that haven't ended yet are returned; set all_day='true' for the whole day. AU rooms have no floor number and never match."),
+		mcp.WithString("building",
+			mcp.Description("Building code, e.g. TR or RB"),
+		),
+		mcp.WithNumber("floor",
+			mcp.Description("Floor number, e.g. 5"),
+		),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+		mcp.WithString("all_day",
+			mcp.Description("Set to 'true' to include sessions that already ended"),
+		),
+	)
+}
+
+func handleGetFloorSessions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	building, err := request.RequireString("building")
+	if err != nil {
+		return mcp.NewToolResultError("Error: building is required"), nil
+	}
+	building = strings.ToUpper(strings.TrimSpace(building))
+
+	floor := request.GetInt("floor", 0)
+	if floor <= 0 {
+		return mcp.NewToolResultError("Error: floor must be a positive number"), nil
+	}
+
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	currentTime := ""
+	if request.GetString("all_day", "") != "true" {
+		timeProvider := &RealTimeProvider{}
+		currentTime = formatTimeForSession(timeProvider.Now())
+	}
+
+	sessions := FindSessionsByFloor(internalDay, building, floor, currentTime)
+
+	data := map[string]any{
+		"day":      toUserDayFormat(internalDay),
+		"building": building,
+		"floor":    floor,
+		"sessions": sessions,
+	}
+	if currentTime != "" {
+		data["current_time"] = currentTime
+	}
+
+	var message string
+	if len(sessions) == 0 {
+		message = fmt.Sprintf("%s %s %d 樓目前沒有議程。", toUserDayFormat(internalDay), buildingDisplayName(building), floor)
+	} else {
+		message = fmt.Sprintf("%s %s %d 樓有 %d 場議程，已按時間與教室排序。請以用戶偏好語言列出每場的時間、教室與標題，並標示正在進行中的議程。",
+			toUserDayFormat(internalDay), buildingDisplayName(building), floor, len(sessions))
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 25. Get Missed Sessions Tool
+func createGetMissedSessionsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_missed_sessions",
+		mcp.WithDescription(sessionIdWarning+"List the planned sessions that ended while the user was away, i.e. since their last interaction. Use when a returning user asks 'what did I miss?', '我剛剛錯過了什麼', or comes back after a long break. Recap each missed session briefly and remind the user recordings or slides may be on the session page."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetMissedSessions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	// Read the last activity before any lookup refreshes it
+	since, exists := lastActivityOf(sessionID)
+	if !exists {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	missed := MissedSince(sessionID, since)
+
+	data := map[string]any{
+		"since":        since.In(conferenceLocation()).Format(time.RFC3339),
+		"missed":       missed,
+		"missed_count": len(missed),
+	}
+
+	var message string
+	if len(missed) == 0 {
+		message = fmt.Sprintf("自您上次使用（%s）以來，沒有錯過任何已規劃的議程。", formatTimeForSession(since))
+	} else {
+		message = fmt.Sprintf("自您上次使用（%s）以來，有 %d 場已規劃的議程已經結束。請以用戶偏好語言簡短回顧每場議程的標題與重點，並提醒可以到議程頁面查看簡報或錄影。", formatTimeForSession(since), len(missed))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 26. Preview Token Tool
+func createPreviewTokenTool() mcp.Tool {
+	return mcp.NewTool(
+		"preview_token",
+		mcp.WithDescription("Preview what a shared schedule token (the share_token from get_schedule) contains without creating or changing any session. Use when user pastes a friend's token and asks what's in it, e.g. '這個 token 裡面有哪些議程'. Sessions removed from the official agenda since the token was made are flagged as cancelled."),
+		mcp.WithString("token",
+			mcp.Description("Schedule token to preview"),
+		),
+	)
+}
+
+func handlePreviewToken(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	token, err := request.RequireString("token")
+	if err != nil {
+		return mcp.NewToolResultError("Error: token is required"), nil
+	}
+
+	preview, err := PreviewScheduleToken(token)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	}
+	day, _, _ := decodeScheduleToken(token)
+
+	invalid := countCancelledSessions(preview)
+	data := map[string]any{
+		"day":           toUserDayFormat(day),
+		"sessions":      preview,
+		"session_count": len(preview),
+		"invalid_count": invalid,
+	}
+
+	message := fmt.Sprintf("這個行程 token 是 %s 的行程，共 %d 場議程。請以時間軸方式列出這些議程供用戶預覽。", toUserDayFormat(day), len(preview))
+	if invalid > 0 {
+		message += fmt.Sprintf(" 注意：其中 %d 個議程代碼已不在官方議程表中（標記為已取消），請提醒用戶。", invalid)
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 27. Get All Rooms Tool
+func createGetAllRoomsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_all_rooms",
+		mcp.WithDescription("List every COSCUP room used on either day together with its building. Static venue reference that needs no session, e.g. '有哪些教室' or '會場有哪些房間'."),
+	)
+}
+
+func handleGetAllRooms(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	roomBuildings := GetAllRoomsWithBuildings()
+
+	rooms := make([]map[string]string, 0, len(roomBuildings))
+	for room, building := range roomBuildings {
+		rooms = append(rooms, map[string]string{
+			"room":     room,
+			"building": building,
+		})
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i]["building"] != rooms[j]["building"] {
+			return rooms[i]["building"] < rooms[j]["building"]
+		}
+		return rooms[i]["room"] < rooms[j]["room"]
+	})
+
+	response := Response{
+		Success: true,
+		Data: map[string]any{
+			"rooms":      rooms,
+			"room_count": len(rooms),
+		},
+		Message: fmt.Sprintf("COSCUP 兩天共使用 %d 間教室，已依大樓分組排序。", len(rooms)),
+	}
+
+	return newToolResult(response), nil
+}
+
+// 28. Suggest Lunch Tool
+func createSuggestLunchTool() mcp.Tool {
+	return mcp.NewTool(
+		"suggest_lunch",
+		mcp.WithDescription(sessionIdWarning+"Suggest when the user should eat lunch based on the gaps in their planned schedule. Use when user asks '我什麼時候可以吃午餐', 'when should I grab lunch', or worries the schedule leaves no time to eat."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleSuggestLunch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	start, end, ok := SuggestLunchTime(sessionID)
+	data := map[string]any{
+		"has_lunch_slot": ok,
+	}
+
+	var message string
+	if ok {
+		data["lunch_start"] = start
+		data["lunch_end"] = end
+		data["lunch_minutes"] = timeToMinutes(end) - timeToMinutes(start)
+		message = fmt.Sprintf("建議在 %s-%s 用餐（共 %d 分鐘），這是您中午時段最長的空檔。", start, end, timeToMinutes(end)-timeToMinutes(start))
+	} else {
+		message = fmt.Sprintf("您中午時段的行程太緊湊，沒有至少 %d 分鐘的空檔可以好好吃午餐。建議準備輕食，或使用 get_schedule 檢視行程並考慮放棄一場中午的議程。", MinLunchMinutes)
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 29. Get Sessions By All Tags Tool
+func createGetSessionsByAllTagsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_sessions_by_all_tags",
+		mcp.WithDescription("List every tag of a day together with all of its sessions, for browsing by category. Use when user asks '每個分類有哪些議程', 'show everything by topic', or wants an overview grouped by tags. Sessions with several tags appear under each tag."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetSessionsByAllTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	groups := GroupSessionsByTag(internalDay)
+
+	tags := make([]map[string]any, 0, len(groups))
+	for tag, sessions := range groups {
+		tags = append(tags, map[string]any{
+			"tag":           tag,
+			"session_count": len(sessions),
+			"sessions":      sessions,
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		countI, countJ := tags[i]["session_count"].(int), tags[j]["session_count"].(int)
+		if countI != countJ {
+			return countI > countJ
+		}
+		return tags[i]["tag"].(string) < tags[j]["tag"].(string)
+	})
+
+	response := Response{
+		Success: true,
+		Data: map[string]any{
+			"day":       toUserDayFormat(internalDay),
+			"tags":      tags,
+			"tag_count": len(tags),
+		},
+		Message: fmt.Sprintf("%s 共有 %d 個標籤，已依議程數量排序，每個標籤下的議程依時間排序。請依標籤分組列出議程的時間、教室與標題；同一場議程可能出現在多個標籤下。", toUserDayFormat(internalDay), len(tags)),
+	}
+
+	return newToolResult(response), nil
+}
+
+// 30. Set Mobility Tool
+func createSetMobilityTool() mcp.Tool {
+	return mcp.NewTool(
+		"set_mobility",
+		mcp.WithDescription(sessionIdWarning+"Set how fast the user walks between rooms so transfer advice matches them. Use 'slow' when the user carries a laptop to workshops, has limited mobility or says '我走比較慢'; 'fast' when they say they move quickly. Walking estimates in get_next_session and get_schedule are scaled accordingly."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithString("mobility",
+			mcp.Description("Walking pace: 'fast' (x0.75), 'normal' or 'slow' (x1.5)"),
+			mcp.Enum(string(MobilityFast), string(MobilityNormal), string(MobilitySlow)),
+		),
+	)
+}
+
+func handleSetMobility(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	mobility, valid := parseMobility(request.GetString("mobility", ""))
+	if !valid {
+		return mcp.NewToolResultError("Error: mobility must be 'fast', 'normal' or 'slow'"), nil
+	}
+
+	if err := SetMobility(sessionID, mobility); err != nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	data := map[string]any{
+		"mobility": mobility,
+	}
+
+	message := fmt.Sprintf("已將步行速度設為 %s，之後的移動時間與是否來得及的判斷都會依此調整。", mobility)
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 31. Find Free Block Tool
+func createFindFreeBlockTool() mcp.Tool {
+	return mcp.NewTool(
+		"find_free_block",
+		mcp.WithDescription(sessionIdWarning+"Find the earliest free time in the user's schedule lasting at least the given number of minutes, e.g. for a 45-minute meetup. Use when user asks '我什麼時候有 45 分鐘的空檔', 'when am I free for an hour'. Only conference hours are considered."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithNumber("minutes",
+			mcp.Description("Required length of the free block in minutes"),
+		),
+	)
+}
+
+func handleFindFreeBlock(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	minutes := request.GetInt("minutes", 0)
+	if minutes <= 0 {
+		return mcp.NewToolResultError("Error: minutes must be a positive number"), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	start, end, ok := FindFreeSlotOfLength(sessionID, minutes)
+	data := map[string]any{
+		"requested_minutes": minutes,
+		"found":             ok,
+	}
+
+	var message string
+	if ok {
+		data["start"] = start
+		data["end"] = end
+		message = fmt.Sprintf("最早可以在 %s-%s 空出 %d 分鐘（這段空檔共 %d 分鐘）。", start, end, minutes, timeToMinutes(end)-timeToMinutes(start))
+	} else {
+		message = fmt.Sprintf("您在 %s 的行程中沒有長達 %d 分鐘的空檔。可以建議用戶縮短活動時間，或放棄一場議程來騰出時間。", toUserDayFormat(state.Day), minutes)
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 32. Get Next Anywhere Tool
+func createGetNextAnywhereTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_next_anywhere",
+		mcp.WithDescription("List the next sessions starting anywhere in the venue, regardless of the user's schedule. Use when a user without a plan asks '接下來有什麼議程', 'what's starting next'. When nothing else starts today, suggest the social activities that are still running instead."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetNextAnywhere(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	timeProvider := &RealTimeProvider{}
+	currentTime := formatTimeForSession(timeProvider.Now())
+
+	return newToolResult(buildNextAnywhereResponse(internalDay, currentTime)), nil
+}
+
+// buildNextAnywhereResponse builds the get_next_anywhere response, with a terminal message when nothing starts later
+func buildNextAnywhereResponse(day, currentTime string) Response {
+	sessions := GetNextSessionAnywhere(day, currentTime)
+
+	data := map[string]any{
+		"day":          toUserDayFormat(day),
+		"current_time": currentTime,
+		"sessions":     sessions,
+	}
+
+	var message string
+	if len(sessions) == 0 {
+		social := GetOngoingSocialActivities(day, currentTime)
+		data["no_more_sessions"] = true
+		data["social_activities"] = social
+
+		message = fmt.Sprintf("%s %s 之後今天已經沒有新的議程了。", toUserDayFormat(day), currentTime)
+		if len(social) > 0 {
+			message += " 不過以下交流活動還在進行，可以去看看："
+			for _, activity := range social {
+				message += fmt.Sprintf("\n- %s-%s 在 %s「%s」", activity.Start, activity.End, activity.Room, activity.Title)
+			}
+		} else {
+			message += " 可以到攤位區或走廊逛逛，和其他與會者交流。"
+		}
+	} else {
+		message = fmt.Sprintf("%s 下一批議程在 %s 開始，共 %d 場。請以用戶偏好語言列出每場的教室與標題。", toUserDayFormat(day), sessions[0].Start, len(sessions))
+	}
+
+	return Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+}
+
+// 33. Fix Transfers Tool
+func createFixTransfersTool() mcp.Tool {
+	return mcp.NewTool(
+		"fix_transfers",
+		mcp.WithDescription(sessionIdWarning+"Propose a trimmed version of an over-planned schedule that drops the fewest sessions so the user can walk between every remaining pair in time. Use when user worries '我來得及換場嗎', 'my schedule is too tight', or get_next_session keeps warning about rushing. The schedule is NOT changed - show which sessions would be dropped and let the user decide."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleFixTransfers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	subset := SuggestFeasibleSubset(sessionID)
+	kept := make(map[string]bool, len(subset))
+	for _, session := range subset {
+		kept[session.Code] = true
+	}
+	var dropped []Session
+	for _, session := range state.Schedule {
+		if !kept[session.Code] {
+			dropped = append(dropped, session)
+		}
+	}
+
+	data := map[string]any{
+		"proposal":      subset,
+		"dropped":       dropped,
+		"dropped_count": len(dropped),
+	}
+
+	var message string
+	if len(dropped) == 0 {
+		message = "您的行程中每次換場都來得及，不需要調整。"
+	} else {
+		message = fmt.Sprintf("建議移除以下 %d 場議程，其餘 %d 場之間的換場時間就都足夠（尚未套用）：", len(dropped), len(subset))
+		for _, session := range dropped {
+			message += fmt.Sprintf("\n- [%s] %s-%s 在 %s「%s」", session.Code, session.Start, session.End, session.Room, session.Title)
+		}
+		message += "\n\nPlease ask the user whether to accept this proposal before changing anything."
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 34. Import ICS Tool
+func createImportICSTool() mcp.Tool {
+	return mcp.NewTool(
+		"import_ics",
+		mcp.WithDescription(sessionIdWarning+"Add the COSCUP sessions found in an iCalendar (.ics) file to the user's schedule, e.g. one exported earlier with get_schedule include_ics or edited in a calendar app. Use when user pastes calendar content and says '幫我匯入這個行事曆'. Events that are not COSCUP sessions of the planning day are ignored."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithString("ics",
+			mcp.Description("Full iCalendar file content, starting with BEGIN:VCALENDAR"),
+		),
+	)
+}
+
+func handleImportICS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	ics, err := request.RequireString("ics")
+	if err != nil {
+		return mcp.NewToolResultError("Error: ics is required"), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	codes, err := ImportScheduleICS(state.Day, strings.NewReader(ics))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: %s", err.Error())), nil
+	}
+
+	// state is a snapshot, so it doesn't change as sessions are added
+	var added []string
+	failed := make(map[string]string)
+	// A conflicting repeated talk is added in another timeslot; map the requested code to the one added
+	replaced := make(map[string]string)
+	for _, code := range codes {
+		if slices.ContainsFunc(state.Schedule, func(s Session) bool { return s.Code == code }) {
+			continue
+		}
+		session, err := ScheduleSession(sessionID, code)
+		if err != nil {
+			failed[code] = err.Error()
+			continue
+		}
+		added = append(added, session.Code)
+		if session.Code != code {
+			replaced[code] = session.Code
+		}
+	}
+
+	data := map[string]any{
+		"matched_codes": codes,
+		"added":         added,
+		"failed":        failed,
+	}
+
+	message := fmt.Sprintf("行事曆中找到 %d 場 %s 的議程，已加入 %d 場。", len(codes), toUserDayFormat(state.Day), len(added))
+	if len(replaced) > 0 {
+		data["requested_codes"] = replaced
+		message += fmt.Sprintf(" 其中 %d 場與行程衝突，已改加入同一議程的其他場次（見 requested_codes），請告知用戶。", len(replaced))
+	}
+	if len(failed) > 0 {
+		message += fmt.Sprintf(" 有 %d 場因時間衝突等原因無法加入，請告知用戶。", len(failed))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 35. Get Starter Plan Tool
+func createGetStarterPlanTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_starter_plan",
+		mcp.WithDescription("Suggest a ready-made plan for first-time attendees who don't know where to start: the day's keynotes plus a few sessions from varied, popular tracks with short walks and a free lunch break. Needs no session or profile. Use when user says '第一次來不知道要聽什麼', 'just give me a good default plan'. Present it as a suggestion; the user can start_planning and add the sessions they like with choose_session."),
+		mcp.WithString("day",
+			mcp.Description("Day to plan ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetStarterPlan(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	plan := GenerateStarterPlan(internalDay)
+
+	tracks := make(map[string]bool)
+	for _, session := range plan {
+		tracks[session.Track] = true
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]any{
+			"day":         toUserDayFormat(internalDay),
+			"plan":        plan,
+			"track_count": len(tracks),
+		},
+		Message: fmt.Sprintf("%s 的新手推薦行程共 %d 場議程，涵蓋 %d 個議程軌，並保留午餐時間。請以時間軸方式呈現，簡短說明每場的主題，並告訴用戶可以用 start_planning 開始規劃、再用 choose_session 加入喜歡的議程。",
+			toUserDayFormat(internalDay), len(plan), len(tracks)),
+	}
+
+	return newToolResult(response), nil
+}
+
+// 36. Get Ongoing For Me Tool
+func createGetOngoingForMeTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_ongoing_for_me",
+		mcp.WithDescription(sessionIdWarning+"List the sessions running right now that match the user's interests (profile tracks or tags of their planned sessions). Use when a user wandering the venue asks '現在有什麼我會有興趣的', 'anything relevant going on right now?'. Mention that they can still walk in late."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetOngoingForMe(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	timeProvider := &RealTimeProvider{}
+	currentTime := formatTimeForSession(timeProvider.Now())
+	ongoing := OngoingMatchingInterests(sessionID, state.Day, currentTime)
+
+	data := map[string]any{
+		"current_time":  currentTime,
+		"ongoing":       ongoing,
+		"ongoing_count": len(ongoing),
+	}
+
+	var message string
+	if len(ongoing) == 0 {
+		message = fmt.Sprintf("目前（%s）沒有符合您興趣的進行中議程。可以使用 get_next_anywhere 查看接下來的議程。", currentTime)
+	} else {
+		message = fmt.Sprintf("目前（%s）有 %d 場符合您興趣的議程正在進行，已依相關程度排序，可以直接入場旁聽。", currentTime, len(ongoing))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 37. Get Crowding Tool
+func createGetCrowdingTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_crowding",
+		mcp.WithDescription("Estimate which buildings are crowded right now from the number of sessions running in each. Use when user asks '現在哪裡比較擠', 'which building is busy', or before a tight transfer. Warn that hallways and elevators in the busiest building may be slow and suggest leaving a few minutes early."),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetCrowding(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	timeProvider := &RealTimeProvider{}
+	currentTime := formatTimeForSession(timeProvider.Now())
+
+	return newToolResult(buildCrowdingResponse(internalDay, currentTime)), nil
+}
+
+// buildCrowdingResponse builds the get_crowding response, flagging the busiest buildings once they reach CrowdedBuildingSessions
+func buildCrowdingResponse(day, currentTime string) Response {
+	concurrency := BuildingConcurrencyAt(day, currentTime)
+	busiest := busiestBuildings(concurrency)
+	crowded := len(busiest) > 0 && concurrency[busiest[0]] >= CrowdedBuildingSessions
+
+	data := map[string]any{
+		"day":                 toUserDayFormat(day),
+		"current_time":        currentTime,
+		"concurrent_sessions": concurrency,
+		"busiest_buildings":   busiest,
+		"crowded":             crowded,
+		"crowded_threshold":   CrowdedBuildingSessions,
+	}
+
+	var message string
+	switch {
+	case len(busiest) == 0:
+		message = fmt.Sprintf("%s %s 目前沒有進行中的議程，各棟建築應該都不擁擠。", toUserDayFormat(day), currentTime)
+	case crowded:
+		names := make([]string, len(busiest))
+		for i, building := range busiest {
+			names[i] = buildingDisplayName(building)
+		}
+		message = fmt.Sprintf("%s %s %s 同時有 %d 場議程進行，走廊和電梯可能很擁擠，換場時請提早幾分鐘出發。", toUserDayFormat(day), currentTime, strings.Join(names, "、"), concurrency[busiest[0]])
+	default:
+		message = fmt.Sprintf("%s %s 最多議程的是 %s（%d 場），目前人潮應該還好。", toUserDayFormat(day), currentTime, buildingDisplayName(busiest[0]), concurrency[busiest[0]])
+	}
+
+	return Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+}
+
+// 38. Review Plan Tool
+func createReviewPlanTool() mcp.Tool {
+	return mcp.NewTool(
+		"review_plan",
+		mcp.WithDescription(sessionIdWarning+"Review the user's planned schedule for common mistakes: transfers too short for the walk, no lunch break, overly long gaps, and all sessions in one track. Use before finish_planning or when user asks '幫我檢查行程', 'review my plan', 'anything wrong with my schedule?'. Present the warnings as a checklist with a suggested fix for each."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleReviewPlan(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	warnings := ReviewSchedule(sessionID)
+	data := map[string]any{
+		"warnings":      warnings,
+		"warning_count": len(warnings),
+		"looks_good":    len(warnings) == 0,
+	}
+
+	var message string
+	switch {
+	case len(state.Schedule) == 0:
+		message = "目前還沒有規劃任何議程，沒有可以檢查的內容。請引導用戶先使用 start_planning 開始規劃。"
+	case len(warnings) == 0:
+		message = "行程檢查完成，沒有發現換場過趕、沒空吃午餐、空檔過長或主題單一等問題。"
+	default:
+		message = fmt.Sprintf("行程檢查發現 %d 個可以改善的地方：\n- %s\n請以用戶偏好語言以清單方式說明，並為每一項建議調整方式（例如使用 fix_transfers 處理換場過趕）。", len(warnings), strings.Join(warnings, "\n- "))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 39. Get Remaining Time Tool
+func createGetRemainingTimeTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_remaining_time",
+		mcp.WithDescription(sessionIdWarning+"Compare the end of the user's plan with the end of conference hours and report how much time is left unused. Use when user asks '我今天結束了嗎', 'am I done for the day?', 'how much time is left after my last talk'. When time remains, offer the returned options to fill it."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetRemainingTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	return newToolResult(buildRemainingTimeResponse(sessionID, conferenceNow())), nil
+}
+
+// buildRemainingTimeResponse builds the get_remaining_time response at now, offering sessions
+// that still start in time when conference hours remain after the plan
+func buildRemainingTimeResponse(sessionID string, now time.Time) Response {
+	remaining := RemainingConferenceTime(sessionID, now)
+	conferenceEnd := minutesToTime(ConferenceEndHour * 60)
+
+	data := map[string]any{
+		"remaining_minutes": remaining,
+		"conference_end":    conferenceEnd,
+	}
+
+	if remaining == 0 {
+		message := fmt.Sprintf("今天的議程時間（至 %s）已經沒有剩餘的空檔，您今天的行程已經排滿或已結束。", conferenceEnd)
+		return buildStandardResponse(sessionID, data, message)
+	}
+
+	fromMinutes := ConferenceEndHour*60 - remaining
+	var options []Session
+	recommendations, _ := GetRecommendations(sessionID)
+	for _, session := range recommendations {
+		if timeToMinutes(session.Start) >= fromMinutes {
+			options = append(options, session)
+		}
+	}
+	data["options"] = options
+
+	message := fmt.Sprintf("從 %s 到 %s 還有 %d 分鐘的議程時間沒有安排。", minutesToTime(fromMinutes), conferenceEnd, remaining)
+	if len(options) > 0 {
+		message += fmt.Sprintf(" 有 %d 場議程可以補上，請詢問用戶是否要加入，並可用 choose_session 選擇。", len(options))
+	} else {
+		message += " 這段時間沒有適合的議程，可以逛逛攤位或參加交流活動。"
+	}
+
+	return buildStandardResponse(sessionID, data, message)
+}
+
+// 40. Get Sessions By Tags Tool
+func createGetSessionsByTagsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_sessions_by_tags",
+		mcp.WithDescription("Find the sessions of a day tagged with several topics. Use match='all' when user wants sessions covering every topic, e.g. 'AI 而且跟 Python 有關的議程', and match='any' for either topic, e.g. 'AI 或資安的議程都列出來'. Tags can be given without their emoji, e.g. 'AI' for '🧠 AI'."),
+		mcp.WithArray("tags",
+			mcp.Required(),
+			mcp.Description("Tags to match, e.g. [\"AI\", \"Languages\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("match",
+			mcp.Description("'any' (default) for sessions with at least one of the tags, 'all' for sessions with every tag"),
+			mcp.Enum("any", "all"),
+		),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetSessionsByTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tags, err := request.RequireStringSlice("tags")
+	if err != nil || len(tags) == 0 {
+		return mcp.NewToolResultError("Error: tags must be a non-empty list of tag names"), nil
+	}
+
+	match := request.GetString("match", "any")
+	if match != "any" && match != "all" {
+		return mcp.NewToolResultError("Error: match must be 'any' or 'all'"), nil
+	}
+
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+	internalDay := convertDayFormat(day)
+
+	sessions := FindSessionsByTags(internalDay, tags, match == "all")
+
+	var message string
+	if len(sessions) == 0 {
+		message = fmt.Sprintf("%s 沒有符合 %s 的議程（%s）。可以改用 match='any' 或使用 get_sessions_by_all_tags 查看所有標籤。", toUserDayFormat(internalDay), strings.Join(tags, "、"), match)
+	} else {
+		message = fmt.Sprintf("%s 有 %d 場議程符合 %s（%s），已依時間排序。請列出每場的時間、教室、標題與標籤。", toUserDayFormat(internalDay), len(sessions), strings.Join(tags, "、"), match)
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]any{
+			"day":           toUserDayFormat(internalDay),
+			"tags":          tags,
+			"match":         match,
+			"sessions":      sessions,
+			"session_count": len(sessions),
+		},
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 41. Off Campus Break Tool
+func createOffCampusBreakTool() mcp.Tool {
+	return mcp.NewTool(
+		"off_campus_break",
+		mcp.WithDescription(sessionIdWarning+"Find the first break in the user's plan long enough to leave campus and come back, e.g. for food outside. Use when user asks '我有時間出去吃飯嗎', 'can I grab food off campus?', 'is there a break long enough to leave the venue'. Walking between the rooms and the campus gate is added to the round trip."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithNumber("round_trip_minutes",
+			mcp.Description(fmt.Sprintf("Minutes needed outside campus, from leaving the gate to coming back. Optional - defaults to %d", DefaultRoundTripMinutes)),
+		),
+	)
+}
+
+func handleOffCampusBreak(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	roundTrip := request.GetInt("round_trip_minutes", DefaultRoundTripMinutes)
+	if roundTrip <= 0 {
+		return mcp.NewToolResultError("Error: round_trip_minutes must be a positive number"), nil
+	}
+
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	start, end, ok := FindBreakForExcursion(sessionID, roundTrip)
+	data := map[string]any{
+		"round_trip_minutes": roundTrip,
+		"found":              ok,
+	}
+
+	var message string
+	if ok {
+		data["start"] = start
+		data["end"] = end
+		message = fmt.Sprintf("%s-%s 的空檔（%d 分鐘）足夠離開校園來回 %d 分鐘，記得把走到校門的時間算進去，並準時回來。", start, end, timeToMinutes(end)-timeToMinutes(start), roundTrip)
+	} else {
+		message = fmt.Sprintf("您的行程中沒有足夠離開校園來回 %d 分鐘的空檔。可以建議在校內用餐，或放棄一場議程騰出時間。", roundTrip)
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 42. Search Sessions Tool
+func createSearchSessionsTool() mcp.Tool {
+	return mcp.NewTool(
+		"search_sessions",
+		mcp.WithDescription("Search sessions by keyword and/or tags. The keyword is matched case-insensitively against titles, abstracts and speaker names. Use when user looks for a specific talk or topic, e.g. '有沒有講 Kubernetes 的議程', 'find talks by Alice', 'any Rust sessions?'. Searches both days unless a day is given. When total_matches exceeds the returned sessions, tell the user how many more there are and suggest narrowing the search."),
+		mcp.WithString("query",
+			mcp.Description("Keyword to search for. Optional if tags are given"),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Only return sessions carrying any of these tags. Tags must match exactly, emoji included, as listed by get_sessions_by_all_tags, e.g. [\"💻 System\"]. Optional"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("day",
+			mcp.Description("Day to search ('Aug9' or 'Aug10'). Optional - searches both days when omitted"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of sessions to return. Optional - defaults to %d", DefaultSearchLimit)),
+		),
+	)
+}
+
+func handleSearchSessions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := strings.TrimSpace(request.GetString("query", ""))
+	tags := request.GetStringSlice("tags", nil)
+	if query == "" && len(tags) == 0 {
+		return mcp.NewToolResultError("Error: query or tags is required"), nil
+	}
+
+	limit := request.GetInt("limit", DefaultSearchLimit)
+	if limit <= 0 {
+		return mcp.NewToolResultError("Error: limit must be a positive number"), nil
+	}
+
+	var internalDay string
+	if day := request.GetString("day", ""); day != "" {
+		if !IsValidDay(day) {
+			return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+		}
+		internalDay = convertDayFormat(day)
+	}
+
+	matches := SearchSessions(internalDay, query, tags)
+	sessions := matches[:min(limit, len(matches))]
+
+	data := map[string]any{
+		"query":         query,
+		"tags":          tags,
+		"sessions":      sessions,
+		"total_matches": len(matches),
+		"truncated":     len(matches) > len(sessions),
+	}
+	if internalDay != "" {
+		data["day"] = toUserDayFormat(internalDay)
+	}
+
+	var message string
+	switch {
+	case len(matches) == 0:
+		message = "找不到符合條件的議程。請建議用戶換個關鍵字，或使用 get_sessions_by_all_tags 依標籤瀏覽。"
+	case len(matches) > len(sessions):
+		message = fmt.Sprintf("共找到 %d 場符合的議程，以下列出依時間排序的前 %d 場。請告知用戶還有 %d 場未列出，可以加上日期或標籤縮小範圍。", len(matches), len(sessions), len(matches)-len(sessions))
+	default:
+		message = fmt.Sprintf("共找到 %d 場符合的議程，已依日期與時間排序。請列出每場的日期、時間、教室、標題與講者。", len(matches))
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 43. Get Speaker Sessions Tool
+func createGetSpeakerSessionsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_speaker_sessions",
+		mcp.WithDescription("List every session by a speaker across both days. Partial names match, e.g. '張' finds '張三', and sessions with several speakers are included. Use when user asks '某某講者有哪些議程', 'what else is Alice talking about?', 'I want to catch all talks by ...'. Mention the day of each session."),
+		mcp.WithString("speaker",
+			mcp.Required(),
+			mcp.Description("Speaker name or part of it"),
+		),
+	)
+}
+
+func handleGetSpeakerSessions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	speaker, err := request.RequireString("speaker")
+	if err != nil || strings.TrimSpace(speaker) == "" {
+		return mcp.NewToolResultError("Error: speaker is required"), nil
+	}
+
+	sessions := FindSessionsBySpeaker(speaker)
+
+	var message string
+	if len(sessions) == 0 {
+		message = fmt.Sprintf("找不到講者名稱包含「%s」的議程。請確認講者名稱，或改用 search_sessions 以關鍵字搜尋。", speaker)
+	} else {
+		message = fmt.Sprintf("找到 %d 場講者名稱包含「%s」的議程，已依日期與時間排序。請列出每場的日期、時間、教室、標題與完整講者名單。", len(sessions), speaker)
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]any{
+			"speaker":       speaker,
+			"sessions":      sessions,
+			"session_count": len(sessions),
+		},
+		Message: message,
+	}
+
+	return newToolResult(response), nil
+}
+
+// 44. Get All Sessions Tool
+func createGetAllSessionsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_all_sessions",
+		mcp.WithDescription("Page through every session of a day in a stable order (start time, then room). Meant for clients that mirror or cache the full schedule, not for answering a user's question - prefer search_sessions or get_options for that. Keep calling with next_offset while has_more is true."),
+		mcp.WithString("day",
+			mcp.Description("Day to list ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of sessions to skip. Optional - defaults to 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Sessions per page, at most %d. Optional - defaults to %d", MaxPageLimit, DefaultPageLimit)),
+		),
+		mcp.WithString("include_detail",
+			mcp.Description("Set to 'true' to include abstracts and difficulty in the returned sessions"),
+		),
+	)
+}
+
+func handleGetAllSessions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+
+	offset := request.GetInt("offset", 0)
+	if offset < 0 {
+		return mcp.NewToolResultError("Error: offset must not be negative"), nil
+	}
+	limit := request.GetInt("limit", DefaultPageLimit)
+	if limit <= 0 || limit > MaxPageLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: limit must be between 1 and %d", MaxPageLimit)), nil
+	}
+	includeDetail := request.GetString("include_detail", "") == "true"
+
+	internalDay := convertDayFormat(day)
+	sessions, total := AllSessionsPaged(internalDay, offset, limit)
+	if offset > 0 && offset >= total && total > 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Error: offset %d is past the last session (%d sessions in total)", offset, total)), nil
+	}
+	if !includeDetail {
+		sessions = getSimplifiedSessions(sessions)
+	}
+
+	hasMore := offset+len(sessions) < total
+	data := map[string]any{
+		"day":            toUserDayFormat(internalDay),
+		"sessions":       sessions,
+		"offset":         offset,
+		"limit":          limit,
+		"total":          total,
+		"has_more":       hasMore,
+		"include_detail": includeDetail,
+	}
+	if hasMore {
+		data["next_offset"] = offset + len(sessions)
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: fmt.Sprintf("第 %d-%d 場，共 %d 場議程。", min(offset+1, total), offset+len(sessions), total),
+	}
+
+	return newToolResult(response), nil
+}
+
+// 45. Check Favorites Tool
+func createCheckFavoritesTool() mcp.Tool {
+	return mcp.NewTool(
+		"check_favorites",
+		mcp.WithDescription(sessionIdWarning+"Star sessions as favorites without adding them to the schedule, and report which favorites clash with sessions already on the schedule. Use when user says '先幫我收藏這場', 'star this talk for later', or asks '我收藏的議程有跟行程衝突嗎'. For each clash, let the user decide which session to keep; don't change the schedule for them."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+		mcp.WithArray("add",
+			mcp.Description("Session codes to add to the favorites before checking. Optional"),
+			mcp.WithStringItems(),
+		),
+	)
+}
+
+func handleCheckFavorites(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	if codes := request.GetStringSlice("add", nil); len(codes) > 0 {
+		if err := AddFavorites(sessionID, codes); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error: %v", err)), nil
+		}
+	}
+
+	// One snapshot for the whole response, taken after the favorites were added
+	state := GetUserStateSnapshot(sessionID)
+	if state == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+	committed := activeSessions(state.Schedule)
+
+	favorites := favoritesConflictingWithSchedule(state)
+	conflicts := make([]map[string]any, 0, len(favorites))
+	for _, favorite := range favorites {
+		conflicts = append(conflicts, map[string]any{
+			"favorite":     favorite,
+			"clashes_with": findConflictingSessions(favorite, committed),
+		})
+	}
+
+	data := map[string]any{
+		"favorites":      state.Favorites,
+		"conflicts":      conflicts,
+		"conflict_count": len(conflicts),
+	}
+
+	var message string
+	switch {
+	case len(state.Favorites) == 0:
+		message = "目前沒有收藏任何議程。用戶可以在看到有興趣但還不確定的議程時，用 check_favorites 的 add 先收藏起來。"
+	case len(conflicts) == 0:
+		message = fmt.Sprintf("已收藏 %d 場議程，都沒有跟行程中的議程時間衝突。", len(state.Favorites))
+	default:
+		message = fmt.Sprintf("已收藏 %d 場議程，其中 %d 場跟行程中的議程時間衝突。請逐一列出收藏的議程與衝突的議程（時間、教室、標題），詢問用戶要保留哪一場；不要自動修改行程。", len(state.Favorites), len(conflicts))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 46. Summarize Remaining Day Tool
+func createSummarizeRemainingDayTool() mcp.Tool {
+	return mcp.NewTool(
+		"summarize_remaining_day",
+		mcp.WithDescription(sessionIdWarning+"One-call overview of the rest of the user's planned day: current status, the next sessions with walking routes, total walking still ahead, and when the plan and the conference end. Use when user asks '我今天接下來的行程是什麼', 'what does the rest of my day look like?', 'walk me through the rest of today'. Prefer it over calling get_next_session and get_schedule separately."),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleSummarizeRemainingDay(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	response, err := buildRemainingDayResponse(sessionID, conferenceNow())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return newToolResult(response), nil
+}
+
+// buildRemainingDayResponse builds the summarize_remaining_day response at now
+// Returns ErrCannotFindSession when the session does not exist or has expired
+func buildRemainingDayResponse(sessionID string, now time.Time) (Response, error) {
+	summary := SummarizeRemainingDay(sessionID, now)
+	if summary == nil {
+		return Response{}, ErrCannotFindSession
+	}
+	nextSessions := summary["next_sessions"].([]UpcomingSession)
+
+	var message string
+	switch {
+	case summary["plan_end"] == nil:
+		message = "目前還沒有規劃任何議程。請引導用戶先使用 start_planning 開始規劃。"
+	case len(nextSessions) == 0 && summary["current_session"] == nil:
+		message = fmt.Sprintf("今天規劃的議程都已結束（最後一場在 %s 結束）。可以用 get_remaining_time 看看 %s 前還能補哪些議程。", summary["plan_end"], summary["conference_end"])
+	default:
+		message = fmt.Sprintf("接下來還有 %d 場議程，行程在 %s 結束，之後還要走約 %d 分鐘。",
+			summary["remaining_sessions"], summary["plan_end"], summary["remaining_walking_minutes"])
+		if current, ok := summary["current_session"].(Session); ok {
+			message += fmt.Sprintf("\n\n🎯 目前：%s-%s 在 %s「%s」", current.Start, current.End, current.Room, current.Title)
+		}
+		for _, next := range nextSessions {
+			message += fmt.Sprintf("\n⏭️ %s-%s 在 %s「%s」", next.Session.Start, next.Session.End, next.Session.Room, next.Session.Title)
+			if next.Route != nil && next.Route.WalkingTime > 0 {
+				message += fmt.Sprintf("（步行約 %d 分鐘", next.Route.WalkingTime)
+				if !next.Route.EnoughTime {
+					message += "，時間很趕"
+				}
+				message += "）"
+			}
+		}
+		message += "\n\n請以時間軸方式簡短呈現，並提醒換場較趕的地方。"
+	}
+
+	return buildStandardResponse(sessionID, summary, message), nil
+}
+
+// 47. Get Free Slots Tool
+func createGetFreeSlotsTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_free_slots",
+		mcp.WithDescription(sessionIdWarning+fmt.Sprintf("List the open windows in the user's planned day, from conference start to end, that are at least %d minutes long. Use when user asks '我什麼時候有空', 'where are the gaps in my schedule?', 'when am I free?'. Summarize them like 'free 11:30-13:00 and after 16:00' and offer to fill them with get_options.", MinSessionMinutes)),
+		mcp.WithString("sessionId",
+			mcp.Description("User's session ID"),
+		),
+	)
+}
+
+func handleGetFreeSlots(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessionID, err := request.RequireString("sessionId")
+	if err != nil {
+		return mcp.NewToolResultError(ErrSessionIDRequired.Error()), nil
+	}
+
+	if GetUserState(sessionID) == nil {
+		return mcp.NewToolResultError(ErrCannotFindSession.Error()), nil
+	}
+
+	slots := GetFreeSlots(sessionID)
+	data := map[string]any{
+		"free_slots": slots,
+		"slot_count": len(slots),
+	}
+
+	var message string
+	if len(slots) == 0 {
+		message = fmt.Sprintf("行程已經排滿，沒有 %d 分鐘以上的空檔。", MinSessionMinutes)
+	} else {
+		var windows []string
+		for _, slot := range slots {
+			windows = append(windows, fmt.Sprintf("%s-%s（%s 分鐘）", slot["start"], slot["end"], slot["duration"]))
+		}
+		message = fmt.Sprintf("找到 %d 段空檔：%s。請簡短告訴用戶哪些時段有空，並詢問是否要用 get_options 補上議程。", len(slots), strings.Join(windows, "、"))
+	}
+
+	response := buildStandardResponse(sessionID, data, message)
+
+	return newToolResult(response), nil
+}
+
+// 48. Get Now Tool
+func createGetNowTool() mcp.Tool {
+	return mcp.NewTool(
+		"get_now",
+		mcp.WithDescription(fmt.Sprintf("Show what is happening right now across all rooms, grouped by building, plus sessions starting within the next %d minutes. Needs no sessionId or planned schedule - use it for walk-in attendees asking '現在有什麼議程', 'what's on right now?', 'anything starting soon?'. Present it as a short per-building list.", StartingSoonMinutes)),
+		mcp.WithString("day",
+			mcp.Description("Day to query ('Aug9' or 'Aug10'). Optional - defaults to current COSCUP day"),
+		),
+	)
+}
+
+func handleGetNow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	day := request.GetString("day", "")
+	if day == "" {
+		day = defaultQueryDay()
+	}
+	if !IsValidDay(day) {
+		return mcp.NewToolResultError("Error: day must be '" + DayAug9 + "' or '" + DayAug10 + "'"), nil
+	}
+
+	internalDay := convertDayFormat(day)
+
+	timeProvider := &RealTimeProvider{}
+	now := timeProvider.Now()
+	currentTime := formatTimeForSession(now)
+
+	grid := LiveGrid(internalDay, currentTime)
+	startingSoon := SessionsStartingSoon(internalDay, currentTime, StartingSoonMinutes)
+
+	running := 0
+	for _, sessions := range grid {
+		running += len(sessions)
+	}
+
+	data := map[string]any{
+		"day":                toUserDayFormat(internalDay),
+		"current_time":       currentTime,
+		"now_by_building":    grid,
+		"running_count":      running,
+		"next_starting_soon": startingSoon,
+	}
+
+	var message string
+	if running == 0 && len(startingSoon) == 0 {
+		message = fmt.Sprintf("%s %s 目前沒有進行中的議程，接下來 %d 分鐘內也沒有議程開始。", toUserDayFormat(internalDay), currentTime, StartingSoonMinutes)
+	} else {
+		message = fmt.Sprintf("%s %s 共有 %d 場議程進行中，%d 分鐘內有 %d 場即將開始。請以用戶偏好語言按建築物簡短列出，並標出即將開始的議程。",
+			toUserDayFormat(internalDay), currentTime, running, StartingSoonMinutes, len(startingSoon))
+	}
+	if !isInCOSCUPPeriod(now) {
+		message += " 目前非 COSCUP 舉辦時間，以上是該日同一時刻的議程資料。"
+	}
+
+	response := Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	}
+
+	return newToolResult(response), nil
 }
 
 // GetToolHandlers returns a map of tool names to their handlers using new API
 func GetToolHandlers() map[string]server.ToolHandlerFunc {
 	return map[string]server.ToolHandlerFunc{
-		"start_planning":     handleStartPlanning,
-		"choose_session":     handleChooseSession,
-		"get_options":        handleGetOptions,
-		"get_schedule":       handleGetSchedule,
-		"get_next_session":   handleGetNextSession,
-		"get_session_detail": handleGetSessionDetail,
-		"finish_planning":    handleFinishPlanning,
-		"get_room_schedule":  handleGetRoomSchedule,
-		"get_venue_map":      handleGetVenueMap,
-		"help":               handleHelp,
+		"start_planning":           handleStartPlanning,
+		"choose_session":           handleChooseSession,
+		"get_options":              handleGetOptions,
+		"get_schedule":             handleGetSchedule,
+		"get_next_session":         handleGetNextSession,
+		"get_session_detail":       handleGetSessionDetail,
+		"finish_planning":          handleFinishPlanning,
+		"get_room_schedule":        handleGetRoomSchedule,
+		"get_venue_map":            handleGetVenueMap,
+		"help":                     handleHelp,
+		"get_walking_distance":     handleGetWalkingDistance,
+		"get_ending_soon":          handleGetEndingSoon,
+		"get_arrival_advice":       handleGetArrivalAdvice,
+		"get_track_catalog":        handleGetTrackCatalog,
+		"get_balance_score":        handleGetBalanceScore,
+		"lock_session":             handleLockSession,
+		"optimize_schedule":        handleOptimizeSchedule,
+		"get_livestreamed_now":     handleGetLivestreamedNow,
+		"get_all_compatible":       handleGetAllCompatible,
+		"resolve_schedule":         handleResolveSchedule,
+		"get_unsampled_tracks":     handleGetUnsampledTracks,
+		"compare_with_friend":      handleCompareWithFriend,
+		"get_floor_sessions":       handleGetFloorSessions,
+		"get_missed_sessions":      handleGetMissedSessions,
+		"preview_token":            handlePreviewToken,
+		"get_all_rooms":            handleGetAllRooms,
+		"suggest_lunch":            handleSuggestLunch,
+		"get_sessions_by_all_tags": handleGetSessionsByAllTags,
+		"set_mobility":             handleSetMobility,
+		"find_free_block":          handleFindFreeBlock,
+		"get_next_anywhere":        handleGetNextAnywhere,
+		"fix_transfers":            handleFixTransfers,
+		"import_ics":               handleImportICS,
+		"get_starter_plan":         handleGetStarterPlan,
+		"get_ongoing_for_me":       handleGetOngoingForMe,
+		"get_crowding":             handleGetCrowding,
+		"review_plan":              handleReviewPlan,
+		"get_remaining_time":       handleGetRemainingTime,
+		"get_sessions_by_tags":     handleGetSessionsByTags,
+		"off_campus_break":         handleOffCampusBreak,
+		"search_sessions":          handleSearchSessions,
+		"get_speaker_sessions":     handleGetSpeakerSessions,
+		"get_all_sessions":         handleGetAllSessions,
+		"check_favorites":          handleCheckFavorites,
+		"summarize_remaining_day":  handleSummarizeRemainingDay,
+		"get_free_slots":           handleGetFreeSlots,
+		"get_now":                  handleGetNow,
+		"recreate_session":         handleRecreateSession,
 	}
 }
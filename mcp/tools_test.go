@@ -0,0 +1,638 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mcp-coscup/mcp/testutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tests for tool handlers in tools.go
+
+// callTool invokes a tool handler with the given arguments and decodes its JSON response
+// Data is decoded into a generic map so tests can inspect fields by their JSON names
+func callTool(t *testing.T, name string, args map[string]any) Response {
+	t.Helper()
+
+	handler, exists := GetToolHandlers()[name]
+	if !exists {
+		t.Fatalf("Tool %s is not registered", name)
+	}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+
+	result, err := handler(context.Background(), request)
+	testutil.AssertNoError(t, err, "Handler should not return a Go error")
+	if result.IsError {
+		t.Fatalf("Tool %s returned an error result: %+v", name, result.Content)
+	}
+	testutil.AssertEqual(t, 2, len(result.Content), "Result should have a JSON block and a summary")
+
+	jsonBlock, ok := result.Content[0].(mcp.TextContent)
+	testutil.AssertEqual(t, true, ok, "First content item should be text")
+	summary, ok := result.Content[1].(mcp.TextContent)
+	testutil.AssertEqual(t, true, ok, "Second content item should be text")
+
+	var response Response
+	if err := json.Unmarshal([]byte(jsonBlock.Text), &response); err != nil {
+		t.Fatalf("Tool %s returned invalid JSON: %v", name, err)
+	}
+	testutil.AssertEqual(t, response.Message, summary.Text, "Summary should be the response message")
+
+	return response
+}
+
+// responseData returns the decoded data object of a tool response
+func responseData(t *testing.T, response Response) map[string]any {
+	t.Helper()
+	data, ok := response.Data.(map[string]any)
+	if !ok {
+		t.Fatalf("Response data should be a JSON object, got %T", response.Data)
+	}
+	return data
+}
+
+func TestNewToolResultFormat(t *testing.T) {
+	result := newToolResult(Response{
+		Success: true,
+		Data:    map[string]any{"session_id": "user_09_test", "count": 2},
+		Message: "摘要訊息",
+	})
+
+	testutil.AssertEqual(t, false, result.IsError, "Result should not be an error")
+	testutil.AssertEqual(t, 2, len(result.Content), "Result should have two content items")
+
+	var decoded map[string]any
+	err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded)
+	testutil.AssertNoError(t, err, "First content item should be valid JSON")
+	testutil.AssertEqual(t, true, decoded["success"], "JSON should use the response field names")
+	testutil.AssertEqual(t, "user_09_test", decoded["data"].(map[string]any)["session_id"], "JSON should include data")
+	testutil.AssertEqual(t, "摘要訊息", result.Content[1].(mcp.TextContent).Text, "Second content item should be the summary")
+}
+
+func TestMarshalResponseKeepsURLsReadable(t *testing.T) {
+	encoded, err := marshalResponse(buildStandardResponse("user_09_test", map[string]any{
+		"session": Session{Code: "JSON-001", URL: "https://pretalx.coscup.org/coscup-2025/talk/JSON-001/?a=1&b=<2>"},
+		"route":   &RouteInfo{FromRoom: "AU", ToRoom: "TR211", WalkingTime: 4},
+	}, "摘要"))
+	testutil.AssertNoError(t, err, "Response should encode")
+
+	if !strings.Contains(encoded, "?a=1&b=<2>") {
+		t.Errorf("URLs should not be HTML-escaped, got %s", encoded)
+	}
+	if strings.HasSuffix(encoded, "\n") {
+		t.Error("Encoded response should not end with a newline")
+	}
+
+	var decoded map[string]any
+	testutil.AssertNoError(t, json.Unmarshal([]byte(encoded), &decoded), "Encoded response should be valid JSON")
+	data := decoded["data"].(map[string]any)
+	testutil.AssertEqual(t, "user_09_test", data["sessionId"], "sessionId should be a JSON field")
+	testutil.AssertEqual(t, "TR211", data["route"].(map[string]any)["ToRoom"], "Nested pointers should be encoded as objects")
+}
+
+func TestToolsReturnJSONAndSummary(t *testing.T) {
+	for _, name := range []string{"help", "get_venue_map", "get_track_catalog"} {
+		t.Run(name, func(t *testing.T) {
+			response := callTool(t, name, map[string]any{})
+			testutil.AssertEqual(t, true, response.Success, "Tool should succeed")
+			testutil.AssertEqual(t, true, response.Message != "", "Summary should be present")
+		})
+	}
+}
+
+func TestFinishPlanningIncludesStatistics(t *testing.T) {
+	state := &UserState{
+		SessionID: "test_finish_statistics",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "FIN-001", Title: "Morning", Start: "10:00", End: "10:30", Room: "AU", Track: "Main Track"},
+			{Code: "FIN-002", Title: "Late Morning", Start: "10:40", End: "11:10", Room: "TR211", Track: "Rust"},
+		},
+		LastEndTime: "11:10",
+	}
+	storeTestUserState(t, state)
+
+	response := callTool(t, "finish_planning", map[string]any{"sessionId": state.SessionID})
+
+	stats, ok := responseData(t, response)["statistics"].(map[string]any)
+	testutil.AssertEqual(t, true, ok, "Finish response should include a statistics block")
+	for _, field := range []string{"track_coverage", "total_talk_minutes", "longest_gap_minutes", "total_walking_minutes"} {
+		_, exists := stats[field]
+		testutil.AssertEqual(t, true, exists, "Statistics should include "+field)
+	}
+	testutil.AssertEqual(t, true, strings.Contains(response.Message, "今日行程小結"), "Finish message should include the wrap-up summary")
+}
+
+func TestDayDefaultingToolsOutsideCOSCUP(t *testing.T) {
+	if isInCOSCUPPeriod(conferenceNow()) {
+		t.Skip("Running during COSCUP - day defaults to the current day")
+	}
+
+	// Tools that derive the day from the current time should all fall back to the same default day
+	expectedDay := toUserDayFormat(convertDayFormat(getQueryDay(conferenceNow())))
+
+	roomSchedule := callTool(t, "get_room_schedule", map[string]any{"room": "AU"})
+	testutil.AssertEqual(t, true, roomSchedule.Success, "get_room_schedule should succeed outside COSCUP")
+	testutil.AssertEqual(t, expectedDay, responseData(t, roomSchedule)["day"], "get_room_schedule should use the default day")
+
+	endingSoon := callTool(t, "get_ending_soon", map[string]any{})
+	testutil.AssertEqual(t, true, endingSoon.Success, "get_ending_soon should succeed outside COSCUP")
+	testutil.AssertEqual(t, expectedDay, responseData(t, endingSoon)["day"], "get_ending_soon should use the default day")
+
+	now := callTool(t, "get_now", map[string]any{})
+	testutil.AssertEqual(t, true, now.Success, "get_now should succeed without a sessionId")
+	nowData := responseData(t, now)
+	testutil.AssertEqual(t, expectedDay, nowData["day"], "get_now should use the default day")
+	if _, ok := nowData["now_by_building"].(map[string]any); !ok {
+		t.Errorf("get_now should return the live grid, got %v", nowData["now_by_building"])
+	}
+}
+
+func TestGetSessionDetailExposesStreamURL(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "DETAIL-LIVE", Title: "Streamed", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", StreamURL: "https://example.org/live/au"},
+			{Code: "DETAIL-OFFLINE", Title: "Offline", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+
+	streamed := responseData(t, callTool(t, "get_session_detail", map[string]any{"sessionCode": "DETAIL-LIVE"}))
+	testutil.AssertEqual(t, "https://example.org/live/au", streamed["stream_url"], "Detail should expose the stream URL")
+
+	offline := responseData(t, callTool(t, "get_session_detail", map[string]any{"sessionCode": "DETAIL-OFFLINE"}))
+	_, hasStream := offline["stream_url"]
+	testutil.AssertEqual(t, false, hasStream, "Detail should not mention a stream for offline sessions")
+}
+
+func TestGetVenueMapListsRoomsByBuilding(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.10": {
+			{Code: "MAP-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.10"},
+			{Code: "MAP-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.10"},
+		},
+	})
+
+	data := responseData(t, callTool(t, "get_venue_map", map[string]any{"day": DayAug10}))
+
+	testutil.AssertEqual(t, "https://coscup.org/2025/venue/", data["venue_map_url"], "Map URL should be kept")
+	buildings, ok := data["buildings"].(map[string]any)
+	testutil.AssertEqual(t, true, ok, "Buildings should keep their code to name shape")
+	testutil.AssertEqual(t, "視聽館 (Audio-Visual Hall)", buildings[BuildingAU], "Building labels should be unchanged")
+	details, ok := data["building_details"].([]any)
+	testutil.AssertEqual(t, true, ok, "Building details should be a list")
+	testutil.AssertEqual(t, 3, len(details), "All three buildings should be described")
+	_, hasFacilities := details[0].(map[string]any)["facilities"]
+	testutil.AssertEqual(t, true, hasFacilities, "Building details should list facilities")
+	rooms, ok := data["rooms_by_building"].(map[string]any)
+	testutil.AssertEqual(t, true, ok, "Response should include rooms by building")
+	testutil.AssertEqual(t, "AU", rooms[BuildingAU].([]any)[0], "AU should list its room")
+	testutil.AssertEqual(t, "TR211", rooms[BuildingTR].([]any)[0], "TR should list its room")
+}
+
+func TestGetRoomScheduleHintsOtherDay(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "ROOMHINT-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+		"Aug.10": {
+			{Code: "ROOMHINT-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.10"},
+		},
+	})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"room": "TR211", "day": DayAug9}
+
+	result, err := handleGetRoomSchedule(context.Background(), request)
+	testutil.AssertNoError(t, err, "Handler should not return a Go error")
+	testutil.AssertEqual(t, true, result.IsError, "Empty room should still be an error result")
+
+	text := result.Content[0].(mcp.TextContent).Text
+	testutil.AssertEqual(t, true, strings.Contains(text, "TR211 has no sessions on Aug9 but 1 on Aug10"), "Error should hint at the other day")
+}
+
+func TestResolveScheduleListsCompetingSessions(t *testing.T) {
+	t.Cleanup(func() { ReloadData(COSCUPData) })
+
+	first := Session{Code: "TOOL-MOVE-A", Title: "Session A", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	second := Session{Code: "TOOL-MOVE-B", Title: "Session B", Start: "11:00", End: "11:30", Room: "TR211", Day: "Aug.9"}
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {first}, "TR211": {second}},
+	})
+
+	storeTestUserState(t, &UserState{
+		SessionID: "test_resolve_schedule_tool",
+		Day:       "Aug.9",
+		Schedule:  []Session{first, second},
+	})
+
+	second.Start, second.End = "10:00", "10:30"
+	ReloadData(map[string]map[string][]Session{
+		"Aug.9": {"AU": {first}, "TR211": {second}},
+	})
+
+	data := responseData(t, callTool(t, "resolve_schedule", map[string]any{"sessionId": "test_resolve_schedule_tool"}))
+	testutil.AssertEqual(t, float64(1), data["conflict_count"], "Tool should report the conflict")
+
+	options := data["conflicts"].([]any)[0].(map[string]any)["options"].([]any)
+	testutil.AssertEqual(t, 2, len(options), "Tool should enumerate both competing sessions")
+
+	data = responseData(t, callTool(t, "resolve_schedule", map[string]any{
+		"sessionId": "test_resolve_schedule_tool",
+		"keep":      "TOOL-MOVE-A",
+	}))
+	testutil.AssertEqual(t, float64(0), data["conflict_count"], "Keeping one session should clear the conflict")
+}
+
+func TestGetScheduleVerbosity(t *testing.T) {
+	storeTestUserState(t, &UserState{
+		SessionID:   "test_schedule_verbosity",
+		Day:         "Aug.9",
+		Schedule:    []Session{{Code: "VERB-S1", Title: "Only Session", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}},
+		LastEndTime: "10:30",
+	})
+
+	terse := responseData(t, callTool(t, "get_schedule", map[string]any{"sessionId": "test_schedule_verbosity", "verbosity": "terse"}))
+	_, hasTimeline := terse["timeline_view"]
+	testutil.AssertEqual(t, false, hasTimeline, "Terse schedule should omit the timeline view")
+
+	rich := responseData(t, callTool(t, "get_schedule", map[string]any{"sessionId": "test_schedule_verbosity", "verbosity": "rich"}))
+	_, hasTimeline = rich["timeline_view"]
+	_, hasStatistics := rich["statistics"]
+	testutil.AssertEqual(t, true, hasTimeline, "Rich schedule should include the timeline view")
+	testutil.AssertEqual(t, true, hasStatistics, "Rich schedule should include statistics")
+}
+
+func TestGetScheduleEmptyStateGuidance(t *testing.T) {
+	storeTestUserState(t, &UserState{SessionID: "test_schedule_empty_state", Day: "Aug.9", LastEndTime: "08:00"})
+
+	resp := callTool(t, "get_schedule", map[string]any{"sessionId": "test_schedule_empty_state"})
+	data := responseData(t, resp)
+
+	testutil.AssertEqual(t, true, data["is_empty"], "Empty schedule should be flagged")
+	testutil.AssertEqual(t, true, strings.Contains(resp.Message, "get_options"), "Message should guide the user to get_options")
+	for _, field := range []string{"timeline_view", "backups", "tight_transfers", "total_transfers", "last_end_time", "statistics"} {
+		_, exists := data[field]
+		testutil.AssertEqual(t, false, exists, "Empty schedule should not include "+field)
+	}
+}
+
+func TestHelpLanguage(t *testing.T) {
+	english := responseData(t, callTool(t, "help", map[string]any{"language": "en"}))
+	testutil.AssertEqual(t, "en", english["language"], "Language should be reported")
+	content := english["help_content"].(string)
+	testutil.AssertEqual(t, true, strings.Contains(content, "COSCUP Planning Assistant Guide"), "English guide should be returned")
+	testutil.AssertEqual(t, true, strings.Contains(content, "\"Where is my next session?\""), "English examples should be included")
+	testutil.AssertEqual(t, false, strings.Contains(content, "使用範例"), "English guide should not contain the Chinese examples")
+
+	chinese := responseData(t, callTool(t, "help", map[string]any{}))
+	testutil.AssertEqual(t, "zh", chinese["language"], "Chinese should be the default")
+	testutil.AssertEqual(t, true, strings.Contains(chinese["help_content"].(string), "\"我想參加 AI 相關的議程\""), "Chinese examples should be included")
+
+	fallback := responseData(t, callTool(t, "help", map[string]any{"language": "fr"}))
+	testutil.AssertEqual(t, "zh", fallback["language"], "Unknown languages should fall back to Chinese")
+}
+
+func TestNextAnywhereTerminalMessage(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LATE-001", Title: "Last Talk", Start: "16:00", End: "16:30", Room: "AU", Day: "Aug.9"},
+			{Code: "LATE-002", Title: "Closing", Start: "16:30", End: "17:00", Room: "AU", Day: "Aug.9"},
+			{Code: "LATE-003", Title: "Hacking Corner", Start: "13:00", End: "18:00", Room: "Hallway outside TR309", Day: "Aug.9"},
+		},
+	})
+
+	next := buildNextAnywhereResponse("Aug.9", "16:10")
+	nextData := responseData(t, next)
+	testutil.AssertEqual(t, "LATE-002", recommendationCodes(nextData["sessions"].([]Session)), "Next session should be listed")
+	testutil.AssertEqual(t, nil, nextData["no_more_sessions"], "Not terminal while sessions remain")
+
+	terminal := buildNextAnywhereResponse("Aug.9", "16:45")
+	data := responseData(t, terminal)
+	testutil.AssertEqual(t, true, data["no_more_sessions"], "Should report that nothing starts later")
+	testutil.AssertEqual(t, "LATE-003", recommendationCodes(data["social_activities"].([]Session)), "Running social activities should be suggested")
+	testutil.AssertEqual(t, true, strings.Contains(terminal.Message, "今天已經沒有新的議程"), "Message should be terminal")
+	testutil.AssertEqual(t, true, strings.Contains(terminal.Message, "Hacking Corner"), "Message should mention the social activity")
+}
+
+func TestCrowdingResponse(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "CROWD-TR1", Start: "10:00", End: "10:30", Room: "TR509", Day: "Aug.9"},
+			{Code: "CROWD-TR2", Start: "10:00", End: "10:30", Room: "TR510", Day: "Aug.9"},
+			{Code: "CROWD-TR3", Start: "10:00", End: "10:30", Room: "TR511", Day: "Aug.9"},
+			{Code: "CROWD-TR4", Start: "10:00", End: "10:30", Room: "TR512", Day: "Aug.9"},
+			{Code: "CROWD-TR5", Start: "10:00", End: "10:30", Room: "TR513", Day: "Aug.9"},
+			{Code: "CROWD-TR6", Start: "10:00", End: "10:30", Room: "TR514", Day: "Aug.9"},
+			{Code: "CROWD-TR7", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"},
+			{Code: "CROWD-TR8", Start: "10:00", End: "10:30", Room: "TR516", Day: "Aug.9"},
+			{Code: "CROWD-AU", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "CROWD-RB1", Start: "10:10", End: "10:40", Room: "RB101", Day: "Aug.9"},
+			{Code: "CROWD-RB2", Start: "11:00", End: "11:30", Room: "RB102", Day: "Aug.9"},
+		},
+	})
+
+	peak := buildCrowdingResponse("Aug.9", "10:15")
+	data := responseData(t, peak)
+	testutil.AssertEqual(t, true, data["crowded"], "Eight concurrent TR sessions should count as crowded")
+	testutil.AssertEqual(t, true, strings.Contains(peak.Message, "研揚大樓"), "Message should name the busiest building")
+
+	quiet := responseData(t, buildCrowdingResponse("Aug.9", "11:10"))
+	testutil.AssertEqual(t, false, quiet["crowded"], "A single session should not count as crowded")
+}
+
+func TestRemainingTimeResponseOffersOptions(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "REMT-001", Start: "14:30", End: "15:00", Room: "AU", Day: "Aug.9"},
+			{Code: "REMT-002", Start: "15:30", End: "16:00", Room: "AU", Day: "Aug.9"},
+		},
+	})
+	state := &UserState{
+		SessionID:   "test_remaining_response",
+		Day:         "Aug.9",
+		Schedule:    []Session{{Code: "REMT-001", Start: "14:30", End: "15:00", Room: "AU"}},
+		LastEndTime: "15:00",
+	}
+	storeTestUserState(t, state)
+
+	response := buildRemainingTimeResponse(state.SessionID, dayMidnight("Aug.9").Add(10*time.Hour))
+	data := responseData(t, response)
+
+	testutil.AssertEqual(t, 120, data["remaining_minutes"], "Two hours should remain after 15:00")
+	testutil.AssertEqual(t, "REMT-002", recommendationCodes(data["options"].([]Session)), "Later sessions should be offered")
+	testutil.AssertEqual(t, true, strings.Contains(response.Message, "15:00 到 17:00"), "Message should describe the unused window")
+}
+
+func TestGetSessionsByTagsMatchModes(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TTAG-BOTH", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9", Tags: []string{TagAI, TagSecurity}},
+			{Code: "TTAG-AI", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Tags: []string{TagAI}},
+		},
+	})
+
+	all := responseData(t, callTool(t, "get_sessions_by_tags", map[string]any{"day": DayAug9, "tags": []any{"AI", "Security"}, "match": "all"}))
+	testutil.AssertEqual(t, float64(1), all["session_count"], "match=all should only keep sessions with both tags")
+
+	anyMatch := responseData(t, callTool(t, "get_sessions_by_tags", map[string]any{"day": DayAug9, "tags": []any{"AI", "Security"}}))
+	testutil.AssertEqual(t, float64(2), anyMatch["session_count"], "match should default to any")
+}
+
+func TestSearchSessionsLimit(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "SLIM-001", Title: "Go Part 1", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "SLIM-002", Title: "Go Part 2", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9"},
+			{Code: "SLIM-003", Title: "Go Part 3", Start: "12:00", End: "12:30", Room: "AU", Day: "Aug.9"},
+		},
+	})
+
+	data := responseData(t, callTool(t, "search_sessions", map[string]any{"query": "go part", "limit": 2}))
+	testutil.AssertEqual(t, float64(3), data["total_matches"], "Total should count every match")
+	testutil.AssertEqual(t, 2, len(data["sessions"].([]any)), "Sessions should be capped at the limit")
+	testutil.AssertEqual(t, true, data["truncated"], "Trimmed results should be flagged")
+
+	dayData := responseData(t, callTool(t, "search_sessions", map[string]any{"query": "go part", "day": DayAug9}))
+	testutil.AssertEqual(t, DayAug9, dayData["day"], "Day should be reported in the user format")
+}
+
+func TestGetAllSessionsPagination(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "ALL-003", Start: "11:00", End: "11:30", Room: "AU", Day: "Aug.9", Abstract: "Third"},
+			{Code: "ALL-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Abstract: "First"},
+			{Code: "ALL-002", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9", Abstract: "Second"},
+		},
+	})
+
+	var codes []string
+	offset := 0
+	for {
+		data := responseData(t, callTool(t, "get_all_sessions", map[string]any{"day": DayAug9, "offset": offset, "limit": 2}))
+		testutil.AssertEqual(t, float64(3), data["total"], "Total should count the whole day")
+		for _, session := range data["sessions"].([]any) {
+			session := session.(map[string]any)
+			codes = append(codes, session["code"].(string))
+			testutil.AssertEqual(t, "", session["abstract"], "Abstracts should be left out by default")
+		}
+		if data["has_more"] != true {
+			testutil.AssertEqual(t, nil, data["next_offset"], "The last page should not offer a next offset")
+			break
+		}
+		offset = int(data["next_offset"].(float64))
+	}
+	testutil.AssertSliceEqual(t, []string{"ALL-001", "ALL-002", "ALL-003"}, codes, "Pages should reassemble the day in order")
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"day": DayAug9, "offset": 3}
+	result, err := handleGetAllSessions(context.Background(), request)
+	testutil.AssertNoError(t, err, "Handler should not return a Go error")
+	testutil.AssertEqual(t, true, result.IsError, "Offsets past the last session should be rejected")
+
+	detailed := responseData(t, callTool(t, "get_all_sessions", map[string]any{"day": DayAug9, "limit": 1, "include_detail": "true"}))
+	first := detailed["sessions"].([]any)[0].(map[string]any)
+	testutil.AssertEqual(t, "First", first["abstract"], "include_detail should keep abstracts")
+}
+
+func TestCheckFavoritesReportsClashes(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "TFAV-PLANNED", Title: "Planned", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+			{Code: "TFAV-CLASH", Title: "Clash", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"},
+		},
+	})
+	storeTestUserState(t, &UserState{
+		SessionID: "test_check_favorites",
+		Day:       "Aug.9",
+		Schedule:  []Session{{Code: "TFAV-PLANNED", Title: "Planned", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}},
+	})
+
+	data := responseData(t, callTool(t, "check_favorites", map[string]any{"sessionId": "test_check_favorites", "add": []any{"TFAV-CLASH"}}))
+	testutil.AssertEqual(t, float64(1), data["conflict_count"], "The starred session should clash")
+
+	conflict := data["conflicts"].([]any)[0].(map[string]any)
+	testutil.AssertEqual(t, "TFAV-CLASH", conflict["favorite"].(map[string]any)["code"], "Favorite should be reported")
+	testutil.AssertEqual(t, "TFAV-PLANNED", conflict["clashes_with"].([]any)[0].(map[string]any)["code"], "Clashing scheduled session should be named")
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"sessionId": "nonexistent_session"}
+	result, err := handleCheckFavorites(context.Background(), request)
+	testutil.AssertNoError(t, err, "Handler should not return a Go error")
+	testutil.AssertEqual(t, true, result.IsError, "An expired session should be an error result")
+}
+
+func TestBuildRemainingDayResponse(t *testing.T) {
+	storeTestUserState(t, &UserState{
+		SessionID: "test_remaining_day_tool",
+		Day:       "Aug.9",
+		Schedule: []Session{
+			{Code: "RDAY-001", Title: "Morning Talk", Start: "10:00", End: "10:30", Room: "AU"},
+			{Code: "RDAY-002", Title: "Afternoon Talk", Start: "14:00", End: "14:30", Room: "TR211"},
+		},
+	})
+
+	midDay, err := buildRemainingDayResponse("test_remaining_day_tool", dayMidnight("Aug.9").Add(12*time.Hour))
+	testutil.AssertNoError(t, err, "Known session should get a summary")
+	testutil.AssertEqual(t, true, strings.Contains(midDay.Message, "Afternoon Talk"), "Message should list the next session")
+	testutil.AssertEqual(t, true, strings.Contains(midDay.Message, "14:30"), "Message should give the plan end")
+
+	evening, err := buildRemainingDayResponse("test_remaining_day_tool", dayMidnight("Aug.9").Add(16*time.Hour))
+	testutil.AssertNoError(t, err, "Known session should get a summary")
+	testutil.AssertEqual(t, true, strings.Contains(evening.Message, "都已結束"), "A finished plan should say so")
+
+	_, err = buildRemainingDayResponse("nonexistent_session", dayMidnight("Aug.9").Add(12*time.Hour))
+	testutil.AssertEqual(t, true, errors.Is(err, ErrCannotFindSession), "An expired session should be an error, not a panic")
+}
+
+func TestGetOptionsPagination(t *testing.T) {
+	var sessions []Session
+	for i := range 5 {
+		sessions = append(sessions, Session{Code: fmt.Sprintf("OPAGE-%d", i), Start: "11:00", End: "11:30", Room: fmt.Sprintf("TR%d", 211+i), Day: "Aug.9"})
+	}
+	setTestSessions(t, map[string][]Session{"Aug.9": sessions})
+	storeTestUserState(t, &UserState{SessionID: "test_options_paging", Day: "Aug.9", LastEndTime: "10:30"})
+
+	optionCodes := func(data map[string]any) []string {
+		var codes []string
+		for _, option := range data["options"].([]any) {
+			codes = append(codes, option.(map[string]any)["code"].(string))
+		}
+		return codes
+	}
+
+	all := responseData(t, callTool(t, "get_options", map[string]any{"sessionId": "test_options_paging"}))
+	testutil.AssertEqual(t, false, all["has_more"], "Without a limit every option is returned")
+
+	var paged []string
+	offset := 0
+	for {
+		data := responseData(t, callTool(t, "get_options", map[string]any{"sessionId": "test_options_paging", "offset": offset, "limit": 2}))
+		testutil.AssertEqual(t, float64(5), data["total_options"], "Every page should report the total")
+		paged = append(paged, optionCodes(data)...)
+		if data["has_more"] != true {
+			break
+		}
+		offset = int(data["next_offset"].(float64))
+	}
+	testutil.AssertSliceEqual(t, optionCodes(all), paged, "Pages should cover every option once, in the same order")
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]any{"sessionId": "test_options_paging", "offset": 5}
+	result, err := handleGetOptions(context.Background(), request)
+	testutil.AssertNoError(t, err, "Handler should not return a Go error")
+	testutil.AssertEqual(t, true, result.IsError, "Offsets past the last option should be rejected")
+}
+
+func TestChooseSessionBeforeStartPlanning(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "NEVER-001", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"},
+		},
+	})
+
+	choose := func(sessionID, sessionCode string) string {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]any{"sessionId": sessionID, "sessionCode": sessionCode}
+
+		result, err := handleChooseSession(context.Background(), request)
+		testutil.AssertNoError(t, err, "Handler should not return a Go error")
+		testutil.AssertEqual(t, true, result.IsError, "Unknown session ID should be an error result")
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	madeUp := choose("my-plan", "NEVER-001")
+	testutil.AssertEqual(t, true, strings.Contains(madeUp, "call start_planning first"), "Made-up ID should point to start_planning")
+
+	neverCreated := choose("user_09_1754700000_0123456789abcdef", "NOSUCHCODE")
+	testutil.AssertEqual(t, true, strings.Contains(neverCreated, "call start_planning first"), "Unknown ID should point to start_planning even with a bad code")
+	testutil.AssertEqual(t, true, strings.Contains(neverCreated, "recreate_session"), "Well-formed ID may have expired")
+
+	storeTestUserState(t, &UserState{SessionID: "test_never_started_known", Day: "Aug.9"})
+	badCode := choose("test_never_started_known", "NOSUCHCODE")
+	testutil.AssertEqual(t, "Error: session NOSUCHCODE not found", badCode, "Unknown codes keep their own error")
+}
+
+func TestChooseSessionReportsImpossibleTransfers(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "BACK-001", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"},
+			{Code: "BACK-002", Start: "10:30", End: "11:00", Room: "AU", Day: "Aug.9"},
+		},
+	})
+	storeTestUserState(t, &UserState{
+		SessionID:   "test_choose_back_to_back",
+		Day:         "Aug.9",
+		Schedule:    []Session{{Code: "BACK-001", Start: "10:00", End: "10:30", Room: "TR515", Day: "Aug.9"}},
+		LastEndTime: "10:30",
+	})
+
+	chosen := callTool(t, "choose_session", map[string]any{"sessionId": "test_choose_back_to_back", "sessionCode": "BACK-002"})
+	transfers, ok := responseData(t, chosen)["impossible_transfers"].([]any)
+	if !ok || len(transfers) != 1 {
+		t.Fatalf("The back-to-back move should be reported, got %v", responseData(t, chosen)["impossible_transfers"])
+	}
+	transfer := transfers[0].(map[string]any)
+	testutil.AssertEqual(t, "BACK-001", transfer["from_code"], "Transfer should name the earlier session")
+	testutil.AssertEqual(t, "BACK-002", transfer["to_code"], "Transfer should name the later session")
+	testutil.AssertEqual(t, float64(TRToAUWalkTime), transfer["deficit_minutes"], "Deficit should be the whole walk")
+	testutil.AssertEqual(t, 2, len(GetUserStateSnapshot("test_choose_back_to_back").Schedule), "The add should not be blocked")
+
+	schedule := responseData(t, callTool(t, "get_schedule", map[string]any{"sessionId": "test_choose_back_to_back"}))
+	if _, ok := schedule["impossible_transfers"]; !ok {
+		t.Error("get_schedule should report the impossible transfer too")
+	}
+}
+
+func TestImportICSReportsRepeatInstance(t *testing.T) {
+	busy := Session{Code: "ICSR-BUSY", Title: "Busy Talk", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9"}
+	first := Session{Code: "ICSR-1", Title: "Repeated Workshop", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9"}
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {busy, first, {Code: "ICSR-2", Title: "Repeated Workshop", Start: "14:00", End: "14:30", Room: "TR211", Day: "Aug.9"}},
+	})
+	storeTestUserState(t, &UserState{SessionID: "test_import_repeat", Day: "Aug.9", Schedule: []Session{busy}, LastEndTime: "10:30"})
+
+	data := responseData(t, callTool(t, "import_ics", map[string]any{
+		"sessionId": "test_import_repeat",
+		"ics":       ExportScheduleICS("Aug.9", []Session{first}),
+	}))
+
+	testutil.AssertEqual(t, "ICSR-2", data["added"].([]any)[0], "The code actually added should be reported")
+	testutil.AssertEqual(t, "ICSR-2", data["requested_codes"].(map[string]any)["ICSR-1"], "The requested code should map to the added one")
+	testutil.AssertEqual(t, "ICSR-BUSY,ICSR-2", recommendationCodes(GetUserStateSnapshot("test_import_repeat").Schedule), "Reported codes should match the schedule")
+}
+
+func TestGetOptionsIncludesLanguages(t *testing.T) {
+	setTestSessions(t, map[string][]Session{
+		"Aug.9": {
+			{Code: "LANG-ZH", Title: "Mandarin Talk", Start: "10:00", End: "10:30", Room: "AU", Day: "Aug.9", Language: "漢語"},
+			{Code: "LANG-EN", Title: "English Talk", Start: "10:00", End: "10:30", Room: "TR211", Day: "Aug.9", Language: "英語"},
+			{Code: "LANG-NONE", Title: "Unlabelled Talk", Start: "10:00", End: "10:30", Room: "TR212", Day: "Aug.9"},
+		},
+	})
+	storeTestUserState(t, &UserState{SessionID: "test_option_languages", Day: "Aug.9", LastEndTime: "09:00"})
+
+	resp := callTool(t, "get_options", map[string]any{"sessionId": "test_option_languages"})
+	data := responseData(t, resp)
+
+	languages := make(map[string]any)
+	for _, option := range data["options"].([]any) {
+		option := option.(map[string]any)
+		languages[option["code"].(string)] = option["language"]
+	}
+	testutil.AssertEqual(t, "漢語", languages["LANG-ZH"], "Mandarin session should carry its language")
+	testutil.AssertEqual(t, "英語", languages["LANG-EN"], "English session should carry its language")
+
+	counts := data["languages"].(map[string]any)
+	testutil.AssertEqual(t, float64(1), counts["英語"], "Language summary should count English sessions")
+	testutil.AssertEqual(t, float64(1), counts[UnknownLanguage], "Unlabelled sessions should be counted separately")
+	testutil.AssertEqual(t, true, strings.Contains(resp.Message, "language"), "Message should ask to mark languages")
+}